@@ -0,0 +1,654 @@
+package folio
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrForbidden    = errors.New("access: permission denied")
+	ErrUnauthorized = errors.New("access: invalid credentials")
+)
+
+// IsForbidden returns true if the specified error is a permission error.
+func IsForbidden(err error) bool {
+	return errors.Is(err, ErrForbidden)
+}
+
+// IsUnauthorized returns true if the specified error is an authentication error.
+func IsUnauthorized(err error) bool {
+	return errors.Is(err, ErrUnauthorized)
+}
+
+// ---------------------------------- Role ----------------------------------
+
+// Role represents a level of access granted to a principal.
+type Role string
+
+const (
+	RoleNone   Role = ""       // No access at all
+	RoleViewer Role = "viewer" // Can view objects
+	RoleEditor Role = "editor" // Can view, create and update objects
+	RoleAdmin  Role = "admin"  // Can do everything, including deleting objects
+)
+
+// rank returns the rank of the role, higher rank means more permissions.
+func (r Role) rank() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleEditor:
+		return 2
+	case RoleAdmin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Allows returns true if the role permits the specified action.
+func (r Role) Allows(action Action) bool {
+	switch action {
+	case ActionRead:
+		return r.rank() >= RoleViewer.rank()
+	case ActionWrite:
+		return r.rank() >= RoleEditor.rank()
+	case ActionDelete:
+		return r.rank() >= RoleAdmin.rank()
+	default:
+		return false
+	}
+}
+
+// Includes returns true if the role includes all of the permissions of the other role.
+func (r Role) Includes(other Role) bool {
+	return r.rank() >= other.rank()
+}
+
+// minOf returns the role with the lowest rank.
+func minOf(a, b Role) Role {
+	if b.rank() < a.rank() {
+		return b
+	}
+	return a
+}
+
+// maxOf returns the role with the highest rank.
+func maxOf(a, b Role) Role {
+	if b.rank() > a.rank() {
+		return b
+	}
+	return a
+}
+
+// Action represents an operation performed on an object.
+type Action int
+
+const (
+	ActionRead Action = iota
+	ActionWrite
+	ActionDelete
+)
+
+// String returns the string representation of the action.
+func (a Action) String() string {
+	switch a {
+	case ActionRead:
+		return "read"
+	case ActionWrite:
+		return "write"
+	case ActionDelete:
+		return "delete"
+	}
+	return ""
+}
+
+// ---------------------------------- Principal ----------------------------------
+
+// Principal represents an authenticated identity performing an operation.
+type Principal struct {
+	Name   string   // Name of the principal (e.g. "alice")
+	Groups []string // Groups the principal belongs to (e.g. "hr")
+	Role   Role     // Role granted across all namespaces and kinds
+}
+
+// Is returns true if the subject refers to the principal, either by name, by one of
+// its groups or by the "*" wildcard which refers to everyone.
+func (p *Principal) Is(subject string) bool {
+	switch {
+	case subject == "*":
+		return true
+	case p == nil:
+		return false
+	case subject == p.Name:
+		return true
+	default:
+		return slices.Contains(p.Groups, subject)
+	}
+}
+
+// String returns the name of the principal.
+func (p *Principal) String() string {
+	if p == nil {
+		return ""
+	}
+	return p.Name
+}
+
+// ---------------------------------- Grant ----------------------------------
+
+// Grant represents a role granted to a subject (user or group) on a namespace and kind.
+type Grant struct {
+	Subject   string `json:"subject"`             // User or group name, "*" for everyone
+	Role      Role   `json:"role"`                // Role granted to the subject
+	Namespace string `json:"namespace,omitempty"` // Namespace, empty or "*" for all
+	Kind      Kind   `json:"kind,omitempty"`      // Kind, empty or "*" for all
+}
+
+// Matches returns true if the grant applies to the principal, namespace and kind.
+func (g *Grant) Matches(who *Principal, namespace string, kind Kind) bool {
+	return who.Is(g.Subject) &&
+		(g.Namespace == "" || g.Namespace == "*" || g.Namespace == namespace) &&
+		(g.Kind == "" || g.Kind == "*" || g.Kind == kind)
+}
+
+// Member represents a role granted to a user or group within a namespace.
+type Member struct {
+	Subject string `json:"subject" form:"rw" is:"required"`                      // User or group name, "*" for everyone
+	Role    Role   `json:"role" form:"rw" is:"required,in(viewer|editor|admin)"` // Role granted to the subject
+	Kind    Kind   `json:"kind,omitempty" form:"rw" desc:"Kind, empty for all"`  // Kind, empty for all
+}
+
+// ---------------------------------- Authorizer ----------------------------------
+
+// Authorizer resolves the role of a principal for a particular namespace and kind. An empty
+// namespace refers to all of the namespaces at once.
+type Authorizer interface {
+	RoleOf(who *Principal, namespace string, kind Kind) Role
+}
+
+// Can returns true if the authorizer permits the principal to perform an action on the
+// specified namespace and kind. A nil authorizer permits everything.
+func Can(authz Authorizer, who *Principal, action Action, namespace string, kind Kind) bool {
+	if authz == nil {
+		return true
+	}
+
+	return authz.RoleOf(who, namespace, kind).Allows(action)
+}
+
+// Policy is an authorizer that resolves roles from a static set of grants, the global role
+// of the principal and the members of the corresponding Namespace object.
+type Policy struct {
+	grants []Grant
+	store  Storage
+}
+
+// NewPolicy creates a new policy with the specified static grants. If the storage is provided,
+// the members of the Namespace objects are also taken into account.
+func NewPolicy(db Storage, grants ...Grant) *Policy {
+	return &Policy{
+		grants: grants,
+		store:  db,
+	}
+}
+
+// RoleOf returns the highest role granted to the principal for the namespace and kind.
+func (p *Policy) RoleOf(who *Principal, namespace string, kind Kind) Role {
+	if who == nil {
+		who = &Principal{}
+	}
+
+	role := who.Role
+	for _, grant := range p.grants {
+		if grant.Matches(who, namespace, kind) {
+			role = maxOf(role, grant.Role)
+		}
+	}
+
+	// Users can only be written by administrators, since their names are their identity
+	if kind == "user" && role != RoleAdmin {
+		role = minOf(role, RoleViewer)
+	}
+
+	// Members of a namespace can not grant themselves access to namespaces, users or tokens, otherwise
+	// anyone who can edit a namespace would be able to escalate their privileges.
+	if p.store == nil || namespace == "" || kind == "namespace" || kind == "user" || kind == "token" {
+		return role
+	}
+
+	for member := range p.membersOf(namespace) {
+		if who.Is(member.Subject) && (member.Kind == "" || member.Kind == kind) {
+			role = maxOf(role, member.Role)
+		}
+	}
+
+	return role
+}
+
+// membersOf returns the members of the namespace with the specified name.
+func (p *Policy) membersOf(namespace string) iter.Seq[Member] {
+	return func(yield func(Member) bool) {
+		found, err := p.store.Search("namespace", Query{
+			Filters: map[string][]string{"name": {namespace}},
+		})
+		if err != nil {
+			return
+		}
+
+		for obj := range found {
+			ns, ok := obj.(*Namespace)
+			if !ok {
+				continue
+			}
+
+			for _, member := range ns.Members {
+				if !yield(member) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// ---------------------------------- Scope ----------------------------------
+
+// Scope restricts an API token to a namespace, a kind and an access level. A token with a
+// "write" scope can also delete, as long as its owner is allowed to.
+type Scope struct {
+	Namespace string `json:"namespace,omitempty" form:"rw" desc:"Namespace, empty for all"`
+	Kind      Kind   `json:"kind,omitempty" form:"rw" desc:"Kind, empty for all"`
+	Access    string `json:"access" form:"rw" is:"required,in(read|write)"`
+}
+
+// Matches returns true if the scope applies to the namespace and kind. An empty namespace
+// refers to all namespaces, so only the scopes without a namespace apply to it.
+func (s *Scope) Matches(namespace string, kind Kind) bool {
+	return (s.Namespace == "" || s.Namespace == "*" || s.Namespace == namespace) &&
+		(s.Kind == "" || s.Kind == "*" || s.Kind == kind)
+}
+
+// role returns the highest role permitted by the scope.
+func (s *Scope) role() Role {
+	switch s.Access {
+	case "write":
+		return RoleAdmin
+	case "read":
+		return RoleViewer
+	default:
+		return RoleNone
+	}
+}
+
+// restricted represents an authorizer limited to a set of scopes.
+type restricted struct {
+	authz  Authorizer
+	scopes []Scope
+}
+
+// Restrict returns an authorizer which grants at most the roles permitted by the scopes. A
+// nil authorizer is considered to permit everything.
+func Restrict(authz Authorizer, scopes ...Scope) Authorizer {
+	return &restricted{
+		authz:  authz,
+		scopes: scopes,
+	}
+}
+
+// RoleOf returns the role of the principal, limited by the scopes.
+func (r *restricted) RoleOf(who *Principal, namespace string, kind Kind) Role {
+	limit := RoleNone
+	for _, scope := range r.scopes {
+		if scope.Matches(namespace, kind) {
+			limit = maxOf(limit, scope.role())
+		}
+	}
+
+	if r.authz == nil {
+		return limit
+	}
+
+	return minOf(r.authz.RoleOf(who, namespace, kind), limit)
+}
+
+// ---------------------------------- Users ----------------------------------
+
+// FindUser returns the user with the specified name.
+func FindUser(db Storage, name string) (*User, error) {
+	found, err := Search[*User](db, Query{
+		Filters: map[string][]string{"name": {name}},
+		Limit:   1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for user := range found {
+		return user, nil
+	}
+
+	return nil, fmt.Errorf("%w (user '%s')", ErrNotFound, name)
+}
+
+// Authenticate returns the user with the specified name if the password matches.
+func Authenticate(db Storage, name, password string) (*User, error) {
+	user, err := FindUser(db, name)
+	switch {
+	case IsNotFound(err):
+		return nil, ErrUnauthorized
+	case err != nil:
+		return nil, err
+	case !user.Password.Verify(password):
+		return nil, ErrUnauthorized
+	default:
+		return user, nil
+	}
+}
+
+// ChangePassword replaces the password of the user, as long as the current one matches. This
+// lets users change their own password without being able to edit the user object.
+func ChangePassword(db Storage, name, current, next string) error {
+	if next == "" {
+		return fmt.Errorf("%w, the new password must not be empty", ErrRejected)
+	}
+
+	user, err := Authenticate(db, name, current)
+	if err != nil {
+		return err
+	}
+
+	if user.Password, err = NewPassword(next); err != nil {
+		return err
+	}
+
+	_, err = Update(db, user, name)
+	return err
+}
+
+// Bootstrap creates an initial administrator with the specified name and password, unless
+// there is already at least one user. It returns true if the administrator was created.
+func Bootstrap(db Storage, name, password string) (bool, error) {
+	count, err := db.Count("user", Query{})
+	if err != nil || count > 0 {
+		return false, err
+	}
+
+	hashed, err := NewPassword(password)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := Create(db, func(user *User) error {
+		user.Name = name
+		user.Role = RoleAdmin
+		user.Password = hashed
+		return nil
+	}, "default", "sys"); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ---------------------------------- Tokens ----------------------------------
+
+// AuthenticateToken returns the token for the bearer value, as long as the secret matches
+// and the token is neither revoked nor expired.
+func AuthenticateToken(db Storage, bearer string) (*Token, error) {
+	id, secret, ok := strings.Cut(bearer, ".")
+	if !ok || id == "" || secret == "" {
+		return nil, ErrUnauthorized
+	}
+
+	found, err := Search[*Token](db, Query{
+		Filters: map[string][]string{"id": {id}},
+		Limit:   1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for token := range found {
+		switch {
+		case !token.Verify(secret):
+			return nil, ErrUnauthorized
+		case token.Revoked:
+			return nil, fmt.Errorf("%w, token revoked", ErrUnauthorized)
+		case token.Expired(time.Now()):
+			return nil, fmt.Errorf("%w, token expired", ErrUnauthorized)
+		default:
+			return token, nil
+		}
+	}
+
+	return nil, ErrUnauthorized
+}
+
+// ---------------------------------- Storage ----------------------------------
+
+// readablePage is the number of objects fetched at once when searching across namespaces.
+const readablePage = 100
+
+// secured represents a storage which authorizes every operation.
+type secured struct {
+	Storage
+	authz Authorizer
+	who   *Principal
+}
+
+// Secure wraps the storage so that every operation is authorized against the principal. When
+// searching across all namespaces, only the objects the principal is allowed to read are returned.
+func Secure(db Storage, authz Authorizer, who *Principal) Storage {
+	if authz == nil {
+		return db
+	}
+
+	return &secured{
+		Storage: db,
+		authz:   authz,
+		who:     who,
+	}
+}
+
+// check returns an error if the principal is not allowed to perform the action.
+func (s *secured) check(action Action, namespace string, kind Kind) error {
+	if !Can(s.authz, s.who, action, namespace, kind) {
+		return fmt.Errorf("%w (%s %s/%s by '%s')", ErrForbidden, action, namespace, kind, s.who)
+	}
+	return nil
+}
+
+// checkOwner returns an error if the principal writes a token which is owned by someone else,
+// either currently or once written, unless it administers the tokens of the namespace. Tokens
+// act on behalf of their owner, so that they could otherwise be used to impersonate them.
+func (s *secured) checkOwner(urn URN, next Object) error {
+	if urn.Kind != "token" || s.authz.RoleOf(s.who, urn.Namespace, urn.Kind) == RoleAdmin {
+		return nil
+	}
+
+	current, err := s.Storage.Fetch(urn)
+	switch {
+	case IsNotFound(err):
+		current = nil
+	case err != nil:
+		return err
+	}
+
+	for _, v := range []Object{current, next} {
+		if token, ok := v.(*Token); ok && (s.who == nil || token.Owner != s.who.Name) {
+			return fmt.Errorf("%w (token %s is owned by '%s')", ErrForbidden, urn, token.Owner)
+		}
+	}
+	return nil
+}
+
+// Insert inserts a new resource into the storage.
+func (s *secured) Insert(v Object, createdBy string) (Object, error) {
+	if err := s.check(ActionWrite, v.URN().Namespace, v.URN().Kind); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkOwner(v.URN(), v); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.Insert(v, createdBy)
+}
+
+// Update updates an existing resource in the storage.
+func (s *secured) Update(v Object, updatedBy string) (Object, error) {
+	if err := s.check(ActionWrite, v.URN().Namespace, v.URN().Kind); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkOwner(v.URN(), v); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.Update(v, updatedBy)
+}
+
+// Upsert inserts or updates a resource in the storage.
+func (s *secured) Upsert(v Object, updatedBy string) (Object, error) {
+	if err := s.check(ActionWrite, v.URN().Namespace, v.URN().Kind); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkOwner(v.URN(), v); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.Upsert(v, updatedBy)
+}
+
+// Restore writes a resource as-is, keeping its metadata.
+func (s *secured) Restore(v Object) (Object, error) {
+	if err := s.check(ActionWrite, v.URN().Namespace, v.URN().Kind); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkOwner(v.URN(), v); err != nil {
+		return nil, err
+	}
+
+	return Restore(s.Storage, v)
+}
+
+// Delete deletes a resource from the storage.
+func (s *secured) Delete(urn URN, deletedBy string) (Object, error) {
+	if err := s.check(ActionDelete, urn.Namespace, urn.Kind); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkOwner(urn, nil); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.Delete(urn, deletedBy)
+}
+
+// Fetch retrieves a resource by URN.
+func (s *secured) Fetch(urn URN) (Object, error) {
+	if err := s.check(ActionRead, urn.Namespace, urn.Kind); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.Fetch(urn)
+}
+
+// Search performs a query against the storage layer.
+func (s *secured) Search(kind Kind, q Query) (iter.Seq[Object], error) {
+	switch {
+	case q.Namespace != "":
+		if err := s.check(ActionRead, q.Namespace, kind); err != nil {
+			return nil, err
+		}
+		return s.Storage.Search(kind, q)
+	case s.check(ActionRead, "", kind) == nil:
+		return s.Storage.Search(kind, q)
+	}
+
+	objects, err := s.readable(kind, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.Values(objects), nil
+}
+
+// Count returns the number of records that match the specified query.
+func (s *secured) Count(kind Kind, q Query) (int, error) {
+	switch {
+	case q.Namespace != "":
+		if err := s.check(ActionRead, q.Namespace, kind); err != nil {
+			return 0, err
+		}
+		return s.Storage.Count(kind, q)
+	case s.check(ActionRead, "", kind) == nil:
+		return s.Storage.Count(kind, q)
+	}
+
+	// Count every readable object, regardless of the page requested
+	q.Offset, q.Limit = 0, 0
+	objects, err := s.readable(kind, q)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(objects), nil
+}
+
+// readable returns the objects the principal is allowed to read across all namespaces. Since
+// the restriction can not be expressed as a query, the storage is paged through until the limit
+// of the query is filled, skipping the readable objects before its offset. The access to each
+// namespace is resolved once, as it may require a lookup of its members.
+func (s *secured) readable(kind Kind, q Query) ([]Object, error) {
+	page := q
+	page.Offset, page.Limit = 0, max(q.Limit, readablePage)
+
+	allowed := make(map[string]bool)
+	canRead := func(namespace string) bool {
+		ok, found := allowed[namespace]
+		if !found {
+			ok = s.check(ActionRead, namespace, kind) == nil
+			allowed[namespace] = ok
+		}
+		return ok
+	}
+
+	var out []Object
+	skip := q.Offset
+	for {
+		found, err := s.Storage.Search(kind, page)
+		if err != nil {
+			return nil, err
+		}
+
+		// Collect the page first, since resolving the role may query the storage again
+		// and the underlying iterator could still hold on to the connection.
+		objects := slices.Collect(found)
+		for _, obj := range objects {
+			switch {
+			case !canRead(obj.URN().Namespace):
+				continue
+			case skip > 0:
+				skip--
+				continue
+			}
+
+			out = append(out, obj)
+			if q.Limit > 0 && len(out) == q.Limit {
+				return out, nil
+			}
+		}
+
+		if len(objects) < page.Limit {
+			return out, nil
+		}
+		page.Offset += page.Limit
+	}
+}
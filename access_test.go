@@ -0,0 +1,455 @@
+package folio_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kelindar/folio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRole_Allows(t *testing.T) {
+	tests := []struct {
+		role   folio.Role
+		read   bool
+		write  bool
+		delete bool
+	}{
+		{folio.RoleNone, false, false, false},
+		{folio.RoleViewer, true, false, false},
+		{folio.RoleEditor, true, true, false},
+		{folio.RoleAdmin, true, true, true},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.read, tc.role.Allows(folio.ActionRead), tc.role)
+		assert.Equal(t, tc.write, tc.role.Allows(folio.ActionWrite), tc.role)
+		assert.Equal(t, tc.delete, tc.role.Allows(folio.ActionDelete), tc.role)
+	}
+
+	assert.True(t, folio.RoleAdmin.Includes(folio.RoleEditor))
+	assert.False(t, folio.RoleViewer.Includes(folio.RoleEditor))
+}
+
+func TestPolicy_Grants(t *testing.T) {
+	policy := folio.NewPolicy(nil,
+		folio.Grant{Subject: "*", Role: folio.RoleViewer, Namespace: "public"},
+		folio.Grant{Subject: "hr", Role: folio.RoleEditor, Kind: "app"},
+		folio.Grant{Subject: "bob", Role: folio.RoleAdmin, Namespace: "ops", Kind: "app"},
+	)
+
+	alice := &folio.Principal{Name: "alice", Groups: []string{"hr"}}
+	bob := &folio.Principal{Name: "bob"}
+	root := &folio.Principal{Name: "root", Role: folio.RoleAdmin}
+
+	assert.Equal(t, folio.RoleViewer, policy.RoleOf(nil, "public", "app"))
+	assert.Equal(t, folio.RoleNone, policy.RoleOf(nil, "ops", "app"))
+	assert.Equal(t, folio.RoleEditor, policy.RoleOf(alice, "ops", "app"))
+	assert.Equal(t, folio.RoleNone, policy.RoleOf(alice, "ops", "deployment"))
+	assert.Equal(t, folio.RoleAdmin, policy.RoleOf(bob, "ops", "app"))
+	assert.Equal(t, folio.RoleNone, policy.RoleOf(bob, "dev", "app"))
+	assert.Equal(t, folio.RoleAdmin, policy.RoleOf(root, "dev", "app"))
+}
+
+func TestPolicy_Members(t *testing.T) {
+	testStorage(func(db folio.Storage, _ folio.Registry) {
+		_, err := folio.Create(db, func(ns *folio.Namespace) error {
+			ns.Name = "ops"
+			ns.Label = "Operations"
+			ns.Members = []folio.Member{
+				{Subject: "alice", Role: folio.RoleAdmin},
+				{Subject: "dev", Role: folio.RoleViewer, Kind: "app"},
+			}
+			return nil
+		}, "default", "test")
+		assert.NoError(t, err)
+
+		policy := folio.NewPolicy(db)
+		alice := &folio.Principal{Name: "alice"}
+		bob := &folio.Principal{Name: "bob", Groups: []string{"dev"}}
+
+		assert.Equal(t, folio.RoleAdmin, policy.RoleOf(alice, "ops", "app"))
+		assert.Equal(t, folio.RoleNone, policy.RoleOf(alice, "dev", "app"))
+		assert.Equal(t, folio.RoleNone, policy.RoleOf(alice, "ops", "namespace"))
+		assert.Equal(t, folio.RoleViewer, policy.RoleOf(bob, "ops", "app"))
+		assert.Equal(t, folio.RoleNone, policy.RoleOf(bob, "ops", "deployment"))
+	})
+}
+
+func TestSecure(t *testing.T) {
+	testStorage(func(db folio.Storage, _ folio.Registry) {
+		for _, ns := range []string{"ops", "dev", "dev"} {
+			_, err := folio.Create(db, newApp, ns, "test")
+			assert.NoError(t, err)
+		}
+
+		// Bob can edit the "dev" namespace and only view the "ops" one
+		bob := &folio.Principal{Name: "bob"}
+		store := folio.Secure(db, folio.NewPolicy(nil,
+			folio.Grant{Subject: "bob", Role: folio.RoleEditor, Namespace: "dev"},
+			folio.Grant{Subject: "bob", Role: folio.RoleViewer, Namespace: "ops"},
+		), bob)
+
+		// Can create in "dev" but not in "ops"
+		_, err := folio.Create(store, newApp, "dev", "bob")
+		assert.NoError(t, err)
+		_, err = folio.Create(store, newApp, "ops", "bob")
+		assert.True(t, folio.IsForbidden(err))
+
+		// Can read both, but can not delete
+		apps, err := folio.Search[*App](store, folio.Query{Namespace: "ops"})
+		assert.NoError(t, err)
+		for _, app := range slices.Collect(apps) {
+			_, err := store.Fetch(app.URN())
+			assert.NoError(t, err)
+
+			_, err = store.Delete(app.URN(), "bob")
+			assert.True(t, folio.IsForbidden(err))
+		}
+
+		// Members of the namespace are resolved while searching across namespaces
+		_, err = folio.Create(db, func(ns *folio.Namespace) error {
+			ns.Name = "ops"
+			ns.Label = "Operations"
+			ns.Members = []folio.Member{{Subject: "eve", Role: folio.RoleViewer}}
+			return nil
+		}, "default", "test")
+		assert.NoError(t, err)
+
+		count, err := folio.Count[*App](folio.Secure(db, folio.NewPolicy(db), &folio.Principal{Name: "eve"}), folio.Query{})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		// Searching across namespaces only returns the readable ones
+		count, err = folio.Count[*App](store, folio.Query{})
+		assert.NoError(t, err)
+		assert.Equal(t, 4, count)
+
+		// Someone else can not read anything
+		other := folio.Secure(db, folio.NewPolicy(nil), &folio.Principal{Name: "eve"})
+		count, err = folio.Count[*App](other, folio.Query{})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+
+		_, err = folio.Count[*App](other, folio.Query{Namespace: "dev"})
+		assert.True(t, folio.IsForbidden(err))
+	})
+}
+
+func TestSecure_Paging(t *testing.T) {
+	testStorage(func(db folio.Storage, _ folio.Registry) {
+		for i := 0; i < 150; i++ {
+			_, err := folio.Create(db, newApp, []string{"ops", "dev", "dev"}[i%3], "test")
+			assert.NoError(t, err)
+		}
+
+		// Bob can only read the "dev" namespace, which is interleaved with the "ops" one
+		store := folio.Secure(db, folio.NewPolicy(nil,
+			folio.Grant{Subject: "bob", Role: folio.RoleViewer, Namespace: "dev"},
+		), &folio.Principal{Name: "bob"})
+
+		ids := func(db folio.Storage, q folio.Query) (out []string) {
+			q.SortBy = []string{"id"}
+			found, err := folio.Search[*App](db, q)
+			assert.NoError(t, err)
+			for app := range found {
+				out = append(out, app.ID)
+			}
+			return
+		}
+
+		// Pages are filled with readable objects only
+		dev := ids(db, folio.Query{Namespace: "dev"})
+		assert.Len(t, dev, 100)
+		assert.Equal(t, dev[:3], ids(store, folio.Query{Limit: 3}))
+		assert.Equal(t, dev[3:6], ids(store, folio.Query{Limit: 3, Offset: 3}))
+		assert.Equal(t, dev, ids(store, folio.Query{Limit: 150}))
+		assert.Equal(t, dev[95:], ids(store, folio.Query{Offset: 95}))
+
+		// Counting ignores the page requested
+		count, err := store.Count("app", folio.Query{Limit: 3, Offset: 3})
+		assert.NoError(t, err)
+		assert.Equal(t, 100, count)
+	})
+}
+
+func TestSecure_Lookups(t *testing.T) {
+	testStorage(func(db folio.Storage, _ folio.Registry) {
+		for i := 0; i < 150; i++ {
+			_, err := folio.Create(db, newApp, []string{"ops", "dev", "dev"}[i%3], "test")
+			assert.NoError(t, err)
+		}
+
+		// The role is resolved once per namespace, rather than once per object
+		authz := &countingAuthorizer{Authorizer: folio.NewPolicy(db,
+			folio.Grant{Subject: "bob", Role: folio.RoleViewer, Namespace: "dev"},
+		)}
+
+		store := folio.Secure(db, authz, &folio.Principal{Name: "bob"})
+		count, err := store.Count("app", folio.Query{})
+		assert.NoError(t, err)
+		assert.Equal(t, 100, count)
+		assert.Equal(t, 3, authz.calls) // Across all namespaces, then "ops" and "dev"
+	})
+}
+
+// countingAuthorizer counts the roles resolved by the authorizer.
+type countingAuthorizer struct {
+	folio.Authorizer
+	calls int
+}
+
+func (a *countingAuthorizer) RoleOf(who *folio.Principal, namespace string, kind folio.Kind) folio.Role {
+	a.calls++
+	return a.Authorizer.RoleOf(who, namespace, kind)
+}
+
+func TestSecure_NoAuthorizer(t *testing.T) {
+	testStorage(func(db folio.Storage, _ folio.Registry) {
+		assert.Equal(t, db, folio.Secure(db, nil, nil))
+	})
+}
+
+func newApp(*App) error {
+	return nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	testStorage(func(db folio.Storage, _ folio.Registry) {
+		created, err := folio.Bootstrap(db, "admin", "secret")
+		assert.NoError(t, err)
+		assert.True(t, created)
+
+		// Only bootstraps once
+		created, err = folio.Bootstrap(db, "admin", "other")
+		assert.NoError(t, err)
+		assert.False(t, created)
+
+		user, err := folio.Authenticate(db, "admin", "secret")
+		assert.NoError(t, err)
+		assert.Equal(t, folio.RoleAdmin, user.Principal().Role)
+
+		_, err = folio.Authenticate(db, "admin", "other")
+		assert.True(t, folio.IsUnauthorized(err))
+
+		_, err = folio.Authenticate(db, "alice", "secret")
+		assert.True(t, folio.IsUnauthorized(err))
+	})
+}
+
+func TestUser_UniqueName(t *testing.T) {
+	testStorage(func(db folio.Storage, _ folio.Registry) {
+		_, err := folio.Bootstrap(db, "admin", "secret")
+		assert.NoError(t, err)
+
+		_, err = folio.Create(db, func(u *folio.User) error {
+			u.Name = "admin"
+			u.Role = folio.RoleViewer
+			return nil
+		}, "default", "test")
+		assert.True(t, folio.IsRejected(err))
+
+		// The password of the existing user is unchanged
+		_, err = folio.Authenticate(db, "admin", "secret")
+		assert.NoError(t, err)
+	})
+}
+
+func TestUser_Rename(t *testing.T) {
+	testStorage(func(db folio.Storage, _ folio.Registry) {
+		_, err := folio.Bootstrap(db, "alice", "secret")
+		assert.NoError(t, err)
+
+		bob, err := folio.Create(db, func(u *folio.User) error {
+			u.Name = "bob"
+			u.Role = folio.RoleEditor
+			return nil
+		}, "default", "test")
+		assert.NoError(t, err)
+
+		// An editor can not write users, not even their own
+		editor := folio.Secure(db, folio.NewPolicy(db), bob.Principal())
+		bob.Name = "alice"
+		_, err = folio.Update(editor, bob, "bob")
+		assert.True(t, folio.IsForbidden(err))
+
+		// And an administrator can not rename a user onto another one
+		_, err = folio.Update(db, bob, "admin")
+		assert.True(t, folio.IsRejected(err))
+		assert.ErrorContains(t, err, "user 'alice' already exists")
+
+		found, err := folio.FindUser(db, "alice")
+		assert.NoError(t, err)
+		assert.Equal(t, folio.RoleAdmin, found.Role)
+
+		// Renaming onto a free name is allowed
+		bob.Name = "robert"
+		_, err = folio.Update(db, bob, "admin")
+		assert.NoError(t, err)
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	testStorage(func(db folio.Storage, _ folio.Registry) {
+		_, err := folio.Bootstrap(db, "admin", "secret")
+		assert.NoError(t, err)
+
+		// The current password is required
+		err = folio.ChangePassword(db, "admin", "wrong", "changed")
+		assert.True(t, folio.IsUnauthorized(err))
+
+		// The new password must not be empty
+		err = folio.ChangePassword(db, "admin", "secret", "")
+		assert.True(t, folio.IsRejected(err))
+
+		assert.NoError(t, folio.ChangePassword(db, "admin", "secret", "changed"))
+		_, err = folio.Authenticate(db, "admin", "secret")
+		assert.True(t, folio.IsUnauthorized(err))
+		_, err = folio.Authenticate(db, "admin", "changed")
+		assert.NoError(t, err)
+	})
+}
+
+func TestPolicy_UserMembers(t *testing.T) {
+	testStorage(func(db folio.Storage, _ folio.Registry) {
+		_, err := folio.Create(db, func(ns *folio.Namespace) error {
+			ns.Name = "default"
+			ns.Label = "Default"
+			ns.Members = []folio.Member{{Subject: "alice", Role: folio.RoleAdmin}}
+			return nil
+		}, "default", "test")
+		assert.NoError(t, err)
+
+		// Members of a namespace can not manage the users within it
+		policy := folio.NewPolicy(db)
+		alice := &folio.Principal{Name: "alice"}
+		assert.Equal(t, folio.RoleAdmin, policy.RoleOf(alice, "default", "app"))
+		assert.Equal(t, folio.RoleNone, policy.RoleOf(alice, "default", "user"))
+	})
+}
+
+func TestRestrict(t *testing.T) {
+	policy := folio.NewPolicy(nil, folio.Grant{Subject: "alice", Role: folio.RoleAdmin})
+	alice := &folio.Principal{Name: "alice"}
+	authz := folio.Restrict(policy,
+		folio.Scope{Namespace: "ops", Kind: "app", Access: "write"},
+		folio.Scope{Access: "read"},
+	)
+
+	assert.Equal(t, folio.RoleAdmin, authz.RoleOf(alice, "ops", "app"))
+	assert.Equal(t, folio.RoleViewer, authz.RoleOf(alice, "ops", "deployment"))
+	assert.Equal(t, folio.RoleViewer, authz.RoleOf(alice, "", "app"))
+	assert.Equal(t, folio.RoleNone, authz.RoleOf(&folio.Principal{Name: "bob"}, "ops", "app"))
+
+	// Scopes never grant more than the principal has
+	assert.Equal(t, folio.RoleViewer, folio.Restrict(nil, folio.Scope{Access: "read"}).RoleOf(alice, "ops", "app"))
+	assert.Equal(t, folio.RoleNone, folio.Restrict(nil).RoleOf(alice, "ops", "app"))
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	testStorage(func(db folio.Storage, _ folio.Registry) {
+		var bearer string
+		token, err := folio.Create(db, func(v *folio.Token) (err error) {
+			v.Name = "ci"
+			v.Owner = "alice"
+			v.Days = 30
+			bearer, err = v.Mint()
+			return
+		}, "default", "alice")
+		assert.NoError(t, err)
+		assert.NotContains(t, token.Secret, bearer)
+		assert.Equal(t, "Active", token.Status())
+
+		found, err := folio.AuthenticateToken(db, bearer)
+		assert.NoError(t, err)
+		assert.Equal(t, "alice", found.Owner)
+
+		// Wrong or malformed secrets
+		_, err = folio.AuthenticateToken(db, token.ID+".wrong")
+		assert.True(t, folio.IsUnauthorized(err))
+		_, err = folio.AuthenticateToken(db, "garbage")
+		assert.True(t, folio.IsUnauthorized(err))
+
+		// Revoked token
+		token.Revoked = true
+		_, err = folio.Upsert(db, token, "alice")
+		assert.NoError(t, err)
+		_, err = folio.AuthenticateToken(db, bearer)
+		assert.True(t, folio.IsUnauthorized(err))
+		assert.Equal(t, "Revoked", token.Status())
+	})
+}
+
+func TestToken_Owner(t *testing.T) {
+	testStorage(func(db folio.Storage, _ folio.Registry) {
+		policy := folio.NewPolicy(db)
+		alice := folio.Secure(db, policy, &folio.Principal{Name: "alice", Role: folio.RoleEditor})
+		bob := folio.Secure(db, policy, &folio.Principal{Name: "bob", Role: folio.RoleEditor})
+		admin := folio.Secure(db, policy, &folio.Principal{Name: "root", Role: folio.RoleAdmin})
+
+		token, err := folio.Create(alice, func(v *folio.Token) error {
+			v.Name = "ci"
+			v.Owner = "alice"
+			return nil
+		}, "default", "alice")
+		assert.NoError(t, err)
+
+		// Only the owner or an administrator can change the token
+		token.Scopes = []folio.Scope{{Namespace: "ops", Access: "write"}}
+		_, err = folio.Update(bob, token, "bob")
+		assert.True(t, folio.IsForbidden(err))
+		_, err = bob.Delete(token.URN(), "bob")
+		assert.True(t, folio.IsForbidden(err))
+		token, err = folio.Update(alice, token, "alice")
+		assert.NoError(t, err)
+
+		// Nor can a token be given to someone else
+		_, err = folio.Create(bob, func(v *folio.Token) error {
+			v.Name = "ci"
+			v.Owner = "alice"
+			return nil
+		}, "default", "bob")
+		assert.True(t, folio.IsForbidden(err))
+
+		// A revoked token can not be restored, not even by an administrator
+		token.Revoked = true
+		token, err = folio.Update(alice, token, "alice")
+		assert.NoError(t, err)
+
+		token.Revoked = false
+		_, err = folio.Update(admin, token, "root")
+		assert.True(t, folio.IsRejected(err))
+		assert.ErrorContains(t, err, "can not be restored")
+	})
+}
+
+func TestToken_Expired(t *testing.T) {
+	token := &folio.Token{Days: 1}
+	token.CreatedAt = time.Now().Add(-48 * time.Hour).UnixNano()
+	assert.True(t, token.Expired(time.Now()))
+	assert.Equal(t, "Expired", token.Status())
+
+	// Expired tokens are kept in the storage, so they are not purged like an Expirer
+	_, ok := any(token).(folio.Expirer)
+	assert.False(t, ok)
+
+	token.Days = 0
+	assert.True(t, token.Expiry().IsZero())
+	assert.False(t, token.Expired(time.Now()))
+}
+
+func TestPolicy_TokenMembers(t *testing.T) {
+	testStorage(func(db folio.Storage, _ folio.Registry) {
+		_, err := folio.Create(db, func(ns *folio.Namespace) error {
+			ns.Name = "default"
+			ns.Label = "Default"
+			ns.Members = []folio.Member{{Subject: "alice", Role: folio.RoleAdmin}}
+			return nil
+		}, "default", "test")
+		assert.NoError(t, err)
+
+		policy := folio.NewPolicy(db)
+		assert.Equal(t, folio.RoleNone, policy.RoleOf(&folio.Principal{Name: "alice"}, "default", "token"))
+	})
+}
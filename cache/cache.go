@@ -0,0 +1,275 @@
+package cache
+
+import (
+	"encoding/json"
+	"iter"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kelindar/folio"
+)
+
+// Options represents the options of the cache.
+type Options struct {
+	Size      int           // Maximum number of objects kept for Fetch, 1024 by default
+	QueryTTL  time.Duration // Duration for which query results are kept, not cached if zero
+	QuerySize int           // Maximum number of query results kept, 256 by default
+}
+
+// Stats represents the counters of the cache.
+type Stats struct {
+	Hits        uint64 // Number of fetches served from the cache
+	Misses      uint64 // Number of fetches served by the storage
+	QueryHits   uint64 // Number of searches and counts served from the cache
+	QueryMisses uint64 // Number of searches and counts served by the storage
+	Evictions   uint64 // Number of entries evicted to stay within the bounds
+	Objects     int    // Number of objects currently cached
+	Queries     int    // Number of query results currently cached
+}
+
+// Storage represents a read-through cache in front of a storage. Objects are kept encoded, so
+// that every read returns a copy which the caller is free to modify. Writes made through the
+// cache invalidate the object and the cached queries of its kind, but writes made directly
+// to the underlying storage are only seen once the entries are evicted or stale.
+type Storage struct {
+	folio.Storage
+	registry folio.Registry
+	options  Options
+	mu       sync.Mutex
+	objects  *lru   // Encoded objects, by URN
+	queries  *lru   // Encoded results, by query
+	version  uint64 // Incremented on every write, so that concurrent reads are not cached
+	stats    Stats
+}
+
+// New wraps the storage with a cache of the recently fetched objects and, if a TTL is
+// specified, of the recent query results.
+func New(db folio.Storage, registry folio.Registry, opts ...Options) *Storage {
+	var options Options
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	if options.Size <= 0 {
+		options.Size = 1024
+	}
+
+	if options.QuerySize <= 0 {
+		options.QuerySize = 256
+	}
+
+	return &Storage{
+		Storage:  db,
+		registry: registry,
+		options:  options,
+		objects:  newLRU(options.Size),
+		queries:  newLRU(options.QuerySize),
+	}
+}
+
+// Stats returns the counters of the cache.
+func (c *Storage) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Objects = c.objects.Len()
+	stats.Queries = c.queries.Len()
+	return stats
+}
+
+// ---------------------------------- Reads ----------------------------------
+
+// Fetch retrieves a resource by URN, from the cache if possible.
+func (c *Storage) Fetch(urn folio.URN) (folio.Object, error) {
+	key := urn.String()
+	cached, version := c.lookup(c.objects, key, &c.stats.Hits, &c.stats.Misses)
+	if cached != nil {
+		return folio.FromJSON(c.registry, cached.value.([]byte))
+	}
+
+	obj, err := c.Storage.Fetch(urn)
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := folio.ToStorageJSON(obj); err == nil {
+		c.store(c.objects, version, &entry{
+			key:     key,
+			kind:    string(urn.Kind),
+			value:   data,
+			expires: c.expiryOf(obj),
+		})
+	}
+	return obj, nil
+}
+
+// Search performs a query against the storage layer, from the cache if possible.
+func (c *Storage) Search(kind folio.Kind, q folio.Query) (iter.Seq[folio.Object], error) {
+	key, ok := c.queryKey("search", kind, q)
+	if !ok {
+		return c.Storage.Search(kind, q)
+	}
+
+	cached, version := c.lookup(c.queries, key, &c.stats.QueryHits, &c.stats.QueryMisses)
+	if cached != nil {
+		objects := make([]folio.Object, 0, len(cached.value.([][]byte)))
+		for _, data := range cached.value.([][]byte) {
+			obj, err := folio.FromJSON(c.registry, data)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, obj)
+		}
+		return slices.Values(objects), nil
+	}
+
+	found, err := c.Storage.Search(kind, q)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the results, so that they can be cached
+	objects := slices.Collect(found)
+	encoded := make([][]byte, 0, len(objects))
+	for _, obj := range objects {
+		data, err := folio.ToStorageJSON(obj)
+		if err != nil {
+			return slices.Values(objects), nil
+		}
+		encoded = append(encoded, data)
+	}
+
+	c.store(c.queries, version, &entry{
+		key:     key,
+		kind:    string(kind),
+		value:   encoded,
+		expires: time.Now().Add(c.options.QueryTTL),
+	})
+	return slices.Values(objects), nil
+}
+
+// Count returns the number of records that match the specified query, from the cache if possible.
+func (c *Storage) Count(kind folio.Kind, q folio.Query) (int, error) {
+	key, ok := c.queryKey("count", kind, q)
+	if !ok {
+		return c.Storage.Count(kind, q)
+	}
+
+	cached, version := c.lookup(c.queries, key, &c.stats.QueryHits, &c.stats.QueryMisses)
+	if cached != nil {
+		return cached.value.(int), nil
+	}
+
+	count, err := c.Storage.Count(kind, q)
+	if err != nil {
+		return 0, err
+	}
+
+	c.store(c.queries, version, &entry{
+		key:     key,
+		kind:    string(kind),
+		value:   count,
+		expires: time.Now().Add(c.options.QueryTTL),
+	})
+	return count, nil
+}
+
+// lookup returns the cached entry, if any, and the version at the time of the lookup.
+func (c *Storage) lookup(set *lru, key string, hits, misses *uint64) (*entry, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := set.Get(key, time.Now())
+	if ok {
+		*hits++
+		return cached, c.version
+	}
+
+	*misses++
+	return nil, c.version
+}
+
+// store caches the entry, unless a write happened since the version was read.
+func (c *Storage) store(set *lru, version uint64, e *entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.version != version {
+		return
+	}
+
+	if set.Put(e) {
+		c.stats.Evictions++
+	}
+}
+
+// queryKey returns the key of the query, or false if the query results are not cached.
+func (c *Storage) queryKey(prefix string, kind folio.Kind, q folio.Query) (string, bool) {
+	if c.options.QueryTTL <= 0 {
+		return "", false
+	}
+
+	// The filters are a map, which is encoded with sorted keys
+	encoded, err := json.Marshal(q)
+	if err != nil {
+		return "", false
+	}
+
+	return prefix + ":" + string(kind) + ":" + string(encoded), true
+}
+
+// expiryOf returns the time at which the object expires, or zero if it never expires.
+func (c *Storage) expiryOf(obj folio.Object) time.Time {
+	typ, err := c.registry.Resolve(obj.URN().Kind)
+	if err != nil {
+		return time.Time{}
+	}
+
+	_, updatedAt := obj.Updated()
+	return folio.ExpiryOf(typ, obj, updatedAt)
+}
+
+// ---------------------------------- Writes ----------------------------------
+
+// Insert inserts a new resource into the storage.
+func (c *Storage) Insert(v folio.Object, createdBy string) (folio.Object, error) {
+	defer c.invalidate(v.URN())
+	return c.Storage.Insert(v, createdBy)
+}
+
+// Update updates an existing resource in the storage.
+func (c *Storage) Update(v folio.Object, updatedBy string) (folio.Object, error) {
+	defer c.invalidate(v.URN())
+	return c.Storage.Update(v, updatedBy)
+}
+
+// Upsert inserts or updates a resource in the storage.
+func (c *Storage) Upsert(v folio.Object, updatedBy string) (folio.Object, error) {
+	defer c.invalidate(v.URN())
+	return c.Storage.Upsert(v, updatedBy)
+}
+
+// Restore writes a resource as-is, keeping its metadata.
+func (c *Storage) Restore(v folio.Object) (folio.Object, error) {
+	defer c.invalidate(v.URN())
+	return folio.Restore(c.Storage, v)
+}
+
+// Delete deletes a resource from the storage.
+func (c *Storage) Delete(urn folio.URN, deletedBy string) (folio.Object, error) {
+	defer c.invalidate(urn)
+	return c.Storage.Delete(urn, deletedBy)
+}
+
+// invalidate removes the object and the cached queries of its kind. This is done even if the
+// write failed, since a conflict may be the result of a stale entry.
+func (c *Storage) invalidate(urn folio.URN) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	c.objects.Remove(urn.String())
+	c.queries.RemoveKind(string(urn.Kind))
+}
@@ -0,0 +1,213 @@
+package cache
+
+import (
+	"iter"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+type App struct {
+	folio.Meta `kind:"app" json:",inline"`
+	Name       string   `json:"name"`
+	Tags       []string `json:"tags"`
+}
+
+type Session struct {
+	folio.Meta `kind:"session" json:",inline"`
+	Name       string `json:"name"`
+}
+
+func TestFetch(t *testing.T) {
+	testCache(Options{}, func(db *Storage, counter *counter) {
+		app, err := folio.Create[*App](db, func(a *App) error {
+			a.Name = "first"
+			a.Tags = []string{"a"}
+			return nil
+		}, "my_project", "test")
+		assert.NoError(t, err)
+
+		// Only the first fetch reaches the storage
+		for i := 0; i < 3; i++ {
+			found, err := folio.Fetch[*App](db, app.URN())
+			assert.NoError(t, err)
+			assert.Equal(t, "first", found.Name)
+		}
+
+		assert.Equal(t, 1, counter.fetches)
+		assert.Equal(t, Stats{Hits: 2, Misses: 1, Objects: 1}, db.Stats())
+
+		// Every fetch returns a copy
+		found, err := folio.Fetch[*App](db, app.URN())
+		assert.NoError(t, err)
+		found.Name = "changed"
+		found.Tags[0] = "changed"
+
+		again, err := folio.Fetch[*App](db, app.URN())
+		assert.NoError(t, err)
+		assert.Equal(t, "first", again.Name)
+		assert.Equal(t, []string{"a"}, again.Tags)
+
+		// Writes invalidate the object
+		again.Name = "second"
+		_, err = folio.Update(db, again, "test")
+		assert.NoError(t, err)
+
+		updated, err := folio.Fetch[*App](db, app.URN())
+		assert.NoError(t, err)
+		assert.Equal(t, "second", updated.Name)
+
+		_, err = db.Delete(app.URN(), "test")
+		assert.NoError(t, err)
+
+		_, err = db.Fetch(app.URN())
+		assert.True(t, folio.IsNotFound(err))
+	})
+}
+
+func TestFetch_Evict(t *testing.T) {
+	testCache(Options{Size: 2}, func(db *Storage, counter *counter) {
+		var apps []*App
+		for i := 0; i < 3; i++ {
+			app, err := folio.Create[*App](db, func(a *App) error { return nil }, "my_project", "test")
+			assert.NoError(t, err)
+			apps = append(apps, app)
+
+			_, err = db.Fetch(app.URN())
+			assert.NoError(t, err)
+		}
+
+		stats := db.Stats()
+		assert.Equal(t, uint64(1), stats.Evictions)
+		assert.Equal(t, 2, stats.Objects)
+
+		// The least recently used object was evicted
+		_, err := db.Fetch(apps[0].URN())
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(4), db.Stats().Misses)
+	})
+}
+
+func TestFetch_Expired(t *testing.T) {
+	testCache(Options{}, func(db *Storage, counter *counter) {
+		session, err := folio.Create[*Session](db, func(s *Session) error { return nil }, "my_project", "test")
+		assert.NoError(t, err)
+
+		_, err = db.Fetch(session.URN())
+		assert.NoError(t, err)
+
+		// The cached object is dropped once it expires
+		time.Sleep(60 * time.Millisecond)
+		_, err = db.Fetch(session.URN())
+		assert.True(t, folio.IsNotFound(err))
+		assert.Equal(t, uint64(0), db.Stats().Hits)
+	})
+}
+
+func TestSearch(t *testing.T) {
+	testCache(Options{QueryTTL: 50 * time.Millisecond}, func(db *Storage, counter *counter) {
+		for _, name := range []string{"a", "b"} {
+			_, err := folio.Create[*App](db, func(a *App) error {
+				a.Name = name
+				return nil
+			}, "my_project", "test")
+			assert.NoError(t, err)
+		}
+
+		search := func() []string {
+			found, err := folio.Search[*App](db, folio.Query{Namespace: "my_project", SortBy: []string{"name"}})
+			assert.NoError(t, err)
+
+			var names []string
+			for app := range found {
+				names = append(names, app.Name)
+			}
+			return names
+		}
+
+		// Query results are cached
+		assert.Equal(t, []string{"a", "b"}, search())
+		assert.Equal(t, []string{"a", "b"}, search())
+		assert.Equal(t, 1, counter.searches)
+
+		count, err := db.Count("app", folio.Query{})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+		_, err = db.Count("app", folio.Query{})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, counter.counts)
+
+		stats := db.Stats()
+		assert.Equal(t, uint64(2), stats.QueryHits)
+		assert.Equal(t, uint64(2), stats.QueryMisses)
+		assert.Equal(t, 2, stats.Queries)
+
+		// Writes invalidate the queries of the kind
+		_, err = folio.Create[*App](db, func(a *App) error {
+			a.Name = "c"
+			return nil
+		}, "my_project", "test")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a", "b", "c"}, search())
+		assert.Equal(t, 2, counter.searches)
+
+		// And the results are stale after the TTL
+		time.Sleep(60 * time.Millisecond)
+		assert.Equal(t, []string{"a", "b", "c"}, search())
+		assert.Equal(t, 3, counter.searches)
+	})
+}
+
+func TestSearch_Disabled(t *testing.T) {
+	testCache(Options{}, func(db *Storage, counter *counter) {
+		for i := 0; i < 2; i++ {
+			found, err := db.Search("app", folio.Query{})
+			assert.NoError(t, err)
+			assert.Empty(t, slices.Collect(found))
+		}
+
+		assert.Equal(t, 2, counter.searches)
+		assert.Equal(t, uint64(0), db.Stats().QueryMisses)
+	})
+}
+
+// ---------------------------------- Helpers ----------------------------------
+
+// counter counts the calls which reach the storage.
+type counter struct {
+	folio.Storage
+	fetches  int
+	searches int
+	counts   int
+}
+
+func (c *counter) Fetch(urn folio.URN) (folio.Object, error) {
+	c.fetches++
+	return c.Storage.Fetch(urn)
+}
+
+func (c *counter) Search(kind folio.Kind, q folio.Query) (iter.Seq[folio.Object], error) {
+	c.searches++
+	return c.Storage.Search(kind, q)
+}
+
+func (c *counter) Count(kind folio.Kind, q folio.Query) (int, error) {
+	c.counts++
+	return c.Storage.Count(kind, q)
+}
+
+func testCache(options Options, fn func(db *Storage, counter *counter)) {
+	registry := folio.NewRegistry()
+	folio.Register[*App](registry)
+	folio.Register[*Session](registry, folio.Options{TTL: 50 * time.Millisecond})
+
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	counter := &counter{Storage: db}
+	fn(New(counter, registry, options), counter)
+}
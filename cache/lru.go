@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+)
+
+// entry represents a cached value, keyed by its URN or query.
+type entry struct {
+	key     string
+	kind    string    // Kind of the value, for the invalidation
+	value   any       // Encoded object, list of objects or count
+	expires time.Time // Time after which the value is stale, zero if never
+}
+
+// lru represents a bounded set of entries, which evicts the least recently used one.
+type lru struct {
+	size  int
+	order *list.List // Most recently used first
+	items map[string]*list.Element
+}
+
+// newLRU creates a new set holding at most the specified number of entries.
+func newLRU(size int) *lru {
+	return &lru{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+// Get returns the entry and marks it as recently used, dropping it if it is stale.
+func (c *lru) Get(key string, now time.Time) (*entry, bool) {
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := elem.Value.(*entry)
+	if !e.expires.IsZero() && !now.Before(e.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return e, true
+}
+
+// Put adds or replaces the entry and returns true if another entry was evicted.
+func (c *lru) Put(e *entry) bool {
+	if elem, ok := c.items[e.key]; ok {
+		elem.Value = e
+		c.order.MoveToFront(elem)
+		return false
+	}
+
+	c.items[e.key] = c.order.PushFront(e)
+	if c.order.Len() <= c.size {
+		return false
+	}
+
+	c.remove(c.order.Back())
+	return true
+}
+
+// Remove removes the entry with the key, if present.
+func (c *lru) Remove(key string) {
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+}
+
+// RemoveKind removes every entry of the kind.
+func (c *lru) RemoveKind(kind string) {
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*entry).kind == kind {
+			c.remove(elem)
+		}
+		elem = next
+	}
+}
+
+// Len returns the number of entries.
+func (c *lru) Len() int {
+	return c.order.Len()
+}
+
+// remove removes the element from the list and the index.
+func (c *lru) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*entry).key)
+}
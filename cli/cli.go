@@ -0,0 +1,432 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/errors"
+	"github.com/kelindar/folio/sqlite"
+)
+
+const usage = `Usage: %s [flags] <command> [arguments]
+
+Commands:
+  kinds                       List the registered kinds
+  get <urn>                   Print an object
+  list <kind> [query]         List the objects of a kind, e.g. "namespace=company;filter=age:30"
+  create -f <file>            Create the objects of a JSON or YAML file
+  apply -f <file>             Create or update the objects of a JSON or YAML file
+  delete <urn>...             Delete the objects
+  export [kind...]            Write the objects as NDJSON, along with their metadata
+  import [-f file]            Restore the objects from NDJSON, e.g. "-conflict skip"
+
+Flags:
+`
+
+// Main runs the command-line tool with the arguments of the process and exits. Projects can
+// call it with their own registry to compile their types into their own binary.
+func Main(registry folio.Registry) {
+	if err := Run(registry, os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// Run runs the command-line tool with the arguments, against the types of the registry.
+func Run(registry folio.Registry, args []string, stdin io.Reader, stdout io.Writer) error {
+	name := "folio"
+	if len(os.Args) > 0 {
+		name = os.Args[0]
+	}
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	dsn := flags.String("db", envOr("FOLIO_DB", "file:data.db"), "SQLite data source name, or $FOLIO_DB")
+	user := flags.String("user", envOr("FOLIO_USER", "cli"), "Name recorded as the author of the changes, or $FOLIO_USER")
+	keyfile := flags.String("keyfile", os.Getenv("FOLIO_KEYFILE"), "File of the key encrypting the secret fields, or $FOLIO_KEYFILE")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), usage, name)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("command is required")
+	}
+
+	cmd := &command{
+		registry: registry,
+		user:     *user,
+		stdin:    stdin,
+		stdout:   stdout,
+	}
+
+	// Listing the kinds does not need the database
+	command, args := flags.Arg(0), flags.Args()[1:]
+	if command == "kinds" {
+		return cmd.kinds(args)
+	}
+
+	var opts []sqlite.Option
+	if *keyfile != "" {
+		opts = append(opts, sqlite.WithKeys(sqlite.KeyFile(*keyfile)))
+	}
+
+	db, err := sqlite.Open(*dsn, registry, opts...)
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+	cmd.db = db
+
+	switch command {
+	case "get":
+		return cmd.get(args)
+	case "list":
+		return cmd.list(args)
+	case "create":
+		return cmd.save(args, false)
+	case "apply":
+		return cmd.save(args, true)
+	case "delete":
+		return cmd.delete(args)
+	case "export":
+		return cmd.export(args)
+	case "import":
+		return cmd.load(args)
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown command '%s'", command)
+	}
+}
+
+// command represents the state shared by the commands.
+type command struct {
+	registry folio.Registry
+	db       folio.Storage
+	user     string
+	stdin    io.Reader
+	stdout   io.Writer
+}
+
+// kinds lists the registered kinds.
+func (c *command) kinds(args []string) error {
+	flags := flag.NewFlagSet("kinds", flag.ContinueOnError)
+	format := flags.String("o", "table", "Output format: table, json or yaml")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	types := slices.SortedFunc(c.registry.Types(), func(a, b folio.Type) int {
+		return strings.Compare(string(a.Kind), string(b.Kind))
+	})
+
+	if *format != "table" {
+		out := make([]map[string]any, 0, len(types))
+		for _, typ := range types {
+			out = append(out, map[string]any{
+				"kind":   typ.Kind,
+				"title":  typ.Title,
+				"plural": typ.Plural,
+				"type":   typ.Type.String(),
+			})
+		}
+		return write(c.stdout, *format, out)
+	}
+
+	rows := [][]string{{"KIND", "TITLE", "PLURAL", "TYPE"}}
+	for _, typ := range types {
+		rows = append(rows, []string{string(typ.Kind), typ.Title, typ.Plural, typ.Type.String()})
+	}
+	return writeTable(c.stdout, rows)
+}
+
+// get prints a single object.
+func (c *command) get(args []string) error {
+	flags := flag.NewFlagSet("get", flag.ContinueOnError)
+	format := flags.String("o", "json", "Output format: json or yaml")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("get: expected a single urn")
+	}
+
+	urn, err := folio.ParseURN(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	obj, err := c.db.Fetch(urn)
+	if err != nil {
+		return err
+	}
+
+	return write(c.stdout, *format, obj)
+}
+
+// list prints the objects of a kind, matching the query.
+func (c *command) list(args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	format := flags.String("o", "table", "Output format: table, json or yaml")
+	sort := flags.String("sort", "", "Comma-separated fields to sort by, prefixed by - for descending order")
+	offset := flags.Int("offset", 0, "Number of objects to skip")
+	limit := flags.Int("limit", 100, "Maximum number of objects to return")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		return fmt.Errorf("list: expected a kind and an optional query")
+	}
+
+	typ, err := c.registry.Resolve(folio.Kind(flags.Arg(0)))
+	if err != nil {
+		return err
+	}
+
+	query, err := folio.ParseQuery(flags.Arg(1), nil, folio.Query{
+		Offset: *offset,
+		Limit:  *limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	if *sort != "" {
+		query.SortBy = strings.Split(*sort, ",")
+	}
+
+	found, err := c.db.Search(typ.Kind, query)
+	if err != nil {
+		return err
+	}
+
+	objects := make([]folio.Object, 0, 16)
+	for obj := range found {
+		objects = append(objects, obj)
+	}
+
+	if *format != "table" {
+		return write(c.stdout, *format, objects)
+	}
+
+	rows := [][]string{{"URN", "TITLE", "STATE", "UPDATED"}}
+	for _, obj := range objects {
+		by, at := obj.Updated()
+		rows = append(rows, []string{obj.URN().String(), titleOf(obj), obj.Status(),
+			at.Format("2006-01-02 15:04") + " by " + by})
+	}
+	return writeTable(c.stdout, rows)
+}
+
+// save creates, or creates and updates, the objects of a file.
+func (c *command) save(args []string, upsert bool) error {
+	name := "create"
+	if upsert {
+		name = "apply"
+	}
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	file := flags.String("f", "", "JSON or YAML file to read the objects from, - for stdin")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *file == "" {
+		return fmt.Errorf("file is required")
+	}
+
+	objects, err := c.read(*file)
+	if err != nil {
+		return err
+	}
+
+	vd := errors.NewValidator()
+	for _, obj := range objects {
+		if err := validate(vd, obj); err != nil {
+			return err
+		}
+
+		action, saved := "created", folio.Object(nil)
+		switch {
+		case upsert:
+			var created bool
+			if saved, created, err = c.upsert(obj); err == nil && !created {
+				action = "updated"
+			}
+		default:
+			saved, err = c.db.Insert(obj, c.user)
+		}
+
+		if err != nil {
+			return fmt.Errorf("unable to save %s, %w", obj.URN(), err)
+		}
+
+		fmt.Fprintf(c.stdout, "%s %s\n", saved.URN(), action)
+	}
+	return nil
+}
+
+// delete deletes the objects.
+func (c *command) delete(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("delete: expected at least one urn")
+	}
+
+	for _, arg := range args {
+		urn, err := folio.ParseURN(arg)
+		if err != nil {
+			return err
+		}
+
+		if _, err := c.db.Delete(urn, c.user); err != nil {
+			return fmt.Errorf("unable to delete %s, %w", urn, err)
+		}
+
+		fmt.Fprintf(c.stdout, "%s deleted\n", urn)
+	}
+	return nil
+}
+
+// export writes the objects of the kinds, or of all kinds, as NDJSON.
+func (c *command) export(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	namespace := flags.String("ns", "", "Namespace to export, all by default")
+	filter := flags.String("q", "", "Query the objects must match, e.g. \"filter=age:30\"")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	kinds := make([]folio.Kind, 0, 8)
+	for _, arg := range flags.Args() {
+		typ, err := c.registry.Resolve(folio.Kind(arg))
+		if err != nil {
+			return err
+		}
+		kinds = append(kinds, typ.Kind)
+	}
+
+	query, err := folio.ParseQuery(*filter, nil, folio.Query{Namespace: *namespace})
+	if err != nil {
+		return err
+	}
+
+	_, err = folio.Export(c.stdout, c.db, c.registry, query, kinds...)
+	return err
+}
+
+// load creates or updates the objects from NDJSON, keeping their metadata.
+func (c *command) load(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	file := flags.String("f", "-", "NDJSON file to read the objects from, - for stdin")
+	conflict := flags.String("conflict", string(folio.ConflictFail), "Strategy for existing objects: fail, skip or overwrite")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	reader := c.stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+		reader = f
+	}
+
+	vd := errors.NewValidator()
+	result, err := folio.Import(reader, c.db, c.registry, folio.ImportOptions{
+		Conflict: folio.Conflict(*conflict),
+		By:       c.user,
+		Check: func(obj, _ folio.Object) error {
+			return validate(vd, obj)
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout, "%d created, %d updated, %d skipped\n", result.Created, result.Updated, result.Skipped)
+	return nil
+}
+
+// upsert creates the object, or updates it if it already exists. The version of the stored
+// object is taken over, so that the files do not need to carry it.
+func (c *command) upsert(obj folio.Object) (folio.Object, bool, error) {
+	current, err := c.db.Fetch(obj.URN())
+	switch {
+	case folio.IsNotFound(err):
+		created, err := c.db.Insert(obj, c.user)
+		return created, true, err
+	case err != nil:
+		return nil, false, err
+	}
+
+	dst := reflect.ValueOf(obj).Elem().FieldByName("Meta")
+	src := reflect.ValueOf(current).Elem().FieldByName("Meta")
+	for _, name := range []string{"CreatedBy", "CreatedAt", "UpdatedBy", "UpdatedAt"} {
+		dst.FieldByName(name).Set(src.FieldByName(name))
+	}
+	folio.KeepPasswords(obj, current)
+
+	updated, err := c.db.Update(obj, c.user)
+	return updated, false, err
+}
+
+// read reads the objects from the file, or from stdin.
+func (c *command) read(file string) ([]folio.Object, error) {
+	if file == "-" {
+		return readObjects(c.registry, c.stdin, "")
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	return readObjects(c.registry, f, file)
+}
+
+// validate validates the object and reports the validation errors, if any.
+func validate(vd errors.Validator, obj folio.Object) error {
+	validations, ok := vd.Validate(obj)
+	if ok {
+		return nil
+	}
+
+	messages := make([]string, 0, len(validations))
+	for _, v := range validations {
+		messages = append(messages, string(v.Path)+": "+v.Message)
+	}
+	return fmt.Errorf("invalid %s, %s", obj.URN(), strings.Join(messages, "; "))
+}
+
+// titleOf returns the title of the object.
+func titleOf(obj folio.Object) string {
+	if v, ok := obj.(interface{ Title() string }); ok {
+		return v.Title()
+	}
+	return ""
+}
+
+// envOr returns the environment variable, or the default value if it is not set.
+func envOr(name, value string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return value
+}
@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kelindar/folio"
+	"github.com/stretchr/testify/assert"
+)
+
+type Note struct {
+	folio.Meta `kind:"note" json:",inline"`
+	Name       string   `json:"name" form:"rw" is:"required"`
+	Tags       []string `json:"tags" form:"rw"`
+}
+
+func (n *Note) Title() string {
+	return n.Name
+}
+
+func TestRun(t *testing.T) {
+	registry := folio.NewRegistry()
+	folio.Register[*Note](registry)
+
+	dir := t.TempDir()
+	dsn := "file:" + filepath.Join(dir, "data.db")
+	run := func(stdin string, args ...string) (string, error) {
+		var out bytes.Buffer
+		err := Run(registry, append([]string{"-db", dsn, "-user", "alice"}, args...), strings.NewReader(stdin), &out)
+		return out.String(), err
+	}
+
+	// Kinds
+	out, err := run("", "kinds")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "note")
+	assert.Contains(t, out, "namespace")
+
+	// Create from YAML, with multiple documents
+	file := filepath.Join(dir, "notes.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte(`
+kind: note
+namespace: default
+name: First
+tags: [a, b]
+---
+kind: note
+namespace: default
+name: Second
+`), 0644))
+
+	out, err = run("", "create", "-f", file)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(out, " created\n"))
+	urn := strings.Fields(out)[0]
+
+	// Validation errors
+	_, err = run(`{"kind": "note", "namespace": "default"}`, "create", "-f", "-")
+	assert.ErrorContains(t, err, "name")
+
+	// Get
+	out, err = run("", "get", "-o", "yaml", urn)
+	assert.NoError(t, err)
+	assert.Contains(t, out, "name: First")
+	assert.Contains(t, out, "createdBy: alice")
+
+	// List with a query
+	out, err = run("", "list", "note", "namespace=default;filter=name:Second")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "Second")
+	assert.NotContains(t, out, "First")
+
+	// Apply updates the existing objects
+	out, err = run(`[{"kind": "note", "namespace": "default", "id": "`+strings.Split(urn, ":")[3]+`", "name": "Updated"}]`,
+		"apply", "-f", "-")
+	assert.NoError(t, err)
+	assert.Equal(t, urn+" updated\n", out)
+
+	// Export and import into another database
+	exported, err := run("", "export", "note")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(exported, "\n"))
+
+	dsn = "file:" + filepath.Join(dir, "other.db")
+	out, err = run(exported, "import")
+	assert.NoError(t, err)
+	assert.Equal(t, "2 created, 0 updated, 0 skipped\n", out)
+
+	_, err = run(exported, "import")
+	assert.True(t, folio.IsConflict(err))
+
+	out, err = run(exported, "import", "-conflict", "skip")
+	assert.NoError(t, err)
+	assert.Equal(t, "0 created, 0 updated, 2 skipped\n", out)
+
+	out, err = run("", "list", "-o", "json", "note")
+	assert.NoError(t, err)
+	assert.Contains(t, out, `"name": "Updated"`)
+	assert.Contains(t, out, `"createdBy": "alice"`)
+
+	// Delete
+	out, err = run("", "delete", urn)
+	assert.NoError(t, err)
+	assert.Equal(t, urn+" deleted\n", out)
+
+	_, err = run("", "get", urn)
+	assert.True(t, folio.IsNotFound(err))
+
+	// Unknown command
+	_, err = run("", "unknown")
+	assert.Error(t, err)
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kelindar/folio"
+	"gopkg.in/yaml.v3"
+)
+
+// readObjects reads the objects of a JSON, NDJSON or YAML document, which may contain a single
+// object, a list of objects or a stream of them. Objects without an identifier get a new one.
+func readObjects(registry folio.Registry, reader io.Reader, name string) ([]folio.Object, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []any
+	switch ext := strings.ToLower(filepath.Ext(name)); {
+	case ext == ".yaml" || ext == ".yml" || !isJSON(data):
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		for {
+			var v any
+			if err := decoder.Decode(&v); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("unable to decode yaml, %w", err)
+			}
+			values = append(values, v)
+		}
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		for {
+			var v any
+			if err := decoder.Decode(&v); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("unable to decode json, %w", err)
+			}
+			values = append(values, v)
+		}
+	}
+
+	out := make([]folio.Object, 0, len(values))
+	for _, value := range values {
+		items, ok := value.([]any)
+		if !ok {
+			items = []any{value}
+		}
+
+		for _, item := range items {
+			obj, err := objectOf(registry, item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, obj)
+		}
+	}
+	return out, nil
+}
+
+// objectOf decodes a generic value into an object of the registry.
+func objectOf(registry folio.Registry, value any) (folio.Object, error) {
+	m, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid object, expected a map but got %T", value)
+	}
+
+	kind, _ := m["kind"].(string)
+	namespace, _ := m["namespace"].(string)
+	if kind == "" || namespace == "" {
+		return nil, fmt.Errorf("invalid object, kind and namespace are required")
+	}
+
+	if id, _ := m["id"].(string); id == "" {
+		urn, err := folio.NewURN(namespace, folio.Kind(kind))
+		if err != nil {
+			return nil, err
+		}
+		m["id"] = urn.ID
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := folio.FromJSON(registry, data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s, %w", kind, err)
+	}
+	return obj, nil
+}
+
+// isJSON returns true if the data looks like a JSON document.
+func isJSON(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && (data[0] == '{' || data[0] == '[')
+}
+
+// write writes the value in the format, either JSON or YAML.
+func write(w io.Writer, format string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	case "yaml":
+		// Go through JSON first, so that the field names are the same
+		var generic any
+		if err := json.Unmarshal(data, &generic); err != nil {
+			return err
+		}
+
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		defer encoder.Close()
+		return encoder.Encode(generic)
+	default:
+		return fmt.Errorf("unknown output format '%s'", format)
+	}
+}
+
+// writeTable writes the rows as a table, the first row being the header.
+func writeTable(w io.Writer, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
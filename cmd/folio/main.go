@@ -0,0 +1,12 @@
+// Command folio manages the objects of a folio database from the command line. It only knows
+// about the built-in kinds; projects can compile their own registry into a binary with cli.Main.
+package main
+
+import (
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/cli"
+)
+
+func main() {
+	cli.Main(folio.NewRegistry())
+}
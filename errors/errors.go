@@ -54,3 +54,17 @@ func NotFound(format string, args ...any) error {
 		Status: http.StatusNotFound,
 	}
 }
+
+func Conflict(format string, args ...any) error {
+	return &Error{
+		error:  fmt.Errorf(format, args...),
+		Status: http.StatusConflict,
+	}
+}
+
+func PreconditionFailed(format string, args ...any) error {
+	return &Error{
+		error:  fmt.Errorf(format, args...),
+		Status: http.StatusPreconditionFailed,
+	}
+}
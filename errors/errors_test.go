@@ -12,6 +12,7 @@ func TestErrors(t *testing.T) {
 	assert.Equal(t, "401, err", Unauthorized("401, %v", "err").Error())
 	assert.Equal(t, "403, err", Forbidden("403, %v", "err").Error())
 	assert.Equal(t, "404, err", NotFound("404, %v", "err").Error())
+	assert.Equal(t, "409, err", Conflict("409, %v", "err").Error())
 	assert.Equal(t, "xxx, err", New("xxx, %v", "err").Error())
 }
 
@@ -1,6 +1,10 @@
 package errors
 
 import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/kelindar/folio"
@@ -12,10 +16,16 @@ type Validator interface {
 	Validate(value any) ([]Validation, bool)
 }
 
+// Validatable represents a value which validates itself, for example to check several of its
+// fields together. The paths of the validations are relative to the value.
+type Validatable interface {
+	Validate() []Validation
+}
+
 // Validation represents a result of a validation.
 type Validation struct {
-	Path    folio.Path
-	Message string
+	Path    folio.Path `json:"path"`
+	Message string     `json:"message"`
 }
 
 // String returns the string representation of the validation
@@ -30,15 +40,12 @@ func NewValidator() Validator {
 	return &validator{}
 }
 
-// Validate validates the given value
+// Validate validates the given value with its tags, then with the Validate method of the value
+// and of its nested structs.
 func (v *validator) Validate(value any) ([]Validation, bool) {
-	ok, err := validate.Struct(value)
-	if ok {
-		return nil, true
-	}
-
 	var out []Validation
-	if errs, ok := err.(validate.Errors); ok {
+	ok, err := validate.Struct(value)
+	if errs, isErrs := err.(validate.Errors); !ok && isErrs {
 		for _, err := range errs.Errors() {
 			path := err.Path
 			if len(path) == 0 {
@@ -51,5 +58,81 @@ func (v *validator) Validate(value any) ([]Validation, bool) {
 			})
 		}
 	}
-	return out, false
+
+	if value != nil {
+		out = validateNested(reflect.ValueOf(value), "", out)
+	}
+	return out, ok && len(out) == 0
+}
+
+// validateNested appends the validations of the value and of its nested structs, with their
+// paths prefixed by the path of the value. Elements of lists are identified by their index and
+// values of maps by their key, sorted so that the validations are reported in a stable order.
+func validateNested(rv reflect.Value, prefix folio.Path, out []Validation) []Validation {
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return out
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		// Values of maps are not addressable, so a copy is validated instead
+		if !rv.CanAddr() {
+			copied := reflect.New(rv.Type()).Elem()
+			copied.Set(rv)
+			rv = copied
+		}
+
+		target := rv.Addr()
+
+		if self, ok := target.Interface().(Validatable); ok {
+			for _, validation := range self.Validate() {
+				validation.Path = join(prefix, validation.Path)
+				out = append(out, validation)
+			}
+		}
+
+		for i := 0; i < rv.NumField(); i++ {
+			field := rv.Type().Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			switch {
+			case !field.IsExported() || name == "-":
+				continue
+			case field.Anonymous && name == "":
+				out = validateNested(rv.Field(i), prefix, out)
+			case name == "":
+				out = validateNested(rv.Field(i), join(prefix, folio.Path(field.Name)), out)
+			default:
+				out = validateNested(rv.Field(i), join(prefix, folio.Path(name)), out)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			out = validateNested(rv.Index(i), join(prefix, folio.Path(strconv.Itoa(i))), out)
+		}
+	case reflect.Map:
+		keys := rv.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			return strings.Compare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+		})
+
+		for _, key := range keys {
+			out = validateNested(rv.MapIndex(key), join(prefix, folio.Path(fmt.Sprint(key.Interface()))), out)
+		}
+	}
+	return out
+}
+
+// join joins the path to its parent path.
+func join(parent, path folio.Path) folio.Path {
+	switch {
+	case parent == "":
+		return path
+	case path == "":
+		return parent
+	default:
+		return parent + "." + path
+	}
 }
@@ -1,7 +1,9 @@
 package errors
 
 import (
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -25,3 +27,81 @@ func TestValidate(t *testing.T) {
 	assert.Equal(t, "height", errs[1].Path.String())
 
 }
+
+type Trip struct {
+	Name  string           `json:"name" is:"required"`
+	Start time.Time        `json:"start"`
+	End   time.Time        `json:"end"`
+	Legs  []Leg            `json:"legs"`
+	Cargo *Cargo           `json:"cargo"`
+	Stops map[string]*Leg  `json:"stops"`
+	Loads map[string]Cargo `json:"loads"`
+}
+
+func (t *Trip) Validate() []Validation {
+	if t.End.Before(t.Start) {
+		return []Validation{{Path: "end", Message: "end must be after start"}}
+	}
+	return nil
+}
+
+type Leg struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (l Leg) Validate() []Validation {
+	if l.From == l.To {
+		return []Validation{{Path: "to", Message: "to must differ from the origin"}}
+	}
+	return nil
+}
+
+type Cargo struct {
+	Usage     []string `json:"usage"`
+	Insurance string   `json:"insurance"`
+}
+
+func (c *Cargo) Validate() []Validation {
+	if slices.Contains(c.Usage, "commercial") && c.Insurance == "" {
+		return []Validation{{Path: "insurance", Message: "insurance is required for commercial use"}}
+	}
+	return nil
+}
+
+func TestValidate_Object(t *testing.T) {
+	vd := NewValidator()
+	now := time.Now()
+	trip := &Trip{
+		Start: now,
+		End:   now.Add(-time.Hour),
+		Legs:  []Leg{{From: "a", To: "b"}, {From: "b", To: "b"}},
+		Cargo: &Cargo{Usage: []string{"commercial"}},
+		Stops: map[string]*Leg{"lunch": {From: "c", To: "c"}, "dinner": {From: "d", To: "d"}, "night": {From: "d", To: "e"}},
+		Loads: map[string]Cargo{"pallet": {Usage: []string{"commercial"}}},
+	}
+
+	errs, ok := vd.Validate(trip)
+	assert.False(t, ok)
+	assert.Equal(t, []Validation{
+		{Path: "name", Message: "name is a required field"},
+		{Path: "end", Message: "end must be after start"},
+		{Path: "legs.1.to", Message: "to must differ from the origin"},
+		{Path: "cargo.insurance", Message: "insurance is required for commercial use"},
+		{Path: "stops.dinner.to", Message: "to must differ from the origin"},
+		{Path: "stops.lunch.to", Message: "to must differ from the origin"},
+		{Path: "loads.pallet.insurance", Message: "insurance is required for commercial use"},
+	}, errs)
+
+	// Valid once every rule is satisfied
+	trip.Name = "holiday"
+	trip.End = now.Add(time.Hour)
+	trip.Legs[1].To = "c"
+	trip.Cargo.Insurance = "full"
+	delete(trip.Stops, "lunch")
+	delete(trip.Stops, "dinner")
+	trip.Loads["pallet"] = Cargo{Usage: []string{"private"}}
+	errs, ok = vd.Validate(trip)
+	assert.True(t, ok)
+	assert.Empty(t, errs)
+}
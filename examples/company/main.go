@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/cache"
 	"github.com/kelindar/folio/render"
 	"github.com/kelindar/folio/sqlite"
 )
@@ -32,12 +33,13 @@ func main() {
 		Sort:   "3",
 	})
 
-	db, err := sqlite.Open("file:data.db?_journal_mode=WAL", reg)
+	db, err := sqlite.Open("file:data.db?_pragma=journal_mode(WAL)", reg)
 	if err != nil {
 		panic(err)
 	}
 
-	if err := render.ListenAndServe(7000, reg, db); err != nil {
+	// Cache the objects, since every rendered reference fetches one
+	if err := render.ListenAndServe(7000, reg, cache.New(db, reg)); err != nil {
 		slog.Error("Failed to start server!", "details", err.Error())
 		os.Exit(1)
 	}
@@ -2,9 +2,11 @@ package main
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/brianvoe/gofakeit/v7"
 	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/errors"
 )
 
 // ---------------------------------- Person ----------------------------------
@@ -130,3 +132,11 @@ func (c *Vehicle) Title() string {
 func (c *Vehicle) Subtitle() string {
 	return fmt.Sprintf("Manufactured in %v", c.Year)
 }
+
+// Validate checks the rules which span several fields of the vehicle.
+func (c *Vehicle) Validate() []errors.Validation {
+	if slices.Contains(c.Usage, "commercial") && c.Insurance == nil {
+		return []errors.Validation{{Path: "insurance", Message: "insurance is required for commercial use"}}
+	}
+	return nil
+}
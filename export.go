@@ -0,0 +1,221 @@
+package folio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/kelindar/folio/validate"
+)
+
+// Conflict represents the strategy used when an imported object already exists.
+type Conflict string
+
+const (
+	ConflictFail      Conflict = "fail"      // Stop the import with an error
+	ConflictSkip      Conflict = "skip"      // Keep the existing object
+	ConflictOverwrite Conflict = "overwrite" // Replace the existing object
+)
+
+// Restorer represents a storage which can write objects as-is, keeping their metadata. The
+// hooks of the objects must be run as for an insert or an update.
+type Restorer interface {
+	Restore(v Object) (Object, error)
+}
+
+// ImportOptions represents the options of an import.
+type ImportOptions struct {
+	Conflict Conflict // Strategy when an object already exists, fail by default
+	Kinds    []Kind   // Kinds which can be imported, all registered kinds if empty
+	By       string   // Name recorded for the objects without metadata
+
+	// Check is called for every object before it is written, along with the current object or
+	// nil if it does not exist yet. If nil, the objects are validated with their "is" tags.
+	Check func(v, current Object) error
+}
+
+// ImportResult represents the outcome of an import.
+type ImportResult struct {
+	Created int `json:"created"` // Number of objects created
+	Updated int `json:"updated"` // Number of objects overwritten
+	Skipped int `json:"skipped"` // Number of objects skipped, as they already exist
+}
+
+// Export writes the objects of the kinds matching the query as newline-delimited JSON, one
+// object per line along with its metadata. All registered kinds are exported if none are given.
+// The objects are written in their storage form, so that the hashes of the passwords are kept,
+// but without their secrets which are only readable through the UI.
+func Export(w io.Writer, db Storage, registry Registry, query Query, kinds ...Kind) (int, error) {
+	if len(kinds) == 0 {
+		for typ := range registry.Types() {
+			kinds = append(kinds, typ.Kind)
+		}
+	}
+
+	// Export in a stable order, so that exports can be compared
+	kinds = slices.Clone(kinds)
+	slices.Sort(kinds)
+
+	count := 0
+	for _, kind := range kinds {
+		found, err := db.Search(kind, query)
+		if err != nil {
+			return count, err
+		}
+
+		for obj := range found {
+			data, err := ToStorageJSON(withoutSecrets(obj))
+			if err != nil {
+				return count, err
+			}
+
+			if _, err := w.Write(append(data, '\n')); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// Import reads the objects from newline-delimited JSON and writes them into the storage. Every
+// object is checked first, and the import stops at the first invalid object or at the first
+// conflict when the strategy is to fail. The metadata is kept if the storage is a Restorer, and
+// the hooks of the objects are run either way.
+func Import(r io.Reader, db Storage, registry Registry, opts ImportOptions) (ImportResult, error) {
+	if opts.Conflict == "" {
+		opts.Conflict = ConflictFail
+	}
+
+	switch opts.Conflict {
+	case ConflictFail, ConflictSkip, ConflictOverwrite:
+	default:
+		return ImportResult{}, fmt.Errorf("import: invalid conflict strategy '%s'", opts.Conflict)
+	}
+
+	if opts.Check == nil {
+		opts.Check = checkStruct
+	}
+
+	var result ImportResult
+	decoder := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var data json.RawMessage
+		switch err := decoder.Decode(&data); {
+		case err == io.EOF:
+			return result, nil
+		case err != nil:
+			return result, fmt.Errorf("import: unable to decode object %d, %w", line, err)
+		}
+
+		obj, err := FromJSON(registry, data)
+		switch {
+		case err != nil:
+			return result, fmt.Errorf("import: unable to decode object %d, %w", line, err)
+		case len(opts.Kinds) > 0 && !slices.Contains(opts.Kinds, obj.URN().Kind):
+			return result, fmt.Errorf("import: unexpected kind '%s' of object %d", obj.URN().Kind, line)
+		}
+
+		// Resolve the conflict with the existing object, if any
+		current, err := db.Fetch(obj.URN())
+		exists := err == nil
+		switch {
+		case err != nil && !IsNotFound(err):
+			return result, err
+		case exists && opts.Conflict == ConflictFail:
+			return result, fmt.Errorf("import: %w, object %d (%s) already exists", ErrConflict, line, obj.URN())
+		case exists && opts.Conflict == ConflictSkip:
+			result.Skipped++
+			continue
+		case exists:
+			KeepPasswords(obj, current)
+			keepEmpty(obj, current, secretsOf)
+		}
+
+		if err := opts.Check(obj, current); err != nil {
+			return result, fmt.Errorf("import: invalid object %d (%s), %w", line, obj.URN(), err)
+		}
+
+		if _, err := Restore(db, withDefaults(obj, opts.By)); err != nil {
+			return result, fmt.Errorf("import: unable to write object %d (%s), %w", line, obj.URN(), err)
+		}
+
+		if exists {
+			result.Updated++
+		} else {
+			result.Created++
+		}
+	}
+}
+
+// Restore writes the object into the storage as-is, keeping its metadata if the storage is a
+// Restorer. Otherwise, the object is inserted or updated and the metadata set by the storage.
+func Restore(db Storage, v Object) (Object, error) {
+	if restorer, ok := db.(Restorer); ok {
+		return restorer.Restore(v)
+	}
+
+	current, err := db.Fetch(v.URN())
+	switch {
+	case IsNotFound(err):
+		by, _ := v.Created()
+		return db.Insert(v, by)
+	case err != nil:
+		return nil, err
+	}
+
+	// Take over the version of the current object, for the optimistic concurrency check
+	by, _ := v.Updated()
+	_, version := current.Updated()
+	reflect.ValueOf(v).Elem().FieldByName("UpdatedAt").SetInt(version.UnixNano())
+	return db.Update(v, by)
+}
+
+// withoutSecrets returns a copy of the object with its secrets left empty, or the object itself
+// if it has none.
+func withoutSecrets(v Object) Object {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct || len(secretsOf(rv.Elem().Type())) == 0 {
+		return v
+	}
+
+	out := reflect.New(rv.Elem().Type())
+	out.Elem().Set(rv.Elem())
+	for _, field := range secretsOf(rv.Elem().Type()) {
+		out.Elem().FieldByIndex(field.index).SetString("")
+	}
+	return out.Interface().(Object)
+}
+
+// checkStruct validates the object with its "is" tags.
+func checkStruct(v, _ Object) error {
+	if ok, err := validate.Struct(v); !ok {
+		return err
+	}
+	return nil
+}
+
+// withDefaults fills in the metadata missing from the object.
+func withDefaults(v Object, by string) Object {
+	if by == "" {
+		by = "sys"
+	}
+
+	rv := reflect.ValueOf(v).Elem()
+	now := time.Now().UnixNano()
+	for name, value := range map[string]any{
+		"CreatedBy": by,
+		"CreatedAt": now,
+		"UpdatedBy": by,
+		"UpdatedAt": now,
+	} {
+		if field := rv.FieldByName(name); field.IsZero() {
+			field.Set(reflect.ValueOf(value))
+		}
+	}
+	return v
+}
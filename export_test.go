@@ -0,0 +1,205 @@
+package folio_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+type Release struct {
+	folio.Meta `kind:"release" json:",inline"`
+	Version    string `json:"version" is:"required"`
+}
+
+func TestExport(t *testing.T) {
+	testStorage(func(db folio.Storage, registry folio.Registry) {
+		for _, ns := range []string{"project_a", "project_a", "project_b"} {
+			_, err := folio.Create[*App](db, func(*App) error { return nil }, ns, "alice")
+			assert.NoError(t, err)
+		}
+
+		// Export everything
+		var buffer bytes.Buffer
+		n, err := folio.Export(&buffer, db, registry, folio.Query{})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, n)
+		assert.Equal(t, n, strings.Count(buffer.String(), "\n"))
+		assert.Contains(t, buffer.String(), `"createdBy":"alice"`)
+
+		// Export only one kind and namespace
+		buffer.Reset()
+		n, err = folio.Export(&buffer, db, registry, folio.Query{Namespace: "project_a"}, "app")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+	})
+}
+
+func TestExport_Passwords(t *testing.T) {
+	registry := newRegistry()
+	src := sqlite.OpenEphemeral(registry)
+	defer src.Close()
+
+	_, err := folio.Bootstrap(src, "admin", "secret")
+	assert.NoError(t, err)
+
+	var buffer bytes.Buffer
+	_, err = folio.Export(&buffer, src, registry, folio.Query{}, "user")
+	assert.NoError(t, err)
+	exported := buffer.String()
+
+	// The hashes are kept when restoring into another database
+	dst := sqlite.OpenEphemeral(registry)
+	defer dst.Close()
+
+	_, err = folio.Import(strings.NewReader(exported), dst, registry, folio.ImportOptions{})
+	assert.NoError(t, err)
+	_, err = folio.Authenticate(dst, "admin", "secret")
+	assert.NoError(t, err)
+
+	// And when overwriting with a line without the password
+	admin, err := folio.FindUser(src, "admin")
+	assert.NoError(t, err)
+	stripped, err := json.Marshal(admin)
+	assert.NoError(t, err)
+
+	_, err = folio.Import(bytes.NewReader(stripped), src, registry, folio.ImportOptions{
+		Conflict: folio.ConflictOverwrite,
+	})
+	assert.NoError(t, err)
+	_, err = folio.Authenticate(src, "admin", "secret")
+	assert.NoError(t, err)
+}
+
+type Vault struct {
+	folio.Meta `kind:"vault" json:",inline"`
+	Name       string `json:"name"`
+	APIKey     string `json:"apiKey" secret:"true"`
+}
+
+func TestExport_Secrets(t *testing.T) {
+	registry := newRegistry()
+	folio.Register[*Vault](registry)
+	db := sqlite.OpenEphemeral(registry, sqlite.WithKeys(sqlite.KeyFunc(func() ([]byte, error) {
+		return bytes.Repeat([]byte{1}, 32), nil
+	})))
+	defer db.Close()
+
+	vault, err := folio.Create[*Vault](db, func(v *Vault) error {
+		v.Name = "production"
+		v.APIKey = "topsecretkey"
+		return nil
+	}, "default", "alice")
+	assert.NoError(t, err)
+
+	// The secrets are not exported
+	var buffer bytes.Buffer
+	_, err = folio.Export(&buffer, db, registry, folio.Query{}, "vault")
+	assert.NoError(t, err)
+	assert.Contains(t, buffer.String(), "production")
+	assert.NotContains(t, buffer.String(), "topsecretkey")
+
+	// Nor changed when overwriting the object with the export
+	_, err = folio.Import(&buffer, db, registry, folio.ImportOptions{
+		Conflict: folio.ConflictOverwrite,
+	})
+	assert.NoError(t, err)
+
+	current, err := folio.Fetch[*Vault](db, vault.URN())
+	assert.NoError(t, err)
+	assert.Equal(t, "topsecretkey", current.APIKey)
+}
+
+func TestImport(t *testing.T) {
+	registry := newRegistry()
+	folio.Register[*Release](registry)
+
+	src := sqlite.OpenEphemeral(registry)
+	defer src.Close()
+
+	release, err := folio.Create[*Release](src, func(r *Release) error {
+		r.Version = "1.0"
+		return nil
+	}, "my_project", "alice")
+	assert.NoError(t, err)
+
+	var buffer bytes.Buffer
+	_, err = folio.Export(&buffer, src, registry, folio.Query{}, "release")
+	assert.NoError(t, err)
+	exported := buffer.String()
+
+	dst := sqlite.OpenEphemeral(registry)
+	defer dst.Close()
+
+	// Import into an empty database, keeping the metadata
+	result, err := folio.Import(strings.NewReader(exported), dst, registry, folio.ImportOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, folio.ImportResult{Created: 1}, result)
+
+	imported, err := dst.Fetch(release.URN())
+	assert.NoError(t, err)
+	createdBy, createdAt := imported.Created()
+	_, expectedAt := release.Created()
+	assert.Equal(t, "alice", createdBy)
+	assert.Equal(t, expectedAt.UnixNano(), createdAt.UnixNano())
+
+	// Conflicts
+	_, err = folio.Import(strings.NewReader(exported), dst, registry, folio.ImportOptions{})
+	assert.True(t, folio.IsConflict(err))
+
+	result, err = folio.Import(strings.NewReader(exported), dst, registry, folio.ImportOptions{Conflict: folio.ConflictSkip})
+	assert.NoError(t, err)
+	assert.Equal(t, folio.ImportResult{Skipped: 1}, result)
+
+	result, err = folio.Import(strings.NewReader(exported), dst, registry, folio.ImportOptions{Conflict: folio.ConflictOverwrite})
+	assert.NoError(t, err)
+	assert.Equal(t, folio.ImportResult{Updated: 1}, result)
+
+	// Invalid objects are rejected
+	_, err = folio.Import(strings.NewReader(`{"kind":"release","namespace":"my_project","id":"cs2ojcbbvpprk6ou9gs0"}`),
+		dst, registry, folio.ImportOptions{})
+	assert.ErrorContains(t, err, "invalid object 1")
+
+	// Objects are checked along with the current version
+	_, err = folio.Import(strings.NewReader(exported), dst, registry, folio.ImportOptions{
+		Conflict: folio.ConflictOverwrite,
+		Check: func(v, current folio.Object) error {
+			assert.Equal(t, v.URN(), current.URN())
+			return fmt.Errorf("not allowed")
+		},
+	})
+	assert.ErrorContains(t, err, "not allowed")
+
+	// Only the allowed kinds can be imported
+	_, err = folio.Import(strings.NewReader(exported), dst, registry, folio.ImportOptions{
+		Conflict: folio.ConflictOverwrite,
+		Kinds:    []folio.Kind{"app"},
+	})
+	assert.ErrorContains(t, err, "unexpected kind")
+}
+
+func TestRestore_Secure(t *testing.T) {
+	testStorage(func(db folio.Storage, registry folio.Registry) {
+		app, err := folio.New[*App]("my_project")
+		assert.NoError(t, err)
+		app.CreatedBy, app.CreatedAt = "bob", 1
+		app.UpdatedBy, app.UpdatedAt = "bob", 2
+
+		policy := folio.NewPolicy(db)
+		viewer := folio.Secure(db, policy, &folio.Principal{Name: "eve", Role: folio.RoleViewer})
+		_, err = folio.Restore(viewer, app)
+		assert.True(t, folio.IsForbidden(err))
+
+		admin := folio.Secure(db, policy, &folio.Principal{Name: "root", Role: folio.RoleAdmin})
+		restored, err := folio.Restore(admin, app)
+		assert.NoError(t, err)
+		updatedBy, updatedAt := restored.Updated()
+		assert.Equal(t, "bob", updatedBy)
+		assert.Equal(t, int64(2), updatedAt.UnixNano())
+	})
+}
@@ -0,0 +1,96 @@
+package folio
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrRejected is returned when a hook of the object aborts a write.
+var ErrRejected = errors.New("storage: operation was rejected")
+
+// IsRejected returns true if the specified error is a rejection by a hook.
+func IsRejected(err error) bool {
+	return errors.Is(err, ErrRejected)
+}
+
+// HookContext represents the context of a write, passed to the hooks of the object.
+type HookContext struct {
+	Storage Storage // Storage performing the write, to look up other objects
+	By      string  // Name of the user performing the write
+}
+
+// BeforeInserter represents an object which is prepared or checked before it is inserted.
+type BeforeInserter interface {
+	BeforeInsert(ctx HookContext) error
+}
+
+// BeforeUpdater represents an object which is prepared or checked before it replaces the
+// current version, which is passed to the hook.
+type BeforeUpdater interface {
+	BeforeUpdate(ctx HookContext, old Object) error
+}
+
+// AfterSaver represents an object which is notified once it is inserted or updated.
+type AfterSaver interface {
+	AfterSave()
+}
+
+// BeforeDeleter represents an object which is checked before it is deleted. Expired objects
+// are purged by the storage without this check, since they can no longer be kept.
+type BeforeDeleter interface {
+	BeforeDelete() error
+}
+
+// ---------------------------------- Invoke ----------------------------------
+
+// Storage implementations must run the hooks on every write of an object, including the ones
+// restored from an import which run the hooks of an insert or an update. The only exception is
+// the purge of expired objects, which are reported by the storage instead.
+
+// BeforeInsert runs the insert hook of the object, if any. Storage implementations must call
+// it before inserting, so that the changes made by the hook are saved.
+func BeforeInsert(ctx HookContext, v Object) error {
+	if hook, ok := v.(BeforeInserter); ok {
+		return rejected(hook.BeforeInsert(ctx))
+	}
+	return nil
+}
+
+// BeforeUpdate runs the update hook of the object, if any. The current version is only
+// fetched if the object has a hook.
+func BeforeUpdate(ctx HookContext, v Object, current func() (Object, error)) error {
+	hook, ok := v.(BeforeUpdater)
+	if !ok {
+		return nil
+	}
+
+	old, err := current()
+	if err != nil {
+		return err
+	}
+
+	return rejected(hook.BeforeUpdate(ctx, old))
+}
+
+// AfterSave runs the save hook of the object, if any.
+func AfterSave(v Object) {
+	if hook, ok := v.(AfterSaver); ok {
+		hook.AfterSave()
+	}
+}
+
+// BeforeDelete runs the delete hook of the object, if any.
+func BeforeDelete(v Object) error {
+	if hook, ok := v.(BeforeDeleter); ok {
+		return rejected(hook.BeforeDelete())
+	}
+	return nil
+}
+
+// rejected wraps the error of a hook, if any.
+func rejected(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w, %w", ErrRejected, err)
+}
@@ -0,0 +1,142 @@
+package graphql
+
+import (
+	"fmt"
+)
+
+// Values returns the values of the variables of the operation, applying the defaults and
+// checking that the non-null variables are provided.
+func (op *Operation) Values(provided map[string]any) (map[string]any, error) {
+	out := make(map[string]any, len(op.Variables))
+	for _, v := range op.Variables {
+		value, ok := provided[v.Name]
+		switch {
+		case ok && value != nil:
+			out[v.Name] = value
+		case v.Default != nil:
+			out[v.Name] = Resolve(v.Default, nil)
+		case len(v.Type) > 0 && v.Type[len(v.Type)-1] == '!':
+			return nil, fmt.Errorf("variable '$%s' of type '%s' is required", v.Name, v.Type)
+		default:
+			out[v.Name] = nil
+		}
+	}
+	return out, nil
+}
+
+// Resolve replaces the variables in the value with their values, and converts the enums to
+// strings, so that the value only contains plain JSON-like types.
+func Resolve(value any, vars map[string]any) any {
+	switch v := value.(type) {
+	case Var:
+		return vars[string(v)]
+	case Enum:
+		return string(v)
+	case []any:
+		out := make([]any, 0, len(v))
+		for _, item := range v {
+			out = append(out, Resolve(item, vars))
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			out[key] = Resolve(item, vars)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// Args returns the arguments of the field, with the variables resolved.
+func (f *Field) Args(vars map[string]any) map[string]any {
+	out := make(map[string]any, len(f.Arguments))
+	for name, value := range f.Arguments {
+		out[name] = Resolve(value, vars)
+	}
+	return out
+}
+
+// Collect flattens the selection set into the list of fields to resolve for a type, expanding
+// the fragments and applying the @skip and @include directives. Fields with the same response
+// key are merged together.
+func (d *Document) Collect(selections []Selection, typename string, vars map[string]any) ([]*Field, error) {
+	var out []*Field
+	index := make(map[string]*Field)
+	visited := make(map[string]bool)
+
+	var collect func([]Selection) error
+	collect = func(selections []Selection) error {
+		for _, sel := range selections {
+			switch {
+			case sel.Field != nil:
+				if !isIncluded(sel.Field.Directives, vars) {
+					continue
+				}
+
+				key := sel.Field.Key()
+				if prev, ok := index[key]; ok {
+					if prev.Name != sel.Field.Name {
+						return fmt.Errorf("fields '%s' and '%s' conflict on key '%s'", prev.Name, sel.Field.Name, key)
+					}
+
+					merged := *prev
+					merged.Selections = append(append([]Selection{}, prev.Selections...), sel.Field.Selections...)
+					*index[key] = merged
+					continue
+				}
+
+				field := *sel.Field
+				index[key] = &field
+				out = append(out, &field)
+
+			case sel.Inline != nil:
+				if !isIncluded(sel.Inline.Directives, vars) || !matches(sel.Inline.On, typename) {
+					continue
+				}
+				if err := collect(sel.Inline.Selections); err != nil {
+					return err
+				}
+
+			case sel.Spread != "":
+				frag, ok := d.Fragments[sel.Spread]
+				switch {
+				case !ok:
+					return fmt.Errorf("unknown fragment '%s'", sel.Spread)
+				case visited[sel.Spread]:
+					continue // Already collected, or a cycle
+				case !isIncluded(sel.Directives, vars) || !isIncluded(frag.Directives, vars) || !matches(frag.On, typename):
+					continue
+				}
+
+				visited[sel.Spread] = true
+				if err := collect(frag.Selections); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	return out, collect(selections)
+}
+
+// matches returns true if the type condition applies to the type.
+func matches(on, typename string) bool {
+	return on == "" || typename == "" || on == typename
+}
+
+// isIncluded evaluates the @skip and @include directives.
+func isIncluded(directives []Directive, vars map[string]any) bool {
+	for _, d := range directives {
+		cond, _ := Resolve(d.Arguments["if"], vars).(bool)
+		switch {
+		case d.Name == "skip" && cond:
+			return false
+		case d.Name == "include" && !cond:
+			return false
+		}
+	}
+	return true
+}
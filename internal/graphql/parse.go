@@ -0,0 +1,743 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Document represents a parsed GraphQL request document.
+type Document struct {
+	Operations []*Operation         // Operations of the document
+	Fragments  map[string]*Fragment // Named fragments, by name
+}
+
+// Operation represents a query or a mutation.
+type Operation struct {
+	Type       string      // Type of the operation, "query" or "mutation"
+	Name       string      // Name of the operation, if any
+	Variables  []Variable  // Variables defined by the operation
+	Selections []Selection // Selection set of the operation
+}
+
+// Variable represents a variable definition of an operation.
+type Variable struct {
+	Name    string // Name of the variable, without the $ sign
+	Type    string // Type of the variable (e.g. "[String!]")
+	Default any    // Default value of the variable, if any
+}
+
+// Fragment represents a named fragment or an inline fragment.
+type Fragment struct {
+	Name       string      // Name of the fragment, empty for inline fragments
+	On         string      // Type condition, if any
+	Directives []Directive // Directives of the fragment
+	Selections []Selection // Selection set of the fragment
+}
+
+// Field represents a selected field.
+type Field struct {
+	Alias      string         // Alias of the field, if any
+	Name       string         // Name of the field
+	Arguments  map[string]any // Arguments of the field, possibly containing variables
+	Directives []Directive    // Directives of the field
+	Selections []Selection    // Selection set of the field, if any
+}
+
+// Key returns the key of the field in the response.
+func (f *Field) Key() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// Directive represents a directive, such as @skip or @include.
+type Directive struct {
+	Name      string
+	Arguments map[string]any
+}
+
+// Selection represents either a field, a fragment spread or an inline fragment.
+type Selection struct {
+	Field      *Field      // Selected field
+	Spread     string      // Name of the spread fragment
+	Inline     *Fragment   // Inline fragment
+	Directives []Directive // Directives of the fragment spread
+}
+
+// Var represents a reference to a variable in a value.
+type Var string
+
+// Enum represents an enum value.
+type Enum string
+
+// Operation returns the operation with the given name, or the only operation of the
+// document if the name is empty.
+func (d *Document) Operation(name string) (*Operation, error) {
+	switch {
+	case name == "" && len(d.Operations) == 1:
+		return d.Operations[0], nil
+	case name == "":
+		return nil, fmt.Errorf("operation name is required when the document has %d operations", len(d.Operations))
+	}
+
+	for _, op := range d.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation '%s'", name)
+}
+
+// ---------------------------------- Parser ----------------------------------
+
+// Parse parses an executable GraphQL document.
+func Parse(source string) (*Document, error) {
+	p := &parser{lexer: lexer{src: source}}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	doc := &Document{Fragments: make(map[string]*Fragment)}
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.tok.is("{"):
+			sel, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, &Operation{Type: "query", Selections: sel})
+		case p.tok.is("query"), p.tok.is("mutation"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+		case p.tok.is("fragment"):
+			frag, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			doc.Fragments[frag.Name] = frag
+		default:
+			return nil, p.unexpected()
+		}
+	}
+
+	if len(doc.Operations) == 0 {
+		return nil, fmt.Errorf("graphql: document does not contain any operation")
+	}
+
+	if err := doc.validate(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+type parser struct {
+	lexer lexer
+	tok   token
+}
+
+// next advances to the next token.
+func (p *parser) next() (err error) {
+	p.tok, err = p.lexer.next()
+	return
+}
+
+// expect consumes the punctuator or keyword, or fails.
+func (p *parser) expect(value string) error {
+	if !p.tok.is(value) {
+		return p.unexpected()
+	}
+	return p.next()
+}
+
+// skip consumes the punctuator if present.
+func (p *parser) skip(value string) (bool, error) {
+	if !p.tok.is(value) {
+		return false, nil
+	}
+	return true, p.next()
+}
+
+// name consumes a name.
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", p.unexpected()
+	}
+	name := p.tok.value
+	return name, p.next()
+}
+
+// unexpected returns an error for the current token.
+func (p *parser) unexpected() error {
+	if p.tok.kind == tokenEOF {
+		return fmt.Errorf("graphql: unexpected end of document")
+	}
+	return fmt.Errorf("graphql: unexpected '%s' at position %d", p.tok.value, p.tok.pos)
+}
+
+// operation parses an operation definition.
+func (p *parser) operation() (*Operation, error) {
+	op := &Operation{Type: p.tok.value}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind == tokenName {
+		op.Name, _ = p.name()
+	}
+
+	// Variable definitions
+	if ok, err := p.skip("("); err != nil {
+		return nil, err
+	} else if ok {
+		for !p.tok.is(")") {
+			v, err := p.variable()
+			if err != nil {
+				return nil, err
+			}
+			op.Variables = append(op.Variables, v)
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+
+	sel, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+
+	op.Selections = sel
+	return op, nil
+}
+
+// variable parses a variable definition, such as "$id: ID! = 1".
+func (p *parser) variable() (v Variable, err error) {
+	if err = p.expect("$"); err != nil {
+		return
+	}
+	if v.Name, err = p.name(); err != nil {
+		return
+	}
+	if err = p.expect(":"); err != nil {
+		return
+	}
+	if v.Type, err = p.typeRef(); err != nil {
+		return
+	}
+
+	if ok, err := p.skip("="); err != nil {
+		return v, err
+	} else if ok {
+		if v.Default, err = p.value(true); err != nil {
+			return v, err
+		}
+	}
+
+	_, err = p.directives()
+	return
+}
+
+// typeRef parses a type reference, such as "[String!]!".
+func (p *parser) typeRef() (string, error) {
+	var out string
+	switch {
+	case p.tok.is("["):
+		if err := p.next(); err != nil {
+			return "", err
+		}
+		inner, err := p.typeRef()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect("]"); err != nil {
+			return "", err
+		}
+		out = "[" + inner + "]"
+	default:
+		name, err := p.name()
+		if err != nil {
+			return "", err
+		}
+		out = name
+	}
+
+	if ok, err := p.skip("!"); err != nil {
+		return "", err
+	} else if ok {
+		out += "!"
+	}
+	return out, nil
+}
+
+// fragment parses a named fragment definition.
+func (p *parser) fragment() (*Fragment, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.tok.is("on") {
+		return nil, p.unexpected()
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	on, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+
+	directives, err := p.directives()
+	if err != nil {
+		return nil, err
+	}
+
+	sel, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Fragment{Name: name, On: on, Directives: directives, Selections: sel}, nil
+}
+
+// selectionSet parses a selection set, enclosed in braces.
+func (p *parser) selectionSet() ([]Selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	var out []Selection
+	for !p.tok.is("}") {
+		sel, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sel)
+	}
+
+	if len(out) == 0 {
+		return nil, fmt.Errorf("graphql: empty selection set at position %d", p.tok.pos)
+	}
+	return out, p.next()
+}
+
+// selection parses a field, a fragment spread or an inline fragment.
+func (p *parser) selection() (Selection, error) {
+	if ok, err := p.skip("..."); err != nil || !ok {
+		if err != nil {
+			return Selection{}, err
+		}
+
+		field, err := p.field()
+		return Selection{Field: field}, err
+	}
+
+	// Fragment spread, such as "...PersonFields"
+	if p.tok.kind == tokenName && !p.tok.is("on") {
+		name, _ := p.name()
+		directives, err := p.directives()
+		if err != nil {
+			return Selection{}, err
+		}
+
+		return Selection{Spread: name, Directives: directives}, nil
+	}
+
+	// Inline fragment, such as "... on Person { name }"
+	frag := new(Fragment)
+	if p.tok.is("on") {
+		if err := p.next(); err != nil {
+			return Selection{}, err
+		}
+
+		on, err := p.name()
+		if err != nil {
+			return Selection{}, err
+		}
+		frag.On = on
+	}
+
+	var err error
+	if frag.Directives, err = p.directives(); err != nil {
+		return Selection{}, err
+	}
+	if frag.Selections, err = p.selectionSet(); err != nil {
+		return Selection{}, err
+	}
+	return Selection{Inline: frag}, nil
+}
+
+// field parses a field, along with its alias, arguments and selection set.
+func (p *parser) field() (*Field, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+
+	field := &Field{Name: name}
+	if ok, err := p.skip(":"); err != nil {
+		return nil, err
+	} else if ok {
+		field.Alias = name
+		if field.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+
+	if field.Arguments, err = p.arguments(); err != nil {
+		return nil, err
+	}
+	if field.Directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.is("{") {
+		if field.Selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return field, nil
+}
+
+// arguments parses the optional arguments, enclosed in parentheses.
+func (p *parser) arguments() (map[string]any, error) {
+	if ok, err := p.skip("("); err != nil || !ok {
+		return nil, err
+	}
+
+	out := make(map[string]any)
+	for !p.tok.is(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if out[name], err = p.value(false); err != nil {
+			return nil, err
+		}
+	}
+	return out, p.next()
+}
+
+// directives parses the optional directives, such as "@include(if: $flag)".
+func (p *parser) directives() ([]Directive, error) {
+	var out []Directive
+	for p.tok.is("@") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+
+		args, err := p.arguments()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Directive{Name: name, Arguments: args})
+	}
+	return out, nil
+}
+
+// value parses a value. Variables are not allowed in constant values.
+func (p *parser) value(constant bool) (any, error) {
+	tok := p.tok
+	switch {
+	case tok.is("$") && !constant:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		return Var(name), err
+	case tok.is("["):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		out := make([]any, 0, 4)
+		for !p.tok.is("]") {
+			v, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, p.next()
+	case tok.is("{"):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		out := make(map[string]any)
+		for !p.tok.is("}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if out[name], err = p.value(constant); err != nil {
+				return nil, err
+			}
+		}
+		return out, p.next()
+	case tok.kind == tokenString:
+		return tok.value, p.next()
+	case tok.kind == tokenInt:
+		v, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("graphql: invalid integer '%s'", tok.value)
+		}
+		return v, p.next()
+	case tok.kind == tokenFloat:
+		v, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("graphql: invalid float '%s'", tok.value)
+		}
+		return v, p.next()
+	case tok.kind == tokenName:
+		switch tok.value {
+		case "true":
+			return true, p.next()
+		case "false":
+			return false, p.next()
+		case "null":
+			return nil, p.next()
+		default:
+			return Enum(tok.value), p.next()
+		}
+	default:
+		return nil, p.unexpected()
+	}
+}
+
+// ---------------------------------- Lexer ----------------------------------
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// is returns true if the token is the punctuator or the name.
+func (t token) is(value string) bool {
+	return (t.kind == tokenPunct || t.kind == tokenName) && t.value == value
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+// next returns the next token, skipping whitespace, commas and comments.
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; {
+		case c == ' ', c == '\t', c == '\n', c == '\r', c == ',':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "\uFEFF"):
+			l.pos += 3
+		default:
+			return l.token()
+		}
+	}
+
+	return token{kind: tokenEOF, pos: l.pos}, nil
+}
+
+// token reads the token at the current position.
+func (l *lexer) token() (token, error) {
+	start := l.pos
+	switch c := l.src[l.pos]; {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		return token{kind: tokenPunct, value: "...", pos: start}, nil
+	case strings.IndexByte("!$&()[]{}:=@|", c) >= 0:
+		l.pos++
+		return token{kind: tokenPunct, value: string(c), pos: start}, nil
+	case isNameStart(c):
+		for l.pos < len(l.src) && (isNameStart(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokenName, value: l.src[start:l.pos], pos: start}, nil
+	case c == '-' || isDigit(c):
+		return l.number()
+	case strings.HasPrefix(l.src[l.pos:], `"""`):
+		return l.blockString()
+	case c == '"':
+		return l.string()
+	default:
+		return token{}, fmt.Errorf("graphql: unexpected character '%c' at position %d", c, start)
+	}
+}
+
+// number reads an integer or a float.
+func (l *lexer) number() (token, error) {
+	start, kind := l.pos, tokenInt
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+
+	digits := func() {
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+		}
+	}
+
+	digits()
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		kind = tokenFloat
+		l.pos++
+		digits()
+	}
+
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		kind = tokenFloat
+		l.pos++
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		digits()
+	}
+
+	value := l.src[start:l.pos]
+	if value == "-" || strings.HasSuffix(value, ".") {
+		return token{}, fmt.Errorf("graphql: invalid number '%s' at position %d", value, start)
+	}
+	return token{kind: kind, value: value, pos: start}, nil
+}
+
+// string reads a quoted string, unescaping it.
+func (l *lexer) string() (token, error) {
+	start := l.pos
+	l.pos++
+
+	var sb strings.Builder
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; c {
+		case '"':
+			l.pos++
+			return token{kind: tokenString, value: sb.String(), pos: start}, nil
+		case '\n', '\r':
+			return token{}, fmt.Errorf("graphql: unterminated string at position %d", start)
+		case '\\':
+			if l.pos+1 >= len(l.src) {
+				return token{}, fmt.Errorf("graphql: unterminated string at position %d", start)
+			}
+
+			switch e := l.src[l.pos+1]; e {
+			case '"', '\\', '/':
+				sb.WriteByte(e)
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'u':
+				if l.pos+6 > len(l.src) {
+					return token{}, fmt.Errorf("graphql: invalid escape at position %d", l.pos)
+				}
+				r, err := strconv.ParseUint(l.src[l.pos+2:l.pos+6], 16, 32)
+				if err != nil {
+					return token{}, fmt.Errorf("graphql: invalid escape at position %d", l.pos)
+				}
+				sb.WriteRune(rune(r))
+				l.pos += 4
+			default:
+				return token{}, fmt.Errorf("graphql: invalid escape at position %d", l.pos)
+			}
+			l.pos += 2
+		default:
+			sb.WriteByte(c)
+			l.pos++
+		}
+	}
+
+	return token{}, fmt.Errorf("graphql: unterminated string at position %d", start)
+}
+
+// blockString reads a triple-quoted string, removing the common indentation.
+func (l *lexer) blockString() (token, error) {
+	start := l.pos
+	end := strings.Index(l.src[l.pos+3:], `"""`)
+	if end < 0 {
+		return token{}, fmt.Errorf("graphql: unterminated string at position %d", start)
+	}
+
+	raw := l.src[l.pos+3 : l.pos+3+end]
+	l.pos += end + 6
+
+	// Remove the common indentation of all lines but the first one
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if n := len(line) - len(trimmed); trimmed != "" && (indent < 0 || n < indent) {
+			indent = n
+		}
+	}
+
+	for i := 1; i < len(lines) && indent > 0; i++ {
+		if len(lines[i]) >= indent {
+			lines[i] = lines[i][indent:]
+		}
+	}
+
+	// Remove the leading and trailing blank lines
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	value := strings.Join(lines, "\n")
+	return token{kind: tokenString, value: value, pos: start}, nil
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,123 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	doc, err := Parse(`
+		# Fetch a vehicle with its owners
+		query Vehicle($urn: ID!, $full: Boolean = false) {
+			car: vehicle(urn: $urn) {
+				model
+				owners(limit: 10, sort: ["-name"], filter: {field: "age", values: ["30"]}) {
+					...owner
+					... on Person @include(if: $full) { phone }
+				}
+			}
+		}
+
+		fragment owner on Person {
+			name
+			note: description @skip(if: true)
+		}`)
+	assert.NoError(t, err)
+
+	op, err := doc.Operation("")
+	assert.NoError(t, err)
+	assert.Equal(t, "query", op.Type)
+	assert.Equal(t, "Vehicle", op.Name)
+	assert.Equal(t, []Variable{
+		{Name: "urn", Type: "ID!"},
+		{Name: "full", Type: "Boolean", Default: false},
+	}, op.Variables)
+
+	vars, err := op.Values(map[string]any{"urn": "urn:default:vehicle:1"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"urn": "urn:default:vehicle:1", "full": false}, vars)
+
+	// Required variables must be provided
+	_, err = op.Values(nil)
+	assert.Error(t, err)
+
+	car := op.Selections[0].Field
+	assert.Equal(t, "car", car.Key())
+	assert.Equal(t, map[string]any{"urn": "urn:default:vehicle:1"}, car.Args(vars))
+
+	owners := car.Selections[1].Field
+	assert.Equal(t, map[string]any{
+		"limit":  int64(10),
+		"sort":   []any{"-name"},
+		"filter": map[string]any{"field": "age", "values": []any{"30"}},
+	}, owners.Args(vars))
+
+	// Fragments are expanded, and the directives applied
+	fields, err := doc.Collect(owners.Selections, "Person", vars)
+	assert.NoError(t, err)
+	assert.Len(t, fields, 1)
+	assert.Equal(t, "name", fields[0].Name)
+
+	vars["full"] = true
+	fields, err = doc.Collect(owners.Selections, "Person", vars)
+	assert.NoError(t, err)
+	assert.Len(t, fields, 2)
+	assert.Equal(t, "phone", fields[1].Name)
+}
+
+func TestParse_Values(t *testing.T) {
+	doc, err := Parse(`{ f(a: -1.5e2, b: "line\n\"quoted\" é", c: null, d: ENUM, e: """
+		block
+		  indented
+	""") }`)
+	assert.NoError(t, err)
+
+	field := doc.Operations[0].Selections[0].Field
+	assert.Equal(t, map[string]any{
+		"a": -150.0,
+		"b": "line\n\"quoted\" é",
+		"c": nil,
+		"d": "ENUM",
+		"e": "block\n  indented",
+	}, field.Args(nil))
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, query := range []string{
+		``,
+		`{ }`,
+		`{ a`,
+		`{ a(b: ) }`,
+		`{ a(b: "unterminated) }`,
+		`query ($a: ) { b }`,
+		`fragment f { a }`,
+		`subscription { a }`,
+		`{ a } ?`,
+	} {
+		_, err := Parse(query)
+		assert.Error(t, err, query)
+	}
+}
+
+func TestCollect_Merge(t *testing.T) {
+	doc, err := Parse(`{ a { b } a { c } x: a { d } }`)
+	assert.NoError(t, err)
+
+	fields, err := doc.Collect(doc.Operations[0].Selections, "Query", nil)
+	assert.NoError(t, err)
+	assert.Len(t, fields, 2)
+	assert.Len(t, fields[0].Selections, 2)
+
+	// Different fields with the same key conflict
+	doc, err = Parse(`{ a: b a: c }`)
+	assert.NoError(t, err)
+	_, err = doc.Collect(doc.Operations[0].Selections, "Query", nil)
+	assert.Error(t, err)
+
+	// Unknown fragments
+	doc, err = Parse(`{ ...missing }`)
+	assert.NoError(t, err)
+	_, err = doc.Collect(doc.Operations[0].Selections, "Query", nil)
+	assert.Error(t, err)
+}
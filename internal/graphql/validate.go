@@ -0,0 +1,102 @@
+package graphql
+
+import (
+	"fmt"
+)
+
+const (
+	MaxDepth      = 15   // Maximum depth of the selections of an operation
+	MaxComplexity = 1000 // Maximum number of fields selected by an operation, with its fragments expanded
+)
+
+// validate rejects the documents with fragments which spread themselves, directly or through
+// other fragments, and the operations which are too deep or select too many fields once their
+// fragments are expanded, so that a request can not recurse without end.
+func (d *Document) validate() error {
+	for name := range d.Fragments {
+		if err := d.checkCycle(name, make(map[string]bool)); err != nil {
+			return err
+		}
+	}
+
+	costs := make(map[string]cost)
+	for _, op := range d.Operations {
+		c := d.costOf(op.Selections, costs)
+		switch {
+		case c.depth > MaxDepth:
+			return fmt.Errorf("graphql: operation exceeds the maximum depth of %d", MaxDepth)
+		case c.fields > MaxComplexity:
+			return fmt.Errorf("graphql: operation exceeds the maximum of %d selected fields", MaxComplexity)
+		}
+	}
+	return nil
+}
+
+// checkCycle returns an error if the fragment spreads one of the fragments on the path.
+func (d *Document) checkCycle(name string, path map[string]bool) error {
+	frag, ok := d.Fragments[name]
+	switch {
+	case !ok:
+		return nil // Reported when the selections are collected
+	case path[name]:
+		return fmt.Errorf("graphql: fragment '%s' spreads itself", name)
+	}
+
+	path[name] = true
+	defer delete(path, name)
+	for _, spread := range spreadsOf(frag.Selections, nil) {
+		if err := d.checkCycle(spread, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// spreadsOf appends the names of the fragments spread within the selections, at any depth.
+func spreadsOf(selections []Selection, out []string) []string {
+	for _, sel := range selections {
+		switch {
+		case sel.Field != nil:
+			out = spreadsOf(sel.Field.Selections, out)
+		case sel.Inline != nil:
+			out = spreadsOf(sel.Inline.Selections, out)
+		case sel.Spread != "":
+			out = append(out, sel.Spread)
+		}
+	}
+	return out
+}
+
+// cost represents the depth and number of fields of a selection set.
+type cost struct {
+	depth  int
+	fields int
+}
+
+// costOf returns the cost of the selections with their fragments expanded, regardless of their
+// directives. The costs of the fragments are cached, since they may be spread many times.
+func (d *Document) costOf(selections []Selection, fragments map[string]cost) cost {
+	var out cost
+	add := func(c cost) {
+		out.depth = max(out.depth, c.depth)
+		out.fields = min(out.fields+c.fields, MaxComplexity+1)
+	}
+
+	for _, sel := range selections {
+		switch {
+		case sel.Field != nil:
+			sub := d.costOf(sel.Field.Selections, fragments)
+			add(cost{depth: sub.depth + 1, fields: sub.fields + 1})
+		case sel.Inline != nil:
+			add(d.costOf(sel.Inline.Selections, fragments))
+		case sel.Spread != "":
+			c, ok := fragments[sel.Spread]
+			if frag, found := d.Fragments[sel.Spread]; !ok && found {
+				c = d.costOf(frag.Selections, fragments)
+				fragments[sel.Spread] = c
+			}
+			add(c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package graphql
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate_Cycles(t *testing.T) {
+	for _, query := range []string{
+		`{ a { ...F } } fragment F on Person { name ...F }`,
+		`{ a { ...F } } fragment F on Person { boss { ...F } }`,
+		`{ a { ...F } } fragment F on Person { ...G } fragment G on Person { ... on Person { boss { ...F } } }`,
+	} {
+		_, err := Parse(query)
+		assert.ErrorContains(t, err, "spreads itself", query)
+	}
+
+	// The same fragment can be spread more than once
+	_, err := Parse(`{ a { ...F boss { ...F } } } fragment F on Person { name }`)
+	assert.NoError(t, err)
+}
+
+func TestValidate_Depth(t *testing.T) {
+	query := func(depth int) string {
+		return strings.Repeat("{ a ", depth) + strings.Repeat("}", depth)
+	}
+
+	_, err := Parse(query(MaxDepth))
+	assert.NoError(t, err)
+
+	_, err = Parse(query(MaxDepth + 1))
+	assert.ErrorContains(t, err, "maximum depth")
+
+	// Fragments count towards the depth where they are spread
+	_, err = Parse(query(MaxDepth-1) + ` fragment F on Person { a { b } }`)
+	assert.NoError(t, err)
+	_, err = Parse(`{ a { ...F } } fragment F on Person { a ` + query(MaxDepth-1) + ` }`)
+	assert.ErrorContains(t, err, "maximum depth")
+}
+
+func TestValidate_Complexity(t *testing.T) {
+	fields := make([]string, MaxComplexity+1)
+	for i := range fields {
+		fields[i] = fmt.Sprintf("f%d", i)
+	}
+
+	_, err := Parse(`{ ` + strings.Join(fields[:MaxComplexity], " ") + ` }`)
+	assert.NoError(t, err)
+
+	_, err = Parse(`{ ` + strings.Join(fields, " ") + ` }`)
+	assert.ErrorContains(t, err, "maximum of 1000 selected fields")
+
+	// Fragments which spread others twice select exponentially many fields
+	var query strings.Builder
+	query.WriteString(`{ ...F0 }`)
+	for i := 0; i < 12; i++ {
+		fmt.Fprintf(&query, ` fragment F%d on Q { a { ...F%d } b { ...F%d } }`, i, i+1, i+1)
+	}
+	query.WriteString(` fragment F12 on Q { c }`)
+
+	_, err = Parse(query.String())
+	assert.ErrorContains(t, err, "selected fields")
+}
@@ -1,6 +1,7 @@
 package folio
 
 import (
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -148,6 +149,39 @@ func ToJSON(v Object) ([]byte, error) {
 	return json.Marshal(v)
 }
 
+// ToStorageJSON converts the object to JSON for the storage. Unlike ToJSON, the hashes of the
+// passwords are kept, as they are never encoded otherwise.
+func ToStorageJSON(v Object) ([]byte, error) {
+	data, err := json.Marshal(v)
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if err != nil || rv.Kind() != reflect.Struct || len(passwordsOf(rv.Type())) == 0 {
+		return data, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc map[string]any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	for _, field := range passwordsOf(rv.Type()) {
+		parent := doc
+		for _, name := range field.path[:len(field.path)-1] {
+			if parent, _ = parent[name].(map[string]any); parent == nil {
+				break
+			}
+		}
+
+		if fv, err := rv.FieldByIndexErr(field.index); err == nil && parent != nil {
+			parent[field.path[len(field.path)-1]] = fv.String()
+		}
+	}
+
+	return json.Marshal(doc)
+}
+
 // FromJSON parses a JSON file and returns a resource
 func FromJSON(c Registry, data []byte) (Object, error) {
 	var res Meta
@@ -1,5 +1,21 @@
 package folio
 
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
 // RegisterBuiltins registers the built-in types into the registry.
 func registerBuiltins(r Registry) {
 	Register[*Namespace](r, Options{
@@ -7,16 +23,27 @@ func registerBuiltins(r Registry) {
 		Title:  "Namespace",
 		Plural: "Namespaces",
 	})
+	Register[*User](r, Options{
+		Icon:   "user",
+		Title:  "User",
+		Plural: "Users",
+	})
+	Register[*Token](r, Options{
+		Icon:   "key-round",
+		Title:  "Token",
+		Plural: "Tokens",
+	})
 }
 
 // ---------------------------------- Namespace ----------------------------------
 
 // Namespace represents a namespace in the system.
 type Namespace struct {
-	Meta  `kind:"namespace" json:",inline"`
-	Name  string `json:"name" form:"rw" is:"required,lowercase,alphanum,minlen(2),maxlen(25)"`
-	Label string `json:"label" form:"rw" is:"required,minlen(2),maxlen(50)"`
-	Desc  string `json:"desc" form:"rw" is:"maxlen(255)"`
+	Meta    `kind:"namespace" json:",inline"`
+	Name    string   `json:"name" form:"rw" is:"required,lowercase,alphanum,minlen(2),maxlen(25)"`
+	Label   string   `json:"label" form:"rw" is:"required,minlen(2),maxlen(50)"`
+	Desc    string   `json:"desc" form:"rw" is:"maxlen(255)"`
+	Members []Member `json:"members,omitempty" form:"rw"`
 }
 
 func (n *Namespace) Title() string {
@@ -26,3 +53,320 @@ func (n *Namespace) Title() string {
 func (n *Namespace) Subtitle() string {
 	return n.Desc
 }
+
+// ---------------------------------- User ----------------------------------
+
+// User represents a user account which can sign in with a password.
+type User struct {
+	Meta     `kind:"user" json:",inline"`
+	Name     string   `json:"name" form:"rw@admin,ro" is:"required,lowercase,alphanum,minlen(2),maxlen(25)"`
+	Email    string   `json:"email" form:"rw@admin,ro" is:"email"`
+	Role     Role     `json:"role" form:"rw@admin,ro" is:"in(viewer|editor|admin)"`
+	Groups   []string `json:"groups" form:"rw@admin,ro"`
+	Password Password `json:"password" form:"rw@admin" desc:"Leave empty to keep the current password"`
+}
+
+func (u *User) Title() string {
+	return u.Name
+}
+
+func (u *User) Subtitle() string {
+	return u.Email
+}
+
+// BeforeInsert makes sure the name of the user is unique, as users sign in with it.
+func (u *User) BeforeInsert(ctx HookContext) error {
+	return u.checkName(ctx.Storage)
+}
+
+// BeforeUpdate makes sure that a renamed user does not take the name of another one.
+func (u *User) BeforeUpdate(ctx HookContext, old Object) error {
+	if prev, ok := old.(*User); ok && prev.Name == u.Name {
+		return nil
+	}
+	return u.checkName(ctx.Storage)
+}
+
+// checkName returns an error if another user has the same name.
+func (u *User) checkName(db Storage) error {
+	other, err := FindUser(db, u.Name)
+	switch {
+	case IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	case other.ID != u.ID:
+		return fmt.Errorf("user '%s' already exists", u.Name)
+	default:
+		return nil
+	}
+}
+
+// Principal returns the principal represented by the user.
+func (u *User) Principal() *Principal {
+	return &Principal{
+		Name:   u.Name,
+		Groups: u.Groups,
+		Role:   u.Role,
+	}
+}
+
+// ---------------------------------- Password ----------------------------------
+
+const (
+	passwordScheme     = "pbkdf2-sha256"
+	passwordIterations = 600000
+)
+
+// Password represents a hashed password. Plain text passwords are hashed as soon as they
+// are decoded, so that they are never stored.
+type Password string
+
+// NewPassword hashes the plain text password with a random salt.
+func NewPassword(plain string) (Password, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key, err := pbkdf2.Key(sha256.New, plain, salt, passwordIterations, 32)
+	if err != nil {
+		return "", err
+	}
+
+	return Password(fmt.Sprintf("%s$%d$%s$%s", passwordScheme, passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)), nil
+}
+
+// Verify returns true if the plain text password matches the hashed one.
+func (p Password) Verify(plain string) bool {
+	parts := strings.Split(string(p), "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return false
+	}
+
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+
+	expect, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+
+	actual, err := pbkdf2.Key(sha256.New, plain, salt, iterations, len(expect))
+	return err == nil && subtle.ConstantTimeCompare(actual, expect) == 1
+}
+
+// IsHashed returns true if the password is hashed.
+func (p Password) IsHashed() bool {
+	return strings.HasPrefix(string(p), passwordScheme+"$")
+}
+
+// MarshalJSON encodes the password as an empty string, so that its hash never leaves the
+// storage. Use ToStorageJSON to keep it.
+func (p Password) MarshalJSON() ([]byte, error) {
+	return []byte(`""`), nil
+}
+
+// UnmarshalJSON decodes the password, hashing it if it is in plain text. An empty password
+// keeps the current one unchanged.
+func (p *Password) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+
+	switch {
+	case text == "":
+		return nil
+	case Password(text).IsHashed():
+		*p = Password(text)
+		return nil
+	}
+
+	hashed, err := NewPassword(text)
+	if err != nil {
+		return err
+	}
+
+	*p = hashed
+	return nil
+}
+
+// KeepPasswords copies the passwords of the current version of an object which are left empty
+// in the next one. As passwords are never encoded, this keeps them when an encoded object is
+// edited and saved back.
+func KeepPasswords(next, current Object) {
+	keepEmpty(next, current, passwordsOf)
+}
+
+// keepEmpty copies the fields of the current version of an object which are left empty in the
+// next one, for the fields returned by the function.
+func keepEmpty(next, current Object, fields func(reflect.Type) []structField) {
+	dst := reflect.Indirect(reflect.ValueOf(next))
+	src := reflect.Indirect(reflect.ValueOf(current))
+	if dst.Kind() != reflect.Struct || dst.Type() != src.Type() {
+		return
+	}
+
+	for _, field := range fields(dst.Type()) {
+		if fv := dst.FieldByIndex(field.index); fv.String() == "" {
+			fv.Set(src.FieldByIndex(field.index))
+		}
+	}
+}
+
+// structField represents a field, possibly within nested structs.
+type structField struct {
+	index []int    // Index of the field, for reflection
+	path  []string // JSON path of the field
+}
+
+// cache of the password and secret fields, by type
+var passwords, secrets sync.Map
+
+// passwordsOf returns the password fields of the struct type. The fields of nested structs are
+// included, but not the ones within lists or maps.
+func passwordsOf(typ reflect.Type) []structField {
+	return cachedFields(&passwords, typ, func(field reflect.StructField) bool {
+		return field.Type == reflect.TypeFor[Password]()
+	})
+}
+
+// secretsOf returns the string fields tagged with `secret:"true"` of the struct type, which the
+// storage encrypts. The fields of nested structs are included, but not the ones within lists or
+// maps.
+func secretsOf(typ reflect.Type) []structField {
+	return cachedFields(&secrets, typ, func(field reflect.StructField) bool {
+		return field.Tag.Get("secret") == "true" && field.Type.Kind() == reflect.String
+	})
+}
+
+// cachedFields returns the fields of the struct type matching the predicate, using the cache.
+func cachedFields(cache *sync.Map, typ reflect.Type, match func(reflect.StructField) bool) []structField {
+	if v, ok := cache.Load(typ); ok {
+		return v.([]structField)
+	}
+
+	out := walkFields(typ, nil, nil, nil, match)
+	cache.Store(typ, out)
+	return out
+}
+
+// walkFields appends the fields of the struct type matching the predicate.
+func walkFields(typ reflect.Type, index []int, path []string, out []structField, match func(reflect.StructField) bool) []structField {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		fieldIndex := append(append([]int{}, index...), i)
+		if name == "" {
+			name = field.Name
+		}
+
+		switch {
+		case !field.IsExported() || name == "-":
+			continue
+		case field.Anonymous && field.Type.Kind() == reflect.Struct:
+			out = walkFields(field.Type, fieldIndex, path, out, match)
+		case match(field):
+			out = append(out, structField{
+				index: fieldIndex,
+				path:  append(append([]string{}, path...), name),
+			})
+		case field.Type.Kind() == reflect.Struct:
+			out = walkFields(field.Type, fieldIndex, append(append([]string{}, path...), name), out, match)
+		}
+	}
+	return out
+}
+
+// ---------------------------------- Token ----------------------------------
+
+// Token represents an API token used by scripts and services. The token acts on behalf of its
+// owner, but is restricted to its scopes. Only the hash of the secret is stored.
+type Token struct {
+	Meta    `kind:"token" json:",inline"`
+	Name    string  `json:"name" form:"rw" is:"required,minlen(2),maxlen(50)"`
+	Owner   string  `json:"owner" form:"rw@admin,ro" desc:"User or service the token acts on behalf of"`
+	Scopes  []Scope `json:"scopes" form:"rw"`
+	Days    int     `json:"days" form:"rw" is:"range(0|3650)" desc:"Validity in days, 0 never expires"`
+	Revoked bool    `json:"revoked" form:"rw" desc:"Is the token revoked?"`
+	Secret  string  `json:"secret" form:"-"`
+}
+
+func (t *Token) Title() string {
+	return t.Name
+}
+
+func (t *Token) Subtitle() string {
+	return fmt.Sprintf("Acts on behalf of %s", t.Owner)
+}
+
+// Status returns the status of the token.
+func (t *Token) Status() string {
+	switch {
+	case t.Revoked:
+		return "Revoked"
+	case t.Expired(time.Now()):
+		return "Expired"
+	default:
+		return "Active"
+	}
+}
+
+// Expiry returns the time at which the token expires, or zero if it never expires. It is not
+// named ExpiresAt on purpose, since expired tokens are kept in the storage rather than purged
+// like the objects implementing Expirer.
+func (t *Token) Expiry() time.Time {
+	if t.Days <= 0 || t.CreatedAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, t.CreatedAt).AddDate(0, 0, t.Days)
+}
+
+// BeforeUpdate makes sure that a revoked token stays revoked, as its secret may have leaked.
+func (t *Token) BeforeUpdate(ctx HookContext, old Object) error {
+	if prev, ok := old.(*Token); ok && prev.Revoked && !t.Revoked {
+		return fmt.Errorf("token '%s' is revoked and can not be restored", t.Name)
+	}
+	return nil
+}
+
+// Expired returns true if the token has expired at the specified time.
+func (t *Token) Expired(now time.Time) bool {
+	expires := t.Expiry()
+	return !expires.IsZero() && now.After(expires)
+}
+
+// Mint generates a new secret for the token and returns the bearer value, which is only
+// available at this point since only the hash of the secret is kept.
+func (t *Token) Mint() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	value := base64.RawURLEncoding.EncodeToString(secret)
+	t.Secret = hashOf(value)
+	return t.ID + "." + value, nil
+}
+
+// Verify returns true if the secret matches the token.
+func (t *Token) Verify(secret string) bool {
+	return t.Secret != "" && subtle.ConstantTimeCompare([]byte(hashOf(secret)), []byte(t.Secret)) == 1
+}
+
+// hashOf returns the SHA-256 hash of a high-entropy secret.
+func hashOf(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
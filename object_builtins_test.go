@@ -0,0 +1,46 @@
+package folio_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kelindar/folio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPassword(t *testing.T) {
+	hashed, err := folio.NewPassword("secret")
+	assert.NoError(t, err)
+	assert.True(t, hashed.IsHashed())
+	assert.NotContains(t, string(hashed), "secret")
+	assert.True(t, hashed.Verify("secret"))
+	assert.False(t, hashed.Verify("Secret"))
+	assert.False(t, folio.Password("secret").Verify("secret"))
+	assert.False(t, folio.Password("").Verify(""))
+}
+
+func TestPassword_JSON(t *testing.T) {
+	var user folio.User
+	assert.NoError(t, json.Unmarshal([]byte(`{"name":"alice","password":"secret"}`), &user))
+	assert.True(t, user.Password.IsHashed())
+	assert.True(t, user.Password.Verify("secret"))
+
+	// Hashes are never encoded, except for the storage
+	hashed := user.Password
+	out, err := json.Marshal(&user)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(out), string(hashed))
+
+	out, err = folio.ToStorageJSON(&user)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), string(hashed))
+
+	// Hashed passwords are kept as-is
+	user.Password = ""
+	assert.NoError(t, json.Unmarshal(out, &user))
+	assert.Equal(t, hashed, user.Password)
+
+	// Empty password keeps the current one
+	assert.NoError(t, json.Unmarshal([]byte(`{"password":""}`), &user))
+	assert.Equal(t, hashed, user.Password)
+}
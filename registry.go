@@ -9,6 +9,8 @@ import (
 	"slices"
 	"strings"
 	"sync"
+
+	"github.com/kelindar/folio/validate"
 )
 
 var (
@@ -74,6 +76,11 @@ func (c *registry) Register(typ Type) error {
 		}
 	}
 
+	// Compile the "is" tags, so that invalid validators are reported before the first save
+	if err := validate.Compile(typ.Type); err != nil {
+		return fmt.Errorf("resource: unable to register '%s', %w", typ.Kind, err)
+	}
+
 	// Construct the fields map
 	typ.fields = fieldsOf(typ.Type)
 
@@ -38,7 +38,7 @@ func TestRegistryRange(t *testing.T) {
 		assert.NotNil(t, typ)
 		count++
 	}
-	assert.Equal(t, 2, count)
+	assert.Equal(t, 4, count)
 
 	// Get the reflect.Type of the specified resource kind
 	typ, err := registry.Resolve("kind1")
@@ -60,6 +60,22 @@ func TestRegisterInvalid(t *testing.T) {
 	}))
 }
 
+func TestRegisterInvalidTag(t *testing.T) {
+	type Nested struct {
+		Size int `json:"size" is:"minimum(1)"`
+	}
+
+	type Invalid struct {
+		Meta   `kind:"invalid" json:",inline"`
+		Name   string   `json:"name" is:"required,min(1"`
+		Nested []Nested `json:"nested"`
+	}
+
+	_, err := Register[*Invalid](NewRegistry())
+	assert.ErrorContains(t, err, `invalid rule "min(1" on field Name`)
+	assert.ErrorContains(t, err, `unknown validator "minimum(1)" on field Size`)
+}
+
 // ---------------------------------- Test Types ----------------------------------
 
 type Kind1 struct {
@@ -0,0 +1,469 @@
+package render
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/errors"
+)
+
+var typeMeta = reflect.TypeFor[folio.Meta]()
+
+// csvColumn represents a column of a CSV file, mapped to a field path of a type.
+type csvColumn struct {
+	Path  string       // Path of the field, such as "engine.power"
+	Type  reflect.Type // Type of the field
+	Kind  folio.Kind   // Kind the URNs of the field refer to, if any
+	Write bool         // Whether the column can be imported
+}
+
+// csvColumnsOf returns the columns of the type of the context, with the fields of nested structs
+// flattened into their own columns. Lists, maps and embedded documents are kept in a single
+// column as JSON. The fields which are hidden from the principal are left out.
+func csvColumnsOf(rx *Context) []csvColumn {
+	columns := []csvColumn{
+		{Path: "id", Type: reflect.TypeFor[string](), Write: true},
+		{Path: "namespace", Type: reflect.TypeFor[string](), Write: true},
+		{Path: "state", Type: reflect.TypeFor[string](), Write: true},
+	}
+
+	return csvWalk(rx, columns, rx.Type.Type, "", true)
+}
+
+// csvWalk appends the columns of the fields of the struct type, which can only be written if
+// the struct itself can be.
+func csvWalk(rx *Context, columns []csvColumn, typ reflect.Type, prefix string, write bool) []csvColumn {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := jsonName(field)
+		level := levelReadWrite
+		if field.Tag.Get("form") != "" {
+			level = rx.levelOf(field)
+		}
+
+		switch {
+		case !field.IsExported() || field.Type == typeMeta:
+			continue
+		case field.Anonymous && field.Type.Kind() == reflect.Struct:
+			columns = csvWalk(rx, columns, field.Type, prefix, write)
+			continue
+		case name == "-" || isSecret(field):
+			continue // Secrets are never exported
+		case level == levelHidden:
+			continue
+		}
+
+		inner := field.Type
+		for inner.Kind() == reflect.Pointer {
+			inner = inner.Elem()
+		}
+
+		// Nested structs are flattened, while other values are kept in a single column
+		path := prefix + name
+		switch {
+		case inner.Kind() == reflect.Struct && inner != typeURN && inner != typeTime && inner != typeEmbed:
+			columns = csvWalk(rx, columns, inner, path+".", write && level == levelReadWrite)
+		default:
+			columns = append(columns, csvColumn{
+				Path:  path,
+				Type:  inner,
+				Kind:  folio.Kind(field.Tag.Get("kind")),
+				Write: write && level == levelReadWrite,
+			})
+		}
+	}
+	return columns
+}
+
+// ---------------------------------- Export ----------------------------------
+
+// writeCSV writes the objects as CSV, with a header row of the column paths. The columns and
+// the values which are hidden from the principal are left out.
+func writeCSV(w io.Writer, rx *Context, objects iter.Seq[folio.Object]) error {
+	columns := csvColumnsOf(rx)
+	out := csv.NewWriter(w)
+
+	header := make([]string, 0, len(columns))
+	for _, column := range columns {
+		header = append(header, column.Path)
+	}
+
+	if err := out.Write(header); err != nil {
+		return err
+	}
+
+	for obj := range objects {
+		fields, err := visibleOf(rx, obj)
+		if err != nil {
+			return err
+		}
+
+		record := make([]string, 0, len(columns))
+		for _, column := range columns {
+			record = append(record, csvCell(valueAt(fields, column.Path)))
+		}
+
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// valueAt returns the value at the dot-separated path of the decoded JSON object.
+func valueAt(fields map[string]any, path string) any {
+	var value any = fields
+	for part := range strings.SplitSeq(path, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = m[part]
+	}
+	return value
+}
+
+// csvCell returns the text of a decoded JSON value, lists and maps being kept as JSON.
+func csvCell(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// ---------------------------------- Import ----------------------------------
+
+// csvRow represents a row of an imported CSV file, along with the outcome of its validation.
+type csvRow struct {
+	Line        int                 // Line of the row in the file
+	Object      folio.Object        // Decoded object, nil if the row can not be decoded
+	Exists      bool                // Whether the object already exists
+	Validations []errors.Validation // Validation errors of the row
+}
+
+// Actions of the imported rows
+const (
+	csvCreate  = "create"
+	csvUpdate  = "update"
+	csvSkip    = "skip"
+	csvInvalid = "invalid"
+)
+
+// Action returns what importing the row does, given the conflict strategy.
+func (r *csvRow) Action(conflict folio.Conflict) string {
+	switch {
+	case r.Object == nil || len(r.Validations) > 0:
+		return csvInvalid
+	case r.Exists && conflict == folio.ConflictSkip:
+		return csvSkip
+	case r.Exists:
+		return csvUpdate
+	default:
+		return csvCreate
+	}
+}
+
+// csvCount returns the number of rows which result in one of the actions.
+func csvCount(rows []csvRow, conflict folio.Conflict, actions ...string) (count int) {
+	for _, row := range rows {
+		if slices.Contains(actions, row.Action(conflict)) {
+			count++
+		}
+	}
+	return
+}
+
+// invalid records a validation error of the row.
+func (r *csvRow) invalid(path, format string, args ...any) {
+	r.Validations = append(r.Validations, errors.Validation{
+		Path:    folio.Path(path),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// readCSV reads the rows of a CSV file into objects of the kind of the context. The header row
+// maps the columns to the field paths, the values of each row are set onto the existing object
+// with the same identifier (or onto a new one) and the resulting object is validated. Existing
+// objects are reported as invalid when the conflict strategy is to fail, and so are the rows
+// which change a column that is not writable by the principal.
+func readCSV(rx *Context, r io.Reader, vd errors.Validator, conflict folio.Conflict) ([]csvRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the header, %w", err)
+	}
+
+	// Map the columns of the header onto the field paths
+	known := csvColumnsOf(rx)
+	columns := make([]*csvColumn, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\uFEFF"))
+		idx := slices.IndexFunc(known, func(c csvColumn) bool { return strings.EqualFold(c.Path, name) })
+		if idx < 0 {
+			return nil, fmt.Errorf("unknown column '%s'", name)
+		}
+		columns[i] = &known[idx]
+	}
+
+	titles := &csvTitles{store: rx.Store, urns: make(map[string]map[string][]string)}
+	rows := make([]csvRow, 0, 16)
+	for {
+		record, err := reader.Read()
+		switch {
+		case err == io.EOF:
+			return rows, nil
+		case err != nil:
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := csvRow{Line: line}
+		readRow(rx, &row, columns, record, titles)
+		if row.Object != nil {
+			switch {
+			case row.Exists && conflict == folio.ConflictFail:
+				row.invalid("id", "%s already exists", row.Object.URN())
+			case len(row.Validations) == 0:
+				row.Validations, _ = vd.Validate(row.Object)
+			}
+		}
+
+		rows = append(rows, row)
+	}
+}
+
+// readRow decodes the record into the object of the row.
+func readRow(rx *Context, row *csvRow, columns []*csvColumn, record []string, titles *csvTitles) {
+	fields := map[string]any{}
+	for i, cell := range record {
+		if i >= len(columns) || columns[i] == nil || strings.TrimSpace(cell) == "" {
+			continue
+		}
+
+		value, err := columns[i].parse(strings.TrimSpace(cell), titles, rx.Namespace)
+		if err != nil {
+			row.invalid(columns[i].Path, "%v", err)
+			continue
+		}
+
+		setAt(fields, columns[i].Path, value)
+	}
+
+	// The namespace defaults to the one being viewed, and new objects get an identifier
+	namespace, _ := fields["namespace"].(string)
+	if namespace == "" {
+		namespace = rx.Namespace
+	}
+
+	urn, err := folio.NewURN(namespace, rx.Kind)
+	if err != nil {
+		row.invalid("namespace", "%v", err)
+		return
+	}
+
+	if id, _ := fields["id"].(string); id != "" {
+		urn.ID = id
+	}
+
+	fields["id"], fields["kind"], fields["namespace"] = urn.ID, urn.Kind, urn.Namespace
+	data, err := json.Marshal(fields)
+	if err != nil {
+		row.invalid("", "%v", err)
+		return
+	}
+
+	// Apply the values onto a copy of the existing object, so that the missing columns are kept
+	var obj folio.Object
+	current, err := rx.Store.Fetch(urn)
+	switch {
+	case err == nil:
+		row.Exists = true
+		obj, err = copyOf(rx.Registry, current)
+	case folio.IsNotFound(err):
+		current = nil
+		obj, err = folio.NewByURN(rx.Registry, urn)
+	}
+
+	if err == nil {
+		err = json.Unmarshal(data, obj)
+	}
+
+	if err != nil {
+		row.invalid("", "%v", err)
+		return
+	}
+
+	row.Object = obj
+	if err := guardObject(rx, obj, current); err != nil {
+		row.invalid("", "%v", err)
+	}
+}
+
+// copyOf returns a deep copy of the object.
+func copyOf(registry folio.Registry, obj folio.Object) (folio.Object, error) {
+	data, err := folio.ToStorageJSON(obj)
+	if err != nil {
+		return nil, err
+	}
+	return folio.FromJSON(registry, data)
+}
+
+// setAt sets the value at the dot-separated path, creating the intermediate objects.
+func setAt(fields map[string]any, path string, value any) {
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := fields[part].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			fields[part] = next
+		}
+		fields = next
+	}
+	fields[parts[len(parts)-1]] = value
+}
+
+// parse converts the text of a cell into the JSON value of the column. References can be given
+// either as URNs or as the titles of the objects they refer to.
+func (c *csvColumn) parse(cell string, titles *csvTitles, namespace string) (any, error) {
+	return parseCell(c.Type, c.Kind, cell, titles, namespace)
+}
+
+// parseCell converts the text of a cell into the JSON value of the type.
+func parseCell(typ reflect.Type, kind folio.Kind, cell string, titles *csvTitles, namespace string) (any, error) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch typ {
+	case typeURN:
+		return titles.resolve(kind, namespace, cell)
+	case typeTime:
+		for _, layout := range []string{time.RFC3339Nano, time.DateTime, time.DateOnly} {
+			if t, err := time.Parse(layout, cell); err == nil {
+				return t.Format(time.RFC3339Nano), nil
+			}
+		}
+		return nil, fmt.Errorf("invalid time '%s'", cell)
+	case typeEmbed:
+		return decodeCell(cell)
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		return cell, nil
+	case reflect.Bool:
+		return strconv.ParseBool(cell)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if _, err := strconv.ParseFloat(cell, 64); err != nil {
+			return nil, fmt.Errorf("invalid number '%s'", cell)
+		}
+		return json.Number(cell), nil
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return cell, nil // Bytes are encoded as base64
+		}
+
+		// Lists are either JSON arrays or separated by semicolons
+		var items []any
+		if strings.HasPrefix(cell, "[") {
+			if err := json.Unmarshal([]byte(cell), &items); err != nil {
+				return nil, fmt.Errorf("invalid list, %v", err)
+			}
+		} else {
+			for item := range strings.SplitSeq(cell, ";") {
+				items = append(items, strings.TrimSpace(item))
+			}
+		}
+
+		// Text items are converted to the type of the elements, the others are kept as-is
+		for i, item := range items {
+			if text, ok := item.(string); ok {
+				value, err := parseCell(typ.Elem(), kind, text, titles, namespace)
+				if err != nil {
+					return nil, err
+				}
+				items[i] = value
+			}
+		}
+		return items, nil
+	default:
+		return decodeCell(cell)
+	}
+}
+
+// decodeCell decodes a cell which contains JSON.
+func decodeCell(cell string) (any, error) {
+	var value any
+	if err := json.Unmarshal([]byte(cell), &value); err != nil {
+		return nil, fmt.Errorf("invalid JSON, %v", err)
+	}
+	return value, nil
+}
+
+// ---------------------------------- References ----------------------------------
+
+// csvTitles resolves the titles of the objects into their URNs, loading the objects of each
+// kind and namespace once.
+type csvTitles struct {
+	store folio.Storage
+	urns  map[string]map[string][]string // URNs by lowercase title, by kind and namespace
+}
+
+// resolve returns the URN of the object with the title, or the value itself if it is a URN.
+func (t *csvTitles) resolve(kind folio.Kind, namespace, value string) (string, error) {
+	if _, err := folio.ParseURN(value); err == nil || kind == "" {
+		return value, nil
+	}
+
+	key := string(kind) + "/" + namespace
+	byTitle, ok := t.urns[key]
+	if !ok {
+		found, err := t.store.Search(kind, folio.Query{Namespace: namespace})
+		if err != nil {
+			return "", err
+		}
+
+		byTitle = make(map[string][]string)
+		for obj := range found {
+			title := strings.ToLower(TitleOf(obj))
+			byTitle[title] = append(byTitle[title], obj.URN().String())
+		}
+		t.urns[key] = byTitle
+	}
+
+	switch urns := byTitle[strings.ToLower(value)]; len(urns) {
+	case 0:
+		return "", fmt.Errorf("unable to find %s '%s'", kind, value)
+	case 1:
+		return urns[0], nil
+	default:
+		return "", fmt.Errorf("ambiguous %s '%s', found %d objects with this title", kind, value, len(urns))
+	}
+}
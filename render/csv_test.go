@@ -0,0 +1,196 @@
+package render
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSVColumns(t *testing.T) {
+	registry := folio.NewRegistry()
+	typ, _ := folio.Register[*Truck](registry)
+
+	var paths []string
+	for _, column := range csvColumnsOf(&Context{Type: typ}) {
+		paths = append(paths, column.Path)
+	}
+
+	assert.Equal(t, []string{"id", "namespace", "state", "model", "drivers", "engine.power"}, paths)
+}
+
+func TestCSV(t *testing.T) {
+	registry := folio.NewRegistry()
+	folio.Register[*Truck](registry)
+	folio.Register[*Driver](registry)
+	folio.Register[*Depot](registry)
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	var drivers []folio.URN
+	for _, name := range []string{"Alice", "Bob"} {
+		driver, err := folio.Create[*Driver](db, func(d *Driver) error {
+			d.Name = name
+			return nil
+		}, "default", "sys")
+		assert.NoError(t, err)
+		drivers = append(drivers, driver.URN())
+	}
+
+	truck, err := folio.Create[*Truck](db, func(v *Truck) error {
+		v.Model = "Actros"
+		v.Drivers = drivers[:1]
+		v.Engine.Power = 350
+		return nil
+	}, "default", "sys")
+	assert.NoError(t, err)
+
+	handler := New(registry, db)
+	token := strings.Repeat("t", 32)
+	post := func(body *bytes.Buffer, contentType string) string {
+		r := httptest.NewRequest("POST", "/import/truck?ns=default", body)
+		r.Header.Set("Content-Type", contentType)
+		r.Header.Set(csrfHeader, token)
+		r.AddCookie(&http.Cookie{Name: csrfCookie, Value: token})
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		return w.Body.String()
+	}
+
+	// The list links to the CSV export of the current query
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/content/truck?ns=default", nil))
+	assert.Contains(t, w.Body.String(), `href="/csv/truck?ns=default&amp;filter=`)
+
+	// Export with the nested fields flattened
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", csvOf("truck", folio.Query{Namespace: "default"}), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Equal(t, "id,namespace,state,model,drivers,engine.power\n"+
+		truck.URN().ID+`,default,,Actros,"[""`+drivers[0].String()+`""]",350`+"\n", w.Body.String())
+
+	// Upload a file, which is previewed first
+	data := "model,drivers,engine.power\n" +
+		"Volvo,Alice; bob,400\n" +
+		",Alice,100\n" +
+		"Scania,Carol,300\n"
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "trucks.csv")
+	part.Write([]byte(data))
+	form.Close()
+
+	preview := post(&body, form.FormDataContentType())
+	assert.Contains(t, preview, "1 of 3 rows will be imported")
+	assert.Contains(t, preview, "unable to find driver &#39;Carol&#39;")
+	assert.Contains(t, preview, `name="csv"`)
+
+	// Commit the previewed file, only the valid rows are imported
+	result := post(bytes.NewBufferString(url.Values{"csv": {data}, "conflict": {"skip"}}.Encode()),
+		"application/x-www-form-urlencoded")
+	assert.NotContains(t, result, "The import has stopped")
+
+	found, err := folio.Search[*Truck](db, folio.Query{Namespace: "default", Filters: map[string][]string{"model": {"Volvo"}}})
+	assert.NoError(t, err)
+	imported := 0
+	for v := range found {
+		assert.Equal(t, drivers, v.Drivers)
+		assert.Equal(t, 400, v.Engine.Power)
+		imported++
+	}
+	assert.Equal(t, 1, imported)
+
+	count, err := folio.Count[*Truck](db, folio.Query{Namespace: "default"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// Existing objects are updated, keeping the columns which are not provided
+	post(bytes.NewBufferString(url.Values{
+		"csv":      {"id,engine.power\n" + truck.URN().ID + ",500\n"},
+		"conflict": {"overwrite"},
+	}.Encode()), "application/x-www-form-urlencoded")
+
+	updated, err := folio.Fetch[*Truck](db, truck.URN())
+	assert.NoError(t, err)
+	assert.Equal(t, "Actros", updated.Model)
+	assert.Equal(t, 500, updated.Engine.Power)
+}
+
+func TestCSV_Levels(t *testing.T) {
+	registry := folio.NewRegistry()
+	folio.Register[*Incident](registry)
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	incident, err := folio.Create(db, func(v *Incident) error {
+		v.Title = "Outage"
+		v.Notes = "secret"
+		v.Lead = &Step{Name: "bob", Approved: true, Note: "secret"}
+		return nil
+	}, "default", "sys")
+	assert.NoError(t, err)
+
+	handler := New(registry, db,
+		WithAuthenticator(AuthenticatorFunc(func(r *http.Request) (*folio.Principal, error) {
+			return &folio.Principal{Name: "alice", Role: folio.Role(r.Header.Get("X-Role"))}, nil
+		})),
+		WithAuthorizer(folio.NewPolicy(db)),
+	)
+
+	export := func(role folio.Role) string {
+		r := httptest.NewRequest("GET", csvOf("incident", folio.Query{Namespace: "default"}), nil)
+		r.Header.Set("X-Role", string(role))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	// The columns hidden from the principal are left out
+	assert.True(t, strings.HasPrefix(export(folio.RoleEditor), "id,namespace,state,title,steps,lead.name,lead.approved\n"))
+	assert.True(t, strings.HasPrefix(export(folio.RoleAdmin), "id,namespace,state,title,notes,steps,lead.name,lead.approved,lead.note\n"))
+	assert.NotContains(t, export(folio.RoleEditor), "secret")
+
+	preview := func(data string) (int, string) {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("file", "incidents.csv")
+		part.Write([]byte(data))
+		form.WriteField("conflict", "overwrite")
+		form.Close()
+
+		token := strings.Repeat("t", 32)
+		r := httptest.NewRequest("POST", "/import/incident?ns=default", &body)
+		r.Header.Set("Content-Type", form.FormDataContentType())
+		r.Header.Set("X-Role", string(folio.RoleEditor))
+		r.Header.Set(csrfHeader, token)
+		r.AddCookie(&http.Cookie{Name: csrfCookie, Value: token})
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code, w.Body.String()
+	}
+
+	// Hidden columns are unknown
+	code, _ := preview("id,title,notes\n" + incident.ID + ",Outage,changed\n")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// Read-only columns can be kept, but not changed
+	code, out := preview("id,title,lead.approved\n" +
+		incident.ID + ",Incident,true\n" +
+		incident.ID + ",Incident,false\n")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, out, "1 of 2 rows will be imported")
+	assert.Contains(t, out, "unable to write path lead.approved")
+}
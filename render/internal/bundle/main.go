@@ -0,0 +1,102 @@
+// Command bundle vendors the frontend assets into "assets/vendor", so that the render package
+// can serve them in offline mode. It downloads the pinned releases of htmx and Franken UI, and
+// precompiles the Tailwind classes used in the templates with the Tailwind CLI (through npx).
+// It is meant to be run with "go generate" from the render package directory.
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+const (
+	vendor   = "assets/vendor"
+	tailwind = "tailwindcss@3.4.17"
+)
+
+// downloads maps the vendored file names to the pinned release they are downloaded from.
+var downloads = map[string]string{
+	"htmx.min.js":               "https://unpkg.com/htmx.org@2.0.2/dist/htmx.min.js",
+	"franken-core.min.css":      "https://cdn.jsdelivr.net/npm/franken-ui@2.0.0/dist/css/core.min.css",
+	"franken-utilities.min.css": "https://cdn.jsdelivr.net/npm/franken-ui@2.0.0/dist/css/utilities.min.css",
+	"franken-core.iife.js":      "https://cdn.jsdelivr.net/npm/franken-ui@2.0.0/dist/js/core.iife.js",
+	"franken-icon.iife.js":      "https://cdn.jsdelivr.net/npm/franken-ui@2.0.0/dist/js/icon.iife.js",
+}
+
+func main() {
+	if err := run(); err != nil {
+		slog.Error("unable to bundle assets", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	if err := os.MkdirAll(vendor, 0o755); err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: time.Minute}
+	for name, url := range downloads {
+		slog.Info("downloading", "asset", name, "url", url)
+		if err := download(client, url, filepath.Join(vendor, name)); err != nil {
+			return fmt.Errorf("unable to download %s, %w", name, err)
+		}
+	}
+
+	slog.Info("compiling", "asset", "tailwind.css")
+	return compile(filepath.Join(vendor, "tailwind.css"))
+}
+
+// download downloads the URL into the file.
+func download(client *http.Client, url, path string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+	_, err = io.Copy(file, resp.Body)
+	return err
+}
+
+// compile generates the Tailwind classes used by the templates and scripts.
+func compile(path string) error {
+	input, err := os.CreateTemp("", "tailwind-*.css")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(input.Name())
+	if _, err := input.WriteString("@tailwind base;\n@tailwind components;\n@tailwind utilities;\n"); err != nil {
+		return err
+	}
+	if err := input.Close(); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("npx", "--yes", tailwind,
+		"--input", input.Name(),
+		"--output", path,
+		"--content", "./*.templ,./assets/scripts.js",
+		"--minify",
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
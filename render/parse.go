@@ -34,7 +34,16 @@ func isEmail(field reflect.StructField) bool {
 }
 
 func isRequired(field reflect.StructField) bool {
-	return strings.Contains(field.Tag.Get("is"), "required")
+	for _, rule := range strings.Split(field.Tag.Get("is"), ",") {
+		if name, _, _ := strings.Cut(strings.TrimSpace(rule), "~"); name == "required" {
+			return true
+		}
+	}
+	return false
+}
+
+func isSecret(field reflect.StructField) bool {
+	return field.Tag.Get("secret") == "true" && field.Type.Kind() == reflect.String
 }
 
 // Parses oneof tag from validator e.g.: "required,oneof=male female prefer_not_to"
@@ -79,19 +88,23 @@ func decodeKind(field reflect.StructField, registry folio.Registry) (folio.Type,
 
 // ---------------------------------- Form ----------------------------------
 
-// hydrate parses the input flat JSON form.
-func hydrate(reader io.Reader, typ folio.Type, dst folio.Object, vd errors.Validator) (_ []errors.Validation, errDecode error) {
+// hydrate parses the input flat JSON form. Only the paths which are writable by the principal
+// of the context can be present in the input, otherwise a forbidden error is returned.
+func hydrate(rx *Context, reader io.Reader, dst folio.Object, vd errors.Validator) (_ []errors.Validation, errDecode error) {
 	input, err := io.ReadAll(reader)
 	if err != nil {
 		return nil, err
 	}
 
+	typ := rx.Type
 	lookup := make(map[Path]int, 16)
 	reverse := make(slicePath, 0, 16)
 
 	// Reset all nested slices and maps that exist, so we can overwrite them
 	walk.Walk(dst, func(rv reflect.Value, field *reflect.StructField, path []string) error {
 		switch {
+		case !isWritable(rx, Path(strings.Join(path, "."))):
+			return nil
 		case rv.Kind() == reflect.Map && rv.Len() > 0:
 			rv.Set(reflect.MakeMap(rv.Type()))
 		case rv.Kind() == reflect.Slice && rv.Len() > 0:
@@ -103,14 +116,23 @@ func hydrate(reader io.Reader, typ folio.Type, dst folio.Object, vd errors.Valid
 	gjson.ParseBytes(input).ForEach(func(key, value gjson.Result) bool {
 		rv := reflect.Indirect(reflect.ValueOf(dst))
 
+		// Secrets are never rendered into the form, so an empty value keeps the current one
+		if fd, ok := typ.Field(Path(key.String())); ok && isSecret(fd) && value.String() == "" {
+			return true
+		}
+
 		// Ensure that the field is settable and the path can be reached. If not, allocate
 		// everything along the way (analogous to MkDirAll)
 		for subpath := range Path(key.String()).Walk() {
 			subpath = folio.Path(strings.TrimSuffix(string(subpath), "[]"))
 			fd, ok := typ.Field(subpath)
-			if !ok {
+			switch {
+			case !ok:
 				errDecode = fmt.Errorf("unable to find path %s", key.String())
 				return false
+			case fd.Tag.Get("form") != "" && rx.levelOf(fd) != levelReadWrite:
+				errDecode = fmt.Errorf("%w, unable to write path %s", folio.ErrForbidden, key.String())
+				return false
 			}
 
 			//fmt.Printf(" - %s of %s, field: %s, type: %v\n", subpath, rv.Kind(), fd.Name, fd.Type.Kind())
@@ -192,6 +214,18 @@ func hydrate(reader io.Reader, typ folio.Type, dst folio.Object, vd errors.Valid
 	return nil, nil
 }
 
+// isWritable returns true if every field along the path is writable by the principal. The
+// fields without a form tag are considered writable.
+func isWritable(rx *Context, path Path) bool {
+	for subpath := range path.Walk() {
+		fd, ok := rx.Type.Field(subpath)
+		if ok && fd.Tag.Get("form") != "" && rx.levelOf(fd) != levelReadWrite {
+			return false
+		}
+	}
+	return true
+}
+
 var skip = fmt.Errorf("skip")
 
 func unmarshalJSON(dst reflect.Value, value string) error {
@@ -51,7 +51,6 @@ type Car struct {
 
 func TestUnmarshalForm(t *testing.T) {
 	inputJSON := `{
-		"kind": "car",
 		"type": "sedan",
 		"year": 2000,
 		"model": "Tesla",
@@ -83,7 +82,7 @@ func TestUnmarshalForm(t *testing.T) {
 	typ, _ := folio.Register[*Car](registry)
 
 	var car Car
-	_, err := hydrate(strings.NewReader(inputJSON), typ, &car, errors.NewValidator())
+	_, err := hydrate(&Context{Type: typ}, strings.NewReader(inputJSON), &car, errors.NewValidator())
 	assert.NoError(t, err)
 	assert.Equal(t, "sedan", car.Type)
 }
@@ -97,7 +96,7 @@ func TestUnmarshal_URN(t *testing.T) {
 	typ, _ := folio.Register[*Car](registry)
 
 	var car Car
-	_, err := hydrate(strings.NewReader(inputJSON), typ, &car, errors.NewValidator())
+	_, err := hydrate(&Context{Type: typ}, strings.NewReader(inputJSON), &car, errors.NewValidator())
 	assert.NoError(t, err)
 	assert.Equal(t, "urn:default:company:cs0m2m1hq4ujcu6kfr30", car.Company.String())
 }
@@ -112,7 +111,7 @@ func TestUnmarshal_Struct(t *testing.T) {
 	typ, _ := folio.Register[*Car](registry)
 
 	var car Car
-	_, err := hydrate(strings.NewReader(inputJSON), typ, &car, errors.NewValidator())
+	_, err := hydrate(&Context{Type: typ}, strings.NewReader(inputJSON), &car, errors.NewValidator())
 	assert.NoError(t, err)
 	assert.Equal(t, "electric", car.Engine.Type)
 	assert.Equal(t, 200, car.Engine.Power)
@@ -130,7 +129,7 @@ func TestUnmarshal_Slice(t *testing.T) {
 	typ, _ := folio.Register[*Car](registry)
 
 	var car Car
-	_, err := hydrate(strings.NewReader(inputJSON), typ, &car, errors.NewValidator())
+	_, err := hydrate(&Context{Type: typ}, strings.NewReader(inputJSON), &car, errors.NewValidator())
 	assert.NoError(t, err)
 	assert.Equal(t, "diesel", car.Engines[0].Type)
 	assert.Equal(t, 150, car.Engines[0].Power)
@@ -155,7 +154,7 @@ func TestUnmarshal_Slice_Overwrite(t *testing.T) {
 			{Type: "gasoline", Power: 100},
 		},
 	}
-	_, err := hydrate(strings.NewReader(inputJSON), typ, &car, errors.NewValidator())
+	_, err := hydrate(&Context{Type: typ}, strings.NewReader(inputJSON), &car, errors.NewValidator())
 	assert.NoError(t, err)
 	assert.Equal(t, "diesel", car.Engines[0].Type)
 	assert.Equal(t, 150, car.Engines[0].Power)
@@ -163,3 +162,42 @@ func TestUnmarshal_Slice_Overwrite(t *testing.T) {
 	assert.Equal(t, 200, car.Engines[1].Power)
 	assert.Equal(t, 2, len(car.Engines))
 }
+
+type Salary struct {
+	folio.Meta `kind:"salary" json:",inline"`
+	Name       string   `json:"name" form:"rw"`
+	Amount     int      `json:"amount" form:"rw@hr,ro@viewer,-"`
+	Bonuses    []string `json:"bonuses" form:"rw@admin,-"`
+	Notes      []string `json:"notes" form:"rw,inline"`
+}
+
+func TestUnmarshal_Forbidden(t *testing.T) {
+	registry := folio.NewRegistry()
+	typ, _ := folio.Register[*Salary](registry)
+	rx := &Context{
+		Type:   typ,
+		Kind:   typ.Kind,
+		User:   &folio.Principal{Name: "bob"},
+		Access: folio.NewPolicy(nil, folio.Grant{Subject: "*", Role: folio.RoleEditor}),
+	}
+
+	// Writable fields are decoded, while the unwritable slices are kept as-is
+	salary := Salary{Bonuses: []string{"signing"}}
+	_, err := hydrate(rx, strings.NewReader(`{"name": "Bob", "notes[]": ["a", "b"]}`), &salary, errors.NewValidator())
+	assert.NoError(t, err)
+	assert.Equal(t, "Bob", salary.Name)
+	assert.Equal(t, []string{"a", "b"}, salary.Notes)
+	assert.Equal(t, []string{"signing"}, salary.Bonuses)
+
+	// Writing a read-only, hidden or metadata field is rejected
+	for _, input := range []string{`{"amount": 1000}`, `{"bonuses[]": ["yearly"]}`, `{"kind": "app"}`} {
+		_, err := hydrate(rx, strings.NewReader(input), &salary, errors.NewValidator())
+		assert.True(t, folio.IsForbidden(err), input)
+	}
+
+	// Members of the group are allowed to write the field
+	rx.User.Groups = []string{"hr"}
+	_, err = hydrate(rx, strings.NewReader(`{"amount": 1000}`), &salary, errors.NewValidator())
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, salary.Amount)
+}
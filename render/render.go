@@ -50,6 +50,70 @@ type Context struct {
 	Registry  folio.Registry
 	Query     folio.Query
 	Namespace string
+	User      *folio.Principal // Principal performing the request, nil if anonymous
+	Access    folio.Authorizer // Authorizer of the principal, nil if unrestricted
+	role      *folio.Role      // Resolved role of the principal, lazily computed
+}
+
+// Can returns true if the principal is allowed to perform the action on the namespace
+// and kind of the specified URN.
+func (rx *Context) Can(action folio.Action, urn folio.URN) bool {
+	return folio.Can(rx.Access, rx.User, action, urn.Namespace, urn.Kind)
+}
+
+// levelOf returns the access level of the field for the principal.
+func (rx *Context) levelOf(field reflect.StructField) string {
+	return levelOf(field.Tag.Get("form"), rx.matches)
+}
+
+// matches returns true if the condition of a form tag refers to the principal, either by
+// a role it holds on the current namespace and kind, by its name or by one of its groups.
+func (rx *Context) matches(subject string) bool {
+	switch role := folio.Role(strings.ToLower(subject)); role {
+	case folio.RoleViewer, folio.RoleEditor, folio.RoleAdmin:
+		return rx.roleOf().Includes(role)
+	default:
+		return rx.User.Is(subject)
+	}
+}
+
+// in returns the context for the namespace, so that the access levels are resolved in it.
+func (rx *Context) in(namespace string) *Context {
+	if namespace == rx.Namespace {
+		return rx
+	}
+
+	scoped := *rx
+	scoped.Namespace, scoped.role = namespace, nil
+	return &scoped
+}
+
+// roleOf returns the role of the principal on the current namespace and kind.
+func (rx *Context) roleOf() folio.Role {
+	if rx.Access == nil {
+		return folio.RoleAdmin
+	}
+
+	if rx.role == nil {
+		role := rx.Access.RoleOf(rx.User, rx.Namespace, rx.Kind)
+		rx.role = &role
+	}
+	return *rx.role
+}
+
+// withMode returns a copy of the context with a different rendering mode.
+func (rx *Context) withMode(mode Mode) *Context {
+	out := *rx
+	out.Mode = mode
+	return &out
+}
+
+// username returns the name of the principal, used to track who made the changes.
+func (rx *Context) username() string {
+	if rx.User == nil || rx.User.Name == "" {
+		return "sys"
+	}
+	return rx.User.Name
 }
 
 // Props represents the properties of the editor use to render the field.
@@ -75,14 +139,30 @@ const (
 	levelReadWrite = "rw"
 )
 
-func levelOf(tag string) string {
-	// Parse comma-separated values and extract the access level
-	parts := strings.Split(strings.ToLower(tag), ",")
-	if len(parts) == 0 {
-		return levelHidden
+// levelOf returns the access level from the form tag. The tag may contain conditional levels,
+// such as "rw@admin,ro@hr,-", where the first entry whose condition matches is used and the
+// unconditional entry is the default.
+func levelOf(tag string, matches func(subject string) bool) string {
+	fallback := levelHidden
+	defaults := false
+	for _, part := range strings.Split(tag, ",") {
+		level, cond, ok := strings.Cut(strings.TrimSpace(part), "@")
+		level = strings.ToLower(level)
+		switch {
+		case level == "inline":
+			continue
+		case !ok && !defaults:
+			fallback, defaults = level, true
+		case ok && matches != nil && matches(cond):
+			return parseLevel(level)
+		}
 	}
 
-	level := strings.TrimSpace(parts[0])
+	return parseLevel(fallback)
+}
+
+// parseLevel parses a single access level
+func parseLevel(level string) string {
 	switch level {
 	case levelReadOnly: // read-only
 		return levelReadOnly
@@ -201,11 +281,11 @@ func renderField(props *Props) (string, templ.Component) {
 	}
 
 	// Check the level of the field
-	switch levelOf(props.Field.Tag.Get("form")) {
+	switch props.levelOf(props.Field) {
 	case levelHidden:
 		return "", nil
 	case levelReadOnly:
-		props.Mode = ModeView
+		props.Context = props.withMode(ModeView)
 	}
 
 	// Render the actual value
@@ -228,11 +308,21 @@ func renderValue(props *Props) (string, templ.Component) {
 	}
 
 	// Check the level of the field
-	switch levelOf(props.Field.Tag.Get("form")) {
+	switch props.levelOf(props.Field) {
 	case levelHidden:
 		return "", nil
 	case levelReadOnly:
-		props.Mode = ModeView
+		props.Context = props.withMode(ModeView)
+	}
+
+	// Passwords are never rendered, only replaced
+	if _, ok := value.Interface().(folio.Password); ok {
+		return label, Password(props)
+	}
+
+	// Secrets are masked, and only shown when explicitly revealed
+	if isSecret(props.Field) {
+		return label, Secret(props)
 	}
 
 	// If the field implements the Lookup interface, we can render it directly
@@ -36,6 +36,59 @@ func TestObject_Edit(t *testing.T) {
 	assert.Len(t, components, 9)
 }
 
+func TestObject_FieldLevel(t *testing.T) {
+	registry := folio.NewRegistry()
+	typ, _ := folio.Register[*Salary](registry)
+	salary := &Salary{Name: "Bob", Amount: 1000}
+
+	tests := []struct {
+		who      *folio.Principal
+		role     folio.Role
+		expected int
+	}{
+		{who: &folio.Principal{Name: "alice", Groups: []string{"hr"}}, expected: 3},
+		{who: &folio.Principal{Name: "bob"}, role: folio.RoleViewer, expected: 3},
+		{who: &folio.Principal{Name: "bob"}, role: folio.RoleAdmin, expected: 4},
+		{who: &folio.Principal{Name: "eve"}, expected: 2},
+	}
+
+	for _, tc := range tests {
+		rx := &Context{
+			Mode:   ModeEdit,
+			Type:   typ,
+			Kind:   typ.Kind,
+			User:   tc.who,
+			Access: folio.NewPolicy(nil, folio.Grant{Subject: tc.who.Name, Role: tc.role}),
+		}
+
+		assert.Len(t, Object(rx, salary), tc.expected, tc.who.Name)
+		assert.Equal(t, ModeEdit, rx.Mode)
+	}
+}
+
+func TestLevelOf(t *testing.T) {
+	isHR := func(subject string) bool { return subject == "hr" }
+	tests := []struct {
+		tag      string
+		matches  func(string) bool
+		expected string
+	}{
+		{"", nil, levelHidden},
+		{"rw", nil, levelReadWrite},
+		{"ro", nil, levelReadOnly},
+		{"rw,inline", nil, levelReadWrite},
+		{"rw@admin,ro@hr,-", nil, levelHidden},
+		{"rw@admin,ro@hr,-", isHR, levelReadOnly},
+		{"ro@hr,rw", isHR, levelReadOnly},
+		{"ro,rw@hr", isHR, levelReadWrite},
+		{"rw@admin", isHR, levelHidden},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, levelOf(tc.tag, tc.matches), tc.tag)
+	}
+}
+
 func TestInspect_StringOf(t *testing.T) {
 	assert.Equal(t, "John Doe", StringOf(newPerson(), "Name"))
 	assert.Equal(t, "30", StringOf(newPerson(), "Age"))
@@ -2,6 +2,7 @@ package render
 
 import (
 	"compress/gzip"
+	"context"
 	"embed"
 	"fmt"
 	"log/slog"
@@ -19,52 +20,243 @@ import (
 //go:embed all:assets
 var assets embed.FS
 
-// ListenAndServe starts the server on the given port.
-func ListenAndServe(port int, registry folio.Registry, db folio.Storage) error {
+// Option represents a server option.
+type Option func(*options)
+
+// options represents the server options.
+type options struct {
+	store         folio.Storage                     // Storage used to look up users and tokens
+	authenticator Authenticator                     // Authenticator used to identify the principal
+	authorizer    folio.Authorizer                  // Authorizer used to check permissions
+	sessions      *sessions                         // Sessions of the signed in users, if enabled
+	oidc          *oidcProvider                     // OpenID Connect provider, if enabled
+	prefix        string                            // URL prefix under which the handler is mounted
+	middleware    []func(http.Handler) http.Handler // Custom middleware, outermost first
+	server        *http.Server                      // Server used by Serve, if configured
+	debug         bool                              // Whether profiling and diagnostics are enabled
+	offline       bool                              // Whether the frontend assets are self-hosted
+}
+
+// WithPrefix mounts the handler under the URL prefix (e.g. "/admin"), so that it can be
+// embedded into an existing service.
+func WithPrefix(prefix string) Option {
+	return func(o *options) {
+		o.prefix = "/" + strings.Trim(prefix, "/")
+		if o.prefix == "/" {
+			o.prefix = ""
+		}
+	}
+}
+
+// WithMiddleware wraps the handler with custom middleware, applied in the order provided
+// so that the first one is the outermost.
+func WithMiddleware(middleware ...func(http.Handler) http.Handler) Option {
+	return func(o *options) {
+		o.middleware = append(o.middleware, middleware...)
+	}
+}
+
+// WithServer sets the server used by Serve and ListenAndServe. Its handler and address are
+// only set if they are empty.
+func WithServer(server *http.Server) Option {
+	return func(o *options) {
+		o.server = server
+	}
+}
+
+// New creates a new handler which renders the objects of the registry, stored in the storage.
+func New(registry folio.Registry, db folio.Storage, opts ...Option) http.Handler {
 	vd := errors.NewValidator()
+	o := &options{store: db}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	mux := http.NewServeMux()
+
+	// Sign in with the built-in users or the OIDC provider, using signed session cookies
+	if o.sessions != nil || o.oidc != nil {
+		if o.sessions == nil {
+			o.sessions = newSessions(nil)
+		}
+
+		o.sessions.store = db
+		if o.authenticator == nil {
+			o.authenticator = o.sessions
+		}
+		if o.authorizer == nil {
+			o.authorizer = folio.NewPolicy(db)
+		}
+
+		mux.Handle("GET /login", loginPage(o))
+		mux.Handle("POST /logout", logout(o.sessions))
+	}
+
+	// If the built-in users are enabled, make sure there is an administrator
+	if o.sessions != nil && o.sessions.users {
+		if err := o.sessions.bootstrap(); err != nil {
+			slog.Error("unable to create initial user", "error", err)
+		}
+
+		mux.Handle("POST /login", login(o))
+	}
+
+	// If the OIDC provider is enabled, handle the authorization code flow
+	if o.oidc != nil {
+		mux.Handle("GET /oidc/login", oidcLogin(o.oidc, o.sessions))
+		mux.Handle("GET /oidc/callback", oidcCallback(o))
+	}
+
+	// Authenticates every request that renders or modifies objects
+	route := func(pattern string, handler http.Handler) {
+		mux.Handle(pattern, withAccess(o, handler))
+	}
+
+	// Handle static assets
+	mux.Handle("GET /assets/", serveStatic(http.FS(assets)))
+
+	// Profiling and diagnostics, only for administrators
+	if o.debug {
+		debug := func(pattern string, handler http.Handler) {
+			route(pattern, withAdmin(handler))
+		}
+
+		debug("GET /debug/{$}", diagnostics(registry, db))
+		debug("GET /debug/pprof/", http.HandlerFunc(pprof.Index))
+		debug("GET /debug/pprof/cmdline", http.HandlerFunc(pprof.Cmdline))
+		debug("GET /debug/pprof/profile", http.HandlerFunc(pprof.Profile))
+		debug("GET /debug/pprof/symbol", http.HandlerFunc(pprof.Symbol))
+		debug("GET /debug/pprof/trace", http.HandlerFunc(pprof.Trace))
+	}
 
-	// Handle static assets & pprof
-	http.Handle("GET /assets/", serveStatic(http.FS(assets)))
-	http.Handle("GET /pprof/", http.HandlerFunc(pprof.Index))
-	http.Handle("GET /pprof/cmdline", http.HandlerFunc(pprof.Cmdline))
-	http.Handle("GET /pprof/profile", http.HandlerFunc(pprof.Profile))
-	http.Handle("GET /pprof/symbol", http.HandlerFunc(pprof.Symbol))
-	http.Handle("GET /pprof/trace", http.HandlerFunc(pprof.Trace))
-	http.Handle("GET /pprof/allocs", pprof.Handler("allocs"))
-	http.Handle("GET /pprof/block", pprof.Handler("block"))
-	http.Handle("GET /pprof/goroutine", pprof.Handler("goroutine"))
-	http.Handle("GET /pprof/heap", pprof.Handler("heap"))
-	http.Handle("GET /pprof/mutex", pprof.Handler("mutex"))
-	http.Handle("GET /pprof/threadcreate", pprof.Handler("threadcreate"))
+	// Users change their own password, without being able to edit their user object
+	if o.sessions != nil && o.sessions.users {
+		route("GET /password", passwordPage(o))
+		route("POST /password", changePassword(o))
+	}
 
 	// Handle page view
-	http.Handle("GET /", page(registry, db))
-	http.Handle("GET /{kind}", page(registry, db))
-	http.Handle("POST /content/{kind}", content(registry, db))
-	http.Handle("GET /content/{kind}", content(registry, db))
+	route("GET /", page(registry, db))
+	route("GET /{kind}", page(registry, db))
+	route("POST /content/{kind}", content(registry, db))
+	route("GET /content/{kind}", content(registry, db))
 
 	// Handle API endpoints
-	http.Handle("GET /view/{urn}", editObject(ModeView, registry, db))
-	http.Handle("GET /edit/{urn}", editObject(ModeEdit, registry, db))
-	http.Handle("GET /make/{kind}", makeObject(registry, db))
+	route("GET /view/{urn}", editObject(ModeView, registry, db))
+	route("GET /edit/{urn}", editObject(ModeEdit, registry, db))
+	route("GET /make/{kind}", makeObject(registry, db))
+	route("GET /reveal/{urn}", revealSecret(registry, db))
 
 	// Object CRUD endpoints
-	http.Handle("PUT /obj/{urn}", saveObject(registry, db, vd))
-	http.Handle("DELETE /obj/{urn}", deleteObject(db))
+	route("PUT /obj/{urn}", saveObject(registry, db, vd))
+	route("DELETE /obj/{urn}", deleteObject(registry, db))
+
+	// Export and import of the objects of a kind
+	route("GET /export/{kind}", exportObjects(registry, db))
+	route("GET /import/{kind}", importForm(registry, db))
+	route("POST /import/{kind}", importObjects(registry, db, vd))
+	route("GET /csv/{kind}", exportCSV(registry, db))
 
 	// Search and listing endpoints
-	http.Handle("GET /search/{kind}", search(registry, db))
-	http.Handle("POST /search/{kind}", search(registry, db))
+	route("GET /search/{kind}", search(registry, db))
+	route("POST /search/{kind}", search(registry, db))
+
+	// JSON REST API
+	apiRoutes(route, registry, db, vd)
+
+	// Wrap with the custom middleware, the first one being the outermost
+	handler := withCSRF(mux)
+	if o.offline {
+		handler = withOffline(handler)
+	}
+
+	handler = withPrefix(o.prefix, handler)
+	for i := len(o.middleware) - 1; i >= 0; i-- {
+		handler = o.middleware[i](handler)
+	}
+
+	return handler
+}
+
+// ListenAndServe starts the server on the given port.
+func ListenAndServe(port int, registry folio.Registry, db folio.Storage, opts ...Option) error {
+	return Serve(context.Background(), port, registry, db, opts...)
+}
+
+// Serve starts the server on the given port and gracefully shuts it down once the context
+// is cancelled, waiting for the active requests to complete.
+func Serve(ctx context.Context, port int, registry folio.Registry, db folio.Storage, opts ...Option) error {
+	o := new(options)
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	// The pages can not be rendered offline without the vendored assets
+	if missing := missingAssets(); o.offline && len(missing) > 0 {
+		return fmt.Errorf("render: offline assets are missing (%s), run go generate in the render package",
+			strings.Join(missing, ", "))
+	}
 
 	// Create a new server instance with options from environment variables.
 	// For more information, see https://blog.cloudflare.com/the-complete-guide-to-golang-net-http-timeouts/
-	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", port),
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+	server := o.server
+	if server == nil {
+		server = &http.Server{
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		}
+	}
+
+	if server.Addr == "" {
+		server.Addr = fmt.Sprintf(":%d", port)
+	}
+	if server.Handler == nil {
+		server.Handler = New(registry, db, opts...)
+	}
+
+	// Shut down the server once the context is done
+	stopped := make(chan error, 1)
+	stop := context.AfterFunc(ctx, func() {
+		timeout, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		stopped <- server.Shutdown(timeout)
+	})
+	defer stop()
+
+	switch err := server.ListenAndServe(); {
+	case err == http.ErrServerClosed && ctx.Err() != nil:
+		return <-stopped
+	default:
+		return err
+	}
+}
+
+// ---------------------------------- Prefix ----------------------------------
+
+type prefixKey struct{}
+
+// withPrefix strips the URL prefix from the requests and attaches it to their context, so
+// that the links can be generated accordingly.
+func withPrefix(prefix string, next http.Handler) http.Handler {
+	if prefix == "" {
+		return next
 	}
 
-	return server.ListenAndServe()
+	next = http.StripPrefix(prefix, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == prefix {
+			http.Redirect(w, r, prefix+"/", http.StatusMovedPermanently)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), prefixKey{}, prefix)))
+	})
+}
+
+// link returns the URL of the path, under the prefix the handler is mounted on.
+func link(ctx context.Context, path string) string {
+	prefix, _ := ctx.Value(prefixKey{}).(string)
+	return prefix + path
 }
 
 // serveStatic handles a custom handler for serve embed static folder.
@@ -115,9 +307,9 @@ func handle(fn func(r *http.Request, w *Response) error) http.Handler {
 		w.Header().Set("Content-Type", "text/html")
 
 		if err := fn(r, hx); err != nil {
-			if httpErr := err.(interface {
+			if httpErr, ok := err.(interface {
 				HTTP() int
-			}); httpErr != nil {
+			}); ok {
 				http.Error(w, err.Error(), httpErr.HTTP())
 				return
 			}
@@ -0,0 +1,692 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/errors"
+)
+
+const (
+	apiLimit    = 50   // Default number of objects per page
+	apiMaxLimit = 1000 // Maximum number of objects per page
+)
+
+// apiRoutes registers the JSON REST API on the router.
+func apiRoutes(route func(string, http.Handler), registry folio.Registry, db folio.Storage, vd errors.Validator) {
+	route("GET /api/v1/openapi.json", openAPI(registry))
+	route("GET /api/v1/{kind}", apiList(registry, db))
+	route("POST /api/v1/{kind}", apiCreate(registry, db, vd))
+	route("GET /api/v1/obj/{urn}", apiFetch(registry, db))
+	route("PUT /api/v1/obj/{urn}", apiUpdate(registry, db, vd))
+	route("PATCH /api/v1/obj/{urn}", apiPatch(registry, db, vd))
+	route("DELETE /api/v1/obj/{urn}", apiDelete(registry, db))
+	route("GET /api/v1/graphql", graphQL(registry, db, vd))
+	route("POST /api/v1/graphql", graphQL(registry, db, vd))
+	route("GET /api/v1/schema.graphql", graphQLSchema(registry))
+}
+
+// apiList lists the objects of a kind, filtered by the query parameters.
+func apiList(registry folio.Registry, db folio.Storage) http.Handler {
+	return handleAPI(func(r *http.Request, w http.ResponseWriter) error {
+		rx, err := newContext(ModeView, r, registry, db)
+		if err != nil {
+			return err
+		}
+
+		query, err := apiQuery(r)
+		if err != nil {
+			return errors.BadRequest("invalid query, %v", err)
+		}
+
+		// Count all of the matching objects, regardless of the page
+		count := query
+		count.Offset, count.Limit, count.SortBy = 0, 0, nil
+		total, err := rx.Store.Count(rx.Kind, count)
+		if err != nil {
+			return err
+		}
+
+		found, err := rx.Store.Search(rx.Kind, query)
+		if err != nil {
+			return err
+		}
+
+		// Collect the objects first, as resolving the access levels may query the storage
+		items := make([]map[string]any, 0, query.Limit)
+		for _, v := range slices.Collect(found) {
+			item, err := visibleOf(rx, v)
+			if err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+
+		return writeJSON(w, http.StatusOK, map[string]any{
+			"items":  items,
+			"total":  total,
+			"offset": query.Offset,
+			"limit":  query.Limit,
+		})
+	})
+}
+
+// apiFetch returns a single object.
+func apiFetch(registry folio.Registry, db folio.Storage) http.Handler {
+	return handleAPI(func(r *http.Request, w http.ResponseWriter) error {
+		rx, err := newContext(ModeView, r, registry, db)
+		if err != nil {
+			return err
+		}
+
+		obj, err := rx.Store.Fetch(rx.URN)
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("ETag", etagOf(obj))
+		return writeVisible(w, http.StatusOK, rx, obj)
+	})
+}
+
+// apiCreate creates a new object of a kind. The namespace is taken from the body, or the
+// "ns" query parameter, and a new identifier is always generated.
+func apiCreate(registry folio.Registry, db folio.Storage, vd errors.Validator) http.Handler {
+	return handleAPI(func(r *http.Request, w http.ResponseWriter) error {
+		rx, err := newContext(ModeCreate, r, registry, db)
+		if err != nil {
+			return err
+		}
+
+		body, err := readObject(r.Body)
+		if err != nil {
+			return err
+		}
+
+		ns := r.URL.Query().Get("ns")
+		if v, ok := body["namespace"].(string); ok && v != "" {
+			ns = v
+		}
+
+		urn, err := folio.NewURN(ns, rx.Kind)
+		if err != nil {
+			return errors.BadRequest("invalid namespace, %v", err)
+		}
+
+		rx.URN, rx.Namespace = urn, ns
+		obj, err := decodeObject(rx, body, nil)
+		if err != nil {
+			return err
+		}
+
+		if err := validateObject(vd, obj); err != nil {
+			return err
+		}
+
+		bearer, err := mintNew(rx, obj)
+		if err != nil {
+			return err
+		}
+
+		created, err := rx.Store.Insert(obj, rx.username())
+		if err != nil {
+			return err
+		}
+
+		data, err := visibleOf(rx, created)
+		if err != nil {
+			return err
+		}
+
+		// The secret of a new token is returned once, as only its hash is kept
+		if bearer != "" {
+			data["secret"] = bearer
+		}
+
+		w.Header().Set("Location", link(r.Context(), "/api/v1/obj/"+created.URN().String()))
+		w.Header().Set("ETag", etagOf(created))
+		return writeJSON(w, http.StatusCreated, data)
+	})
+}
+
+// mintNew mints the secret of the object being created if it is a token, and returns the
+// bearer value or an empty string for the other kinds.
+func mintNew(rx *Context, obj folio.Object) (string, error) {
+	if token, ok := obj.(*folio.Token); ok {
+		return mintToken(rx, token)
+	}
+	return "", nil
+}
+
+// apiUpdate replaces an existing object.
+func apiUpdate(registry folio.Registry, db folio.Storage, vd errors.Validator) http.Handler {
+	return handleAPI(func(r *http.Request, w http.ResponseWriter) error {
+		body, err := readObject(r.Body)
+		if err != nil {
+			return err
+		}
+
+		return apiSave(w, r, registry, db, vd, func(map[string]any) map[string]any {
+			return body
+		})
+	})
+}
+
+// apiPatch applies a JSON merge patch (RFC 7396) to an existing object.
+func apiPatch(registry folio.Registry, db folio.Storage, vd errors.Validator) http.Handler {
+	return handleAPI(func(r *http.Request, w http.ResponseWriter) error {
+		patch, err := readObject(r.Body)
+		if err != nil {
+			return err
+		}
+
+		return apiSave(w, r, registry, db, vd, func(current map[string]any) map[string]any {
+			return mergePatch(current, patch).(map[string]any)
+		})
+	})
+}
+
+// apiSave updates an existing object with the body produced from its current JSON.
+func apiSave(w http.ResponseWriter, r *http.Request, registry folio.Registry, db folio.Storage, vd errors.Validator, next func(map[string]any) map[string]any) error {
+	rx, err := newContext(ModeEdit, r, registry, db)
+	if err != nil {
+		return err
+	}
+
+	current, err := rx.Store.Fetch(rx.URN)
+	if err != nil {
+		return err
+	}
+
+	if err := ifMatch(r, current); err != nil {
+		return err
+	}
+
+	// Start from the current state of the object, so that a patch can be applied
+	state, err := encodeObject(current)
+	if err != nil {
+		return err
+	}
+
+	obj, err := decodeObject(rx, next(state), current)
+	if err != nil {
+		return err
+	}
+
+	if err := validateObject(vd, obj); err != nil {
+		return err
+	}
+
+	updated, err := rx.Store.Update(obj, rx.username())
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", etagOf(updated))
+	return writeVisible(w, http.StatusOK, rx, updated)
+}
+
+// apiDelete deletes an existing object.
+func apiDelete(registry folio.Registry, db folio.Storage) http.Handler {
+	return handleAPI(func(r *http.Request, w http.ResponseWriter) error {
+		rx, err := newContext(ModeView, r, registry, db)
+		if err != nil {
+			return err
+		}
+
+		current, err := rx.Store.Fetch(rx.URN)
+		if err != nil {
+			return err
+		}
+
+		if err := ifMatch(r, current); err != nil {
+			return err
+		}
+
+		deleted, err := rx.Store.Delete(rx.URN, rx.username())
+		if err != nil {
+			return err
+		}
+
+		return writeVisible(w, http.StatusOK, rx, deleted)
+	})
+}
+
+// ---------------------------------- Query ----------------------------------
+
+// apiQuery builds the query from the request parameters. Filters are specified as "field:value"
+// and can be repeated, while states and sort fields are separated by commas.
+func apiQuery(r *http.Request) (folio.Query, error) {
+	params := r.URL.Query()
+	query := folio.Query{
+		Namespace: params.Get("ns"),
+		Match:     params.Get("match"),
+		Limit:     apiLimit,
+	}
+
+	if query.Namespace == "*" {
+		query.Namespace = ""
+	}
+
+	if v := params.Get("state"); v != "" {
+		query.States = strings.Split(v, ",")
+	}
+
+	if v := params.Get("sort"); v != "" {
+		query.SortBy = strings.Split(v, ",")
+	}
+
+	for _, filter := range params["filter"] {
+		field, value, ok := strings.Cut(filter, ":")
+		if !ok || field == "" {
+			return query, fmt.Errorf("invalid filter '%s', expected field:value", filter)
+		}
+
+		if query.Filters == nil {
+			query.Filters = make(map[string][]string)
+		}
+		query.Filters[field] = append(query.Filters[field], value)
+	}
+
+	var err error
+	if v := params.Get("offset"); v != "" {
+		if query.Offset, err = strconv.Atoi(v); err != nil || query.Offset < 0 {
+			return query, fmt.Errorf("invalid offset '%s'", v)
+		}
+	}
+
+	if v := params.Get("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit <= 0 || query.Limit > apiMaxLimit {
+			return query, fmt.Errorf("invalid limit '%s', expected 1 to %d", v, apiMaxLimit)
+		}
+	}
+
+	return query, nil
+}
+
+// ---------------------------------- Encoding ----------------------------------
+
+// readObject reads a JSON object from the body, keeping the numbers intact.
+func readObject(reader io.Reader) (map[string]any, error) {
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+
+	var out map[string]any
+	if err := decoder.Decode(&out); err != nil || out == nil {
+		return nil, errors.BadRequest("unable to decode request, expected a JSON object")
+	}
+	return out, nil
+}
+
+// encodeObject encodes the object as a generic JSON object.
+func encodeObject(obj folio.Object) (map[string]any, error) {
+	data, err := folio.ToJSON(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return readObject(bytes.NewReader(data))
+}
+
+// visibleOf encodes the object as a generic JSON object, without the fields which are hidden
+// from the principal. The access levels are resolved in the namespace of the object.
+func visibleOf(rx *Context, obj folio.Object) (map[string]any, error) {
+	data, err := encodeObject(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	redact(rx.in(obj.URN().Namespace), reflect.TypeOf(obj), data)
+	return data, nil
+}
+
+// redact removes the fields which are hidden from the principal from the decoded JSON value
+// of the type, including the ones of nested structs, lists and maps. Secrets are removed for
+// everyone, as they can only be read through the reveal handler which audits every access.
+func redact(rx *Context, typ reflect.Type, value any) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		switch typ.Kind() {
+		case reflect.Struct:
+			for i := 0; i < typ.NumField(); i++ {
+				field := typ.Field(i)
+				switch {
+				case !field.IsExported() || field.Type == typeMeta:
+					continue
+				case field.Anonymous:
+					redact(rx, field.Type, v)
+				case isSecret(field):
+					delete(v, jsonName(field))
+				case field.Tag.Get("form") != "" && rx.levelOf(field) == levelHidden:
+					delete(v, jsonName(field))
+				default:
+					redact(rx, field.Type, v[jsonName(field)])
+				}
+			}
+		case reflect.Map:
+			for _, item := range v {
+				redact(rx, typ.Elem(), item)
+			}
+		}
+	case []any:
+		if typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+			for _, item := range v {
+				redact(rx, typ.Elem(), item)
+			}
+		}
+	}
+}
+
+// decodeObject decodes the body into an object identified by the URN of the context. The
+// metadata is managed by the server, and fields which are not writable by the principal must
+// not be changed from the current object (or from their zero value, when creating).
+func decodeObject(rx *Context, body map[string]any, current folio.Object) (folio.Object, error) {
+	body["id"] = rx.URN.ID
+	body["kind"] = rx.URN.Kind
+	body["namespace"] = rx.URN.Namespace
+	delete(body, "createdBy")
+	delete(body, "createdAt")
+	delete(body, "updatedBy")
+	delete(body, "updatedAt")
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := folio.FromJSON(rx.Registry, data)
+	if err != nil {
+		return nil, errors.BadRequest("unable to decode request, %v", err)
+	}
+
+	// Keep the metadata, including the version used for the optimistic concurrency check
+	if current != nil {
+		src := reflect.ValueOf(current).Elem().FieldByName("Meta")
+		reflect.ValueOf(obj).Elem().FieldByName("Meta").Set(src)
+	}
+
+	if err := guardObject(rx, obj, current); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+// guardObject checks that the fields which are not writable by the principal are unchanged
+// from the current object, or from their zero value when creating. Passwords are never
+// encoded, so an empty one keeps the current password. Likewise, the empty secrets and fields
+// which are hidden from the principal keep their current value.
+func guardObject(rx *Context, obj, current folio.Object) error {
+	before := reflect.New(reflect.TypeOf(obj).Elem()).Interface().(folio.Object)
+	if current != nil {
+		before = current
+	}
+
+	rx = rx.in(obj.URN().Namespace)
+	folio.KeepPasswords(obj, before)
+	keepHidden(rx, reflect.ValueOf(before).Elem(), reflect.ValueOf(obj).Elem())
+	if path, ok := isGuarded(rx, reflect.ValueOf(before).Elem(), reflect.ValueOf(obj).Elem(), ""); !ok {
+		return errors.Forbidden("unable to write path %s", path)
+	}
+	return nil
+}
+
+// isGuarded checks whether the fields that are not writable by the principal are unchanged.
+func isGuarded(rx *Context, before, after reflect.Value, prefix string) (string, bool) {
+	for i := 0; i < before.NumField(); i++ {
+		field := before.Type().Field(i)
+		tag := field.Tag.Get("form")
+		path := strings.TrimPrefix(prefix+"."+jsonName(field), ".")
+
+		switch {
+		case !field.IsExported() || tag == "" || field.Anonymous:
+			continue
+		case rx.levelOf(field) != levelReadWrite:
+			if !reflect.DeepEqual(before.Field(i).Interface(), after.Field(i).Interface()) {
+				return path, false
+			}
+		default:
+			if path, ok := isGuardedValue(rx, before.Field(i), after.Field(i), path); !ok {
+				return path, false
+			}
+		}
+	}
+
+	return "", true
+}
+
+// isGuardedValue checks the structs contained in the values, within pointers, lists and arrays.
+// The elements are compared by position, missing ones being compared with their zero value.
+func isGuardedValue(rx *Context, before, after reflect.Value, path string) (string, bool) {
+	if !hasStruct(before.Type()) {
+		return "", true
+	}
+
+	switch before.Kind() {
+	case reflect.Pointer:
+		return isGuardedValue(rx, elemOf(before), elemOf(after), path)
+	case reflect.Struct:
+		return isGuarded(rx, before, after, path)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < max(before.Len(), after.Len()); i++ {
+			if path, ok := isGuardedValue(rx, indexOf(before, i), indexOf(after, i), path+"."+strconv.Itoa(i)); !ok {
+				return path, false
+			}
+		}
+	}
+
+	return "", true
+}
+
+// keepHidden copies the fields which are hidden from the principal and left empty from the
+// current object, as the principal can not send them back. The same goes for secrets, which
+// are never encoded. Elements of lists are matched by position.
+func keepHidden(rx *Context, before, after reflect.Value) {
+	switch before.Kind() {
+	case reflect.Pointer:
+		if !before.IsNil() && !after.IsNil() {
+			keepHidden(rx, before.Elem(), after.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < min(before.Len(), after.Len()); i++ {
+			keepHidden(rx, before.Index(i), after.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < before.NumField(); i++ {
+			field := before.Type().Field(i)
+			switch {
+			case !field.IsExported() || field.Anonymous:
+				continue
+			case isSecret(field), field.Tag.Get("form") != "" && rx.levelOf(field) == levelHidden:
+				if after.Field(i).IsZero() {
+					after.Field(i).Set(before.Field(i))
+				}
+			case field.Tag.Get("form") == "":
+				continue
+			case hasStruct(field.Type):
+				keepHidden(rx, before.Field(i), after.Field(i))
+			}
+		}
+	}
+}
+
+// hasStruct returns whether values of the type may contain structs, within pointers, lists
+// and arrays.
+func hasStruct(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Struct
+}
+
+// elemOf returns the value the pointer points to, or the zero value if it is nil.
+func elemOf(v reflect.Value) reflect.Value {
+	if v.IsNil() {
+		return reflect.Zero(v.Type().Elem())
+	}
+	return v.Elem()
+}
+
+// indexOf returns the element at the index, or the zero value if it is out of range.
+func indexOf(v reflect.Value, i int) reflect.Value {
+	if i >= v.Len() {
+		return reflect.Zero(v.Type().Elem())
+	}
+	return v.Index(i)
+}
+
+// jsonName returns the JSON name of the field.
+func jsonName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" {
+		return name
+	}
+	return field.Name
+}
+
+// mergePatch applies a JSON merge patch (RFC 7396) to the target.
+func mergePatch(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = make(map[string]any, len(patchObj))
+	}
+
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+	return targetObj
+}
+
+// validateObject validates the object and returns the validation errors, if any.
+func validateObject(vd errors.Validator, obj folio.Object) error {
+	if validations, ok := vd.Validate(obj); !ok {
+		return &apiError{
+			Status:      http.StatusBadRequest,
+			Message:     "validation failed",
+			Validations: validations,
+		}
+	}
+	return nil
+}
+
+// ---------------------------------- Versioning ----------------------------------
+
+// etagOf returns the entity tag of the object, based on its last update.
+func etagOf(obj folio.Object) string {
+	_, updatedAt := obj.Updated()
+	return strconv.Quote(strconv.FormatInt(updatedAt.UnixNano(), 10))
+}
+
+// ifMatch checks the If-Match precondition against the current version of the object. A stale
+// version fails the precondition, while conflicts detected by the storage remain conflicts.
+func ifMatch(r *http.Request, current folio.Object) error {
+	header := r.Header.Get("If-Match")
+	if header == "" || header == "*" {
+		return nil
+	}
+
+	etag := etagOf(current)
+	for _, v := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(v), "W/") == etag {
+			return nil
+		}
+	}
+
+	return errors.PreconditionFailed("version of %s does not match %s", current.URN(), header)
+}
+
+// ---------------------------------- Response ----------------------------------
+
+// apiError represents an error returned by the API.
+type apiError struct {
+	Status      int                 `json:"status"`
+	Message     string              `json:"error"`
+	Validations []errors.Validation `json:"validations,omitempty"`
+}
+
+// Error returns the error message.
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+// handleAPI handles a JSON API request, mapping the errors to their status codes.
+func handleAPI(fn func(r *http.Request, w http.ResponseWriter) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := fn(r, w)
+		if err == nil {
+			return
+		}
+
+		out, ok := err.(*apiError)
+		if !ok {
+			out = &apiError{Status: statusOf(err), Message: err.Error()}
+		}
+
+		if out.Status == http.StatusInternalServerError {
+			slog.Error("error", "error", err)
+		}
+
+		writeJSON(w, out.Status, out)
+	})
+}
+
+// statusOf returns the HTTP status code for the error.
+func statusOf(err error) int {
+	if httpErr, ok := err.(interface{ HTTP() int }); ok {
+		return httpErr.HTTP()
+	}
+
+	switch {
+	case folio.IsNotFound(err):
+		return http.StatusNotFound
+	case folio.IsConflict(err):
+		return http.StatusConflict
+	case folio.IsRejected(err):
+		return http.StatusBadRequest
+	case folio.IsForbidden(err):
+		return http.StatusForbidden
+	case folio.IsUnauthorized(err):
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeVisible writes the object as JSON with the status code, without the fields which are
+// hidden from the principal.
+func writeVisible(w http.ResponseWriter, status int, rx *Context, obj folio.Object) error {
+	data, err := visibleOf(rx, obj)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, status, data)
+}
+
+// writeJSON writes the value as JSON with the status code.
+func writeJSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
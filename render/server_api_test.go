@@ -0,0 +1,290 @@
+package render
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+type Ticket struct {
+	folio.Meta `kind:"ticket" json:",inline"`
+	Title      string `json:"title" form:"rw" is:"required"`
+	Points     int    `json:"points" form:"rw"`
+	Owner      string `json:"owner" form:"rw@admin,ro"`
+}
+
+func TestAPI(t *testing.T) {
+	registry := folio.NewRegistry()
+	folio.Register[*Ticket](registry)
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	handler := New(registry, db,
+		WithAuthenticator(AuthenticatorFunc(func(r *http.Request) (*folio.Principal, error) {
+			return &folio.Principal{Name: "alice", Role: folio.Role(r.Header.Get("X-Role"))}, nil
+		})),
+		WithAuthorizer(folio.NewPolicy(db)),
+	)
+
+	call := apiCaller(handler)
+
+	// Validation errors
+	w, out := call(folio.RoleEditor, "POST", "/api/v1/ticket?ns=default", `{"points": 3}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Len(t, out["validations"], 1)
+
+	// Fields that are not writable can not be set
+	w, _ = call(folio.RoleEditor, "POST", "/api/v1/ticket?ns=default", `{"title": "Bug", "owner": "bob"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Create
+	w, out = call(folio.RoleEditor, "POST", "/api/v1/ticket?ns=default", `{"title": "Bug", "points": 3}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "alice", out["createdBy"])
+	location, etag := w.Header().Get("Location"), w.Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(location, "/api/v1/obj/"), location)
+	assert.NotEmpty(t, etag)
+
+	// Fetch
+	w, out = call(folio.RoleViewer, "GET", location, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Bug", out["title"])
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+
+	// List
+	w, out = call(folio.RoleViewer, "GET", "/api/v1/ticket?ns=default&filter=title:Bug&limit=10", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, float64(1), out["total"])
+	assert.Len(t, out["items"], 1)
+
+	w, _ = call(folio.RoleViewer, "GET", "/api/v1/ticket?limit=-1", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Viewers can not write
+	w, _ = call(folio.RoleViewer, "PATCH", location, `{"points": 5}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Patch
+	w, out = call(folio.RoleEditor, "PATCH", location, `{"points": 5}`, "If-Match", etag)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "Bug", out["title"])
+	assert.Equal(t, float64(5), out["points"])
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+
+	// Update with an outdated version
+	w, _ = call(folio.RoleEditor, "PUT", location, `{"title": "Feature"}`, "If-Match", etag)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	// Update
+	w, out = call(folio.RoleAdmin, "PUT", location, `{"title": "Feature", "owner": "bob"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, float64(0), out["points"])
+	assert.Equal(t, "bob", out["owner"])
+
+	// Delete
+	w, _ = call(folio.RoleEditor, "DELETE", location, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w, _ = call(folio.RoleAdmin, "DELETE", location, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w, _ = call(folio.RoleAdmin, "GET", location, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAPI_Password(t *testing.T) {
+	registry := folio.NewRegistry()
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	_, err := folio.Bootstrap(db, "admin", "secret")
+	assert.NoError(t, err)
+	admin, err := folio.FindUser(db, "admin")
+	assert.NoError(t, err)
+
+	handler := New(registry, db,
+		WithAuthenticator(AuthenticatorFunc(func(r *http.Request) (*folio.Principal, error) {
+			return &folio.Principal{Name: "alice", Role: folio.Role(r.Header.Get("X-Role"))}, nil
+		})),
+		WithAuthorizer(folio.NewPolicy(db)),
+	)
+
+	call := func(role folio.Role, body string) int {
+		method := "PATCH"
+		if body == "" {
+			method = "GET"
+		}
+
+		w, _ := apiCaller(handler)(role, method, "/api/v1/obj/"+admin.URN().String(), body)
+		assert.NotContains(t, w.Body.String(), "pbkdf2")
+		return w.Code
+	}
+
+	// The hash of the password is never returned
+	assert.Equal(t, http.StatusOK, call(folio.RoleAdmin, ""))
+
+	// Other fields can be changed while keeping the password
+	assert.Equal(t, http.StatusOK, call(folio.RoleAdmin, `{"email": "admin@example.com"}`))
+	_, err = folio.Authenticate(db, "admin", "secret")
+	assert.NoError(t, err)
+
+	// Editors can not change users, including their passwords
+	assert.Equal(t, http.StatusForbidden, call(folio.RoleEditor, `{"email": "eve@example.com"}`))
+	assert.Equal(t, http.StatusForbidden, call(folio.RoleEditor, `{"password": "hijacked"}`))
+	_, err = folio.Authenticate(db, "admin", "secret")
+	assert.NoError(t, err)
+
+	// Administrators can
+	assert.Equal(t, http.StatusOK, call(folio.RoleAdmin, `{"password": "changed"}`))
+	_, err = folio.Authenticate(db, "admin", "changed")
+	assert.NoError(t, err)
+}
+
+type Incident struct {
+	folio.Meta `kind:"incident" json:",inline"`
+	Title      string `json:"title" form:"rw"`
+	Notes      string `json:"notes" form:"rw@admin"`
+	Steps      []Step `json:"steps" form:"rw"`
+	Lead       *Step  `json:"lead" form:"rw"`
+}
+
+type Step struct {
+	Name     string `json:"name" form:"rw"`
+	Approved bool   `json:"approved" form:"rw@admin,ro"`
+	Note     string `json:"note" form:"rw@admin"`
+}
+
+func TestAPI_Hidden(t *testing.T) {
+	registry := folio.NewRegistry()
+	folio.Register[*Incident](registry)
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	call := apiCaller(New(registry, db,
+		WithAuthenticator(AuthenticatorFunc(func(r *http.Request) (*folio.Principal, error) {
+			return &folio.Principal{Name: "alice", Role: folio.Role(r.Header.Get("X-Role"))}, nil
+		})),
+		WithAuthorizer(folio.NewPolicy(db)),
+	))
+
+	w, _ := call(folio.RoleAdmin, "POST", "/api/v1/incident?ns=default", `{
+		"title": "Outage", "notes": "secret",
+		"steps": [{"name": "restart", "approved": true, "note": "secret"}],
+		"lead": {"name": "bob", "approved": true, "note": "secret"}
+	}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	location := w.Header().Get("Location")
+
+	// Hidden fields are not returned, including the ones of nested structs
+	for _, path := range []string{location, "/api/v1/incident?ns=default"} {
+		w, _ = call(folio.RoleEditor, "GET", path, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "secret")
+		assert.Contains(t, w.Body.String(), "restart")
+	}
+
+	w, _ = call(folio.RoleAdmin, "GET", location, "")
+	assert.Contains(t, w.Body.String(), "secret")
+
+	// Hidden fields which are not sent keep their value
+	w, _ = call(folio.RoleEditor, "PUT", location, `{
+		"title": "Incident",
+		"steps": [{"name": "restart", "approved": true}],
+		"lead": {"name": "bob", "approved": true}
+	}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "secret")
+
+	_, out := call(folio.RoleAdmin, "GET", location, "")
+	assert.Equal(t, "Incident", out["title"])
+	assert.Equal(t, "secret", out["notes"])
+	assert.Equal(t, "secret", out["lead"].(map[string]any)["note"])
+
+	// Fields which are not writable can not be changed within lists and pointers
+	for _, patch := range []string{
+		`{"notes": "changed"}`,
+		`{"steps": [{"name": "restart", "approved": false}]}`,
+		`{"steps": [{"name": "restart", "approved": true}, {"name": "other", "approved": true}]}`,
+		`{"lead": {"approved": false}}`,
+		`{"lead": {"note": "changed"}}`,
+	} {
+		w, _ = call(folio.RoleEditor, "PATCH", location, patch)
+		assert.Equal(t, http.StatusForbidden, w.Code, patch)
+	}
+
+	w, _ = call(folio.RoleEditor, "PATCH", location, `{"lead": {"name": "carol"}}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+// apiCaller returns a function which calls the API of the handler, with the role of the
+// principal passed in the X-Role header.
+func TestAPI_Token(t *testing.T) {
+	registry := folio.NewRegistry()
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	call := apiCaller(New(registry, db,
+		WithAuthenticator(AuthenticatorFunc(func(r *http.Request) (*folio.Principal, error) {
+			return &folio.Principal{Name: "alice", Role: folio.Role(r.Header.Get("X-Role"))}, nil
+		})),
+		WithAuthorizer(folio.NewPolicy(db)),
+	))
+
+	// verify checks that the bearer value authenticates the token owned by alice
+	verify := func(bearer any) string {
+		id, secret, _ := strings.Cut(bearer.(string), ".")
+		token, err := folio.Fetch[*folio.Token](db, folio.URN{Namespace: "default", Kind: "token", ID: id})
+		assert.NoError(t, err)
+		assert.Equal(t, "alice", token.Owner)
+		assert.True(t, token.Verify(secret))
+		return token.URN().String()
+	}
+
+	// The secret is minted when created through the API, and returned only once
+	w, out := call(folio.RoleAdmin, "POST", "/api/v1/token?ns=default", `{"name": "ci", "secret": ""}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	urn := verify(out["secret"])
+
+	w, out = call(folio.RoleAdmin, "GET", "/api/v1/obj/"+urn, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, out, "secret")
+
+	// Likewise through GraphQL
+	body, _ := json.Marshal(map[string]any{"query": `mutation {
+		createToken(namespace: "default", input: {name: "deploy"}) { urn secret }
+	}`})
+	w, out = call(folio.RoleAdmin, "POST", "/api/v1/graphql", string(body))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	created := out["data"].(map[string]any)["createToken"].(map[string]any)
+	assert.Equal(t, created["urn"], verify(created["secret"]))
+}
+
+func apiCaller(handler http.Handler) func(role folio.Role, method, path, body string, headers ...string) (*httptest.ResponseRecorder, map[string]any) {
+	return func(role folio.Role, method, path, body string, headers ...string) (*httptest.ResponseRecorder, map[string]any) {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("X-Role", string(role))
+		r.Header.Set("Content-Type", "application/json")
+		r.AddCookie(&http.Cookie{Name: csrfCookie, Value: "0123456789abcdefghijklmnopqrstuv"})
+		r.Header.Set(csrfHeader, "0123456789abcdefghijklmnopqrstuv")
+		for i := 0; i < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		var out map[string]any
+		json.Unmarshal(w.Body.Bytes(), &out)
+		return w, out
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	target := map[string]any{"a": "b", "c": map[string]any{"d": "e", "f": "g"}}
+	patch := map[string]any{"a": "z", "c": map[string]any{"f": nil}}
+	assert.Equal(t, map[string]any{"a": "z", "c": map[string]any{"d": "e"}}, mergePatch(target, patch))
+}
@@ -0,0 +1,59 @@
+package render
+
+//go:generate go run ./internal/bundle
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"net/http"
+)
+
+// vendored represents the frontend assets served from the embedded file system in offline
+// mode. They are downloaded and compiled by running "go generate" in this package.
+var vendored = []string{
+	"assets/vendor/tailwind.css",
+	"assets/vendor/franken-core.min.css",
+	"assets/vendor/franken-utilities.min.css",
+	"assets/vendor/htmx.min.js",
+	"assets/vendor/franken-core.iife.js",
+	"assets/vendor/franken-icon.iife.js",
+}
+
+// WithOffline serves the frontend assets from the embedded file system instead of public
+// CDNs, and restricts the Content-Security-Policy to the same origin. This is required for
+// air-gapped networks.
+func WithOffline() Option {
+	return func(o *options) {
+		o.offline = true
+	}
+}
+
+type offlineKey struct{}
+
+// withOffline marks the requests as offline, so that the layout uses the vendored assets.
+func withOffline(next http.Handler) http.Handler {
+	for _, name := range missingAssets() {
+		slog.Error("offline asset is missing, run go generate in the render package", "asset", name)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), offlineKey{}, true)))
+	})
+}
+
+// missingAssets returns the vendored assets which are missing from the embedded file system.
+func missingAssets() (missing []string) {
+	for _, name := range vendored {
+		if _, err := fs.Stat(assets, name); err != nil {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// isOffline returns true if the page is rendered in offline mode.
+func isOffline(ctx context.Context) bool {
+	offline, _ := ctx.Value(offlineKey{}).(bool)
+	return offline
+}
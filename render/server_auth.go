@@ -0,0 +1,98 @@
+package render
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/errors"
+)
+
+// Authenticator identifies the principal performing the request.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*folio.Principal, error)
+}
+
+// AuthenticatorFunc is an adapter to allow the use of ordinary functions as authenticators.
+type AuthenticatorFunc func(r *http.Request) (*folio.Principal, error)
+
+// Authenticate calls fn(r).
+func (fn AuthenticatorFunc) Authenticate(r *http.Request) (*folio.Principal, error) {
+	return fn(r)
+}
+
+// WithAuthenticator sets the authenticator used to identify the principal of every request.
+func WithAuthenticator(auth Authenticator) Option {
+	return func(o *options) {
+		o.authenticator = auth
+	}
+}
+
+// WithAuthorizer sets the authorizer used to check the permissions of the principal. If no
+// authorizer is provided, everyone is allowed to do everything.
+func WithAuthorizer(authz folio.Authorizer) Option {
+	return func(o *options) {
+		o.authorizer = authz
+	}
+}
+
+// ---------------------------------- Access ----------------------------------
+
+type accessKey struct{}
+
+// access represents the principal of the request along with its authorizer.
+type access struct {
+	who   *folio.Principal
+	authz folio.Authorizer
+}
+
+// withAccess authenticates the request and attaches the principal to its context.
+func withAccess(o *options, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// API tokens are accepted on every route and never redirect to the login page
+		if bearer, ok := bearerOf(r); ok {
+			acc, err := tokenAccess(o, bearer)
+			if err != nil {
+				http.Error(w, errors.Unauthorized("unable to authenticate, %v", err).Error(), http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), accessKey{}, acc)))
+			return
+		}
+
+		var who *folio.Principal
+		if o.authenticator != nil {
+			principal, err := o.authenticator.Authenticate(r)
+			switch {
+			case err != nil && o.sessions != nil && !isAPI(r):
+				redirect(w, r, link(r.Context(), "/login"))
+				return
+			case err != nil:
+				http.Error(w, errors.Unauthorized("unable to authenticate, %v", err).Error(), http.StatusUnauthorized)
+				return
+			}
+
+			who = principal
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), accessKey{}, &access{
+			who:   who,
+			authz: o.authorizer,
+		})))
+	})
+}
+
+// isAPI returns true if the request is made against the JSON API, which never redirects.
+func isAPI(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/")
+}
+
+// accessOf returns the access information attached to the request.
+func accessOf(r *http.Request) *access {
+	if v, ok := r.Context().Value(accessKey{}).(*access); ok {
+		return v
+	}
+	return &access{}
+}
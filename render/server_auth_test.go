@@ -0,0 +1,100 @@
+package render
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContext_Can(t *testing.T) {
+	urn := folio.URN{Namespace: "ops", Kind: "person"}
+	rx := &Context{
+		User: &folio.Principal{Name: "alice"},
+		Access: folio.NewPolicy(nil,
+			folio.Grant{Subject: "alice", Role: folio.RoleEditor, Namespace: "ops"},
+		),
+	}
+
+	assert.True(t, rx.Can(folio.ActionRead, urn))
+	assert.True(t, rx.Can(folio.ActionWrite, urn))
+	assert.False(t, rx.Can(folio.ActionDelete, urn))
+	assert.False(t, rx.Can(folio.ActionRead, folio.URN{Namespace: "dev", Kind: "person"}))
+	assert.Equal(t, "alice", rx.username())
+
+	// Without an authorizer, everything is allowed
+	assert.True(t, (&Context{}).Can(folio.ActionDelete, urn))
+	assert.Equal(t, "sys", (&Context{}).username())
+}
+
+func TestWithAccess(t *testing.T) {
+	auth := AuthenticatorFunc(func(r *http.Request) (*folio.Principal, error) {
+		if name := r.Header.Get("X-User"); name != "" {
+			return &folio.Principal{Name: name}, nil
+		}
+		return nil, fmt.Errorf("no user")
+	})
+
+	var who *folio.Principal
+	handler := withAccess(&options{authenticator: auth}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		who = accessOf(r).who
+	}))
+
+	// Unauthenticated request
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Nil(t, who)
+
+	// Authenticated request
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-User", "alice")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "alice", who.Name)
+}
+
+func TestWithAccess_Bearer(t *testing.T) {
+	db := sqlite.OpenEphemeral(folio.NewRegistry())
+	defer db.Close()
+
+	var bearer string
+	_, err := folio.Create(db, func(v *folio.Token) (err error) {
+		v.Name = "ci"
+		v.Owner = "robot"
+		v.Scopes = []folio.Scope{{Namespace: "ops", Access: "read"}}
+		bearer, err = v.Mint()
+		return
+	}, "default", "admin")
+	assert.NoError(t, err)
+
+	var acc *access
+	o := &options{store: db, sessions: newSessions(nil)}
+	handler := withAccess(o, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acc = accessOf(r)
+	}))
+
+	// Invalid tokens are rejected without a redirect
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer invalid")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Nil(t, acc)
+
+	// Valid token acts on behalf of its owner, restricted to its scopes
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+bearer)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "robot", acc.who.Name)
+	assert.True(t, folio.Can(acc.authz, acc.who, folio.ActionRead, "ops", "app"))
+	assert.False(t, folio.Can(acc.authz, acc.who, folio.ActionWrite, "ops", "app"))
+	assert.False(t, folio.Can(acc.authz, acc.who, folio.ActionRead, "dev", "app"))
+}
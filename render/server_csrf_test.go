@@ -0,0 +1,85 @@
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSRF(t *testing.T) {
+	var token string
+	handler := withCSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = csrfToken(r.Context())
+	}))
+
+	// Safe requests get a new token
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, token)
+	cookie := w.Result().Cookies()[0]
+	assert.Equal(t, csrfCookie, cookie.Name)
+	assert.Equal(t, token, cookie.Value)
+
+	// Missing token
+	r := httptest.NewRequest("DELETE", "/obj/x", nil)
+	r.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "CSRF")
+
+	// Wrong token
+	r = httptest.NewRequest("DELETE", "/obj/x", nil)
+	r.AddCookie(cookie)
+	r.Header.Set(csrfHeader, "wrong")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Valid token in a header
+	r = httptest.NewRequest("DELETE", "/obj/x", nil)
+	r.AddCookie(cookie)
+	r.Header.Set(csrfHeader, token)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Valid token in a form
+	r = httptest.NewRequest("POST", "/login", strings.NewReader(url.Values{csrfField: {token}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// API tokens are not subject to the token check
+	r = httptest.NewRequest("DELETE", "/obj/x", nil)
+	r.Header.Set("Authorization", "Bearer abc.def")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCSRF_CrossOrigin(t *testing.T) {
+	handler := withCSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// Cross-site request, even with a valid token
+	r := httptest.NewRequest("PUT", "http://folio.local/obj/x", nil)
+	r.Header.Set("Sec-Fetch-Site", "cross-site")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "cross-origin")
+
+	// Mismatched origin
+	r = httptest.NewRequest("PUT", "http://folio.local/obj/x", nil)
+	r.Header.Set("Origin", "http://evil.local")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
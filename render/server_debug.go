@@ -0,0 +1,86 @@
+package render
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"slices"
+	"strings"
+
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/cache"
+	"github.com/kelindar/folio/errors"
+)
+
+// WithDebug enables the profiling endpoints under "/debug/pprof/" and a diagnostics page under
+// "/debug/". Both are only available to authenticated principals with the admin role.
+func WithDebug() Option {
+	return func(o *options) {
+		o.debug = true
+	}
+}
+
+// withAdmin only lets authenticated administrators through. It must be used after withAccess.
+func withAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acc := accessOf(r)
+		switch {
+		case acc.who == nil:
+			forbidden(w, "requires an authenticated administrator")
+		case acc.authz != nil && acc.authz.RoleOf(acc.who, "", "") != folio.RoleAdmin:
+			forbidden(w, "requires the admin role")
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// ---------------------------------- Diagnostics ----------------------------------
+
+// diagnostic represents a snapshot of the runtime state of the server.
+type diagnostic struct {
+	Version    string           // Version of the Go runtime
+	Goroutines int              // Number of goroutines
+	Memory     runtime.MemStats // Memory statistics
+	Database   *sql.DBStats     // Database statistics, if available
+	Cache      *cache.Stats     // Cache statistics, if the storage is cached
+	Types      []folio.Type     // Types in the registry
+	Stacks     string           // Stacks of all goroutines
+}
+
+// diagnostics renders the diagnostics page.
+func diagnostics(registry folio.Registry, db folio.Storage) http.Handler {
+	return handle(func(r *http.Request, w *Response) error {
+		var stacks bytes.Buffer
+		if err := pprof.Lookup("goroutine").WriteTo(&stacks, 1); err != nil {
+			return errors.Internal("unable to capture goroutines, %v", err)
+		}
+
+		d := &diagnostic{
+			Version:    runtime.Version(),
+			Goroutines: runtime.NumGoroutine(),
+			Types:      slices.Collect(registry.Types()),
+			Stacks:     stacks.String(),
+		}
+
+		runtime.ReadMemStats(&d.Memory)
+		if c, ok := db.(*cache.Storage); ok {
+			stats := c.Stats()
+			d.Cache = &stats
+			db = c.Storage
+		}
+
+		if s, ok := db.(interface{ Stats() sql.DBStats }); ok {
+			stats := s.Stats()
+			d.Database = &stats
+		}
+
+		slices.SortFunc(d.Types, func(a, b folio.Type) int {
+			return strings.Compare(string(a.Kind), string(b.Kind))
+		})
+
+		return w.Render(hxLayout("Folio - Diagnostics", hxDiagnostics(d)))
+	})
+}
@@ -0,0 +1,55 @@
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/cache"
+	"github.com/kelindar/folio/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebug(t *testing.T) {
+	registry := folio.NewRegistry()
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	auth := WithAuthenticator(AuthenticatorFunc(func(r *http.Request) (*folio.Principal, error) {
+		return &folio.Principal{Name: r.Header.Get("X-User"), Role: folio.Role(r.Header.Get("X-Role"))}, nil
+	}))
+	authz := WithAuthorizer(folio.NewPolicy(db))
+	request := func(handler http.Handler, path string, role folio.Role) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("X-User", "alice")
+		r.Header.Set("X-Role", string(role))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// Only administrators can access the diagnostics
+	handler := New(registry, db, auth, authz, WithDebug())
+	assert.Equal(t, http.StatusForbidden, request(handler, "/debug/", folio.RoleEditor).Code)
+	assert.Equal(t, http.StatusForbidden, request(handler, "/debug/pprof/cmdline", folio.RoleEditor).Code)
+
+	w := request(handler, "/debug/", folio.RoleAdmin)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Open Connections")
+	assert.Contains(t, w.Body.String(), "folio.Namespace")
+	assert.Contains(t, w.Body.String(), "goroutine profile")
+	assert.Equal(t, http.StatusOK, request(handler, "/debug/pprof/cmdline", folio.RoleAdmin).Code)
+
+	// The statistics of a cached storage are shown as well
+	w = request(New(registry, cache.New(db, registry), auth, authz, WithDebug()), "/debug/", folio.RoleAdmin)
+	assert.Contains(t, w.Body.String(), "Query Hits")
+	assert.Contains(t, w.Body.String(), "Open Connections")
+
+	// Unauthenticated principals are never allowed
+	assert.Equal(t, http.StatusForbidden, request(New(registry, db, WithDebug()), "/debug/", folio.RoleAdmin).Code)
+
+	// Disabled by default
+	w = request(New(registry, db, auth, authz), "/debug/", folio.RoleAdmin)
+	assert.NotContains(t, w.Body.String(), "Diagnostics")
+}
@@ -0,0 +1,233 @@
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/errors"
+	"github.com/kelindar/folio/internal/convert"
+)
+
+// maxImportSize is the maximum size of an uploaded import file.
+const maxImportSize = 32 << 20
+
+// exportObjects downloads the objects of a kind as newline-delimited JSON, optionally
+// restricted to a namespace. The fields which are hidden from the principal are left out.
+func exportObjects(registry folio.Registry, db folio.Storage) http.Handler {
+	return handle(func(r *http.Request, w *Response) error {
+		rx, err := newContext(ModeView, r, registry, db)
+		switch {
+		case err != nil:
+			return errors.BadRequest("invalid request, %v", err)
+		case !rx.Can(folio.ActionRead, folio.URN{Namespace: rx.Namespace, Kind: rx.Kind}):
+			return errors.Forbidden("not allowed to export %s", rx.Type.Plural)
+		}
+
+		query := folio.Query{}
+		if len(rx.Namespace) > 1 {
+			query.Namespace = rx.Namespace
+		}
+
+		found, err := rx.Store.Search(rx.Kind, query)
+		if err != nil {
+			return errors.Internal("unable to export %s, %v", rx.Type.Plural, err)
+		}
+
+		// Collect the objects first, as resolving the access levels may query the storage
+		w.w.Header().Set("Content-Type", "application/x-ndjson")
+		w.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", rx.Kind.String()+".ndjson"))
+		encoder := json.NewEncoder(w.w)
+		for _, obj := range slices.Collect(found) {
+			data, err := visibleOf(rx, obj)
+			if err == nil {
+				err = encoder.Encode(data)
+			}
+			if err != nil {
+				return errors.Internal("unable to export %s, %v", rx.Type.Plural, err)
+			}
+		}
+		return nil
+	})
+}
+
+// importForm renders the form to upload the objects of a kind.
+func importForm(registry folio.Registry, db folio.Storage) http.Handler {
+	return handle(func(r *http.Request, w *Response) error {
+		rx, err := newContext(ModeCreate, r, registry, db)
+		switch {
+		case err != nil:
+			return errors.BadRequest("invalid request, %v", err)
+		case !rx.Can(folio.ActionWrite, folio.URN{Namespace: rx.Namespace, Kind: rx.Kind}):
+			return errors.Forbidden("not allowed to import %s", rx.Type.Plural)
+		}
+
+		return w.Render(hxImportForm(rx))
+	})
+}
+
+// importObjects restores the objects of a kind from an uploaded newline-delimited JSON file. A
+// CSV file is previewed first, and its content is posted back once the preview is confirmed.
+func importObjects(registry folio.Registry, db folio.Storage, vd errors.Validator) http.Handler {
+	return handle(func(r *http.Request, w *Response) error {
+		rx, err := newContext(ModeCreate, r, registry, db)
+		switch {
+		case err != nil:
+			return errors.BadRequest("invalid request, %v", err)
+		case !rx.Can(folio.ActionWrite, folio.URN{Namespace: rx.Namespace, Kind: rx.Kind}):
+			return errors.Forbidden("not allowed to import %s", rx.Type.Plural)
+		}
+
+		r.Body = http.MaxBytesReader(w.w, r.Body, maxImportSize)
+		conflict := folio.Conflict(r.FormValue("conflict"))
+		if conflict == "" {
+			conflict = folio.ConflictFail
+		}
+
+		// The content of a previewed CSV file is posted back to commit it
+		if data := r.FormValue("csv"); data != "" {
+			rows, err := readCSV(rx, strings.NewReader(data), vd, conflict)
+			if err != nil {
+				return errors.BadRequest("unable to read the CSV file, %v", err)
+			}
+
+			result, err := commitCSV(rx, rows, conflict)
+			return w.Render(hxImportResult(rx, result, err))
+		}
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return errors.BadRequest("unable to read the uploaded file, %v", err)
+		}
+
+		defer file.Close()
+		if strings.EqualFold(filepath.Ext(header.Filename), ".csv") {
+			data, err := io.ReadAll(file)
+			if err != nil {
+				return errors.BadRequest("unable to read the uploaded file, %v", err)
+			}
+
+			rows, err := readCSV(rx, bytes.NewReader(data), vd, conflict)
+			if err != nil {
+				return errors.BadRequest("unable to read the CSV file, %v", err)
+			}
+
+			return w.Render(hxCSVPreview(rx, rows, string(data), conflict))
+		}
+
+		result, err := folio.Import(file, rx.Store, registry, folio.ImportOptions{
+			Conflict: conflict,
+			Kinds:    []folio.Kind{rx.Kind},
+			By:       rx.username(),
+			Check: func(obj, current folio.Object) error {
+				if err := guardObject(rx, obj, current); err != nil {
+					return err
+				}
+				return validationError(vd, obj)
+			},
+		})
+
+		// The objects imported before the error are kept, so the summary is shown either way
+		return w.Render(hxImportResult(rx, result, err))
+	})
+}
+
+// validationError validates the object and returns an error listing its validation errors.
+func validationError(vd errors.Validator, obj folio.Object) error {
+	validations, ok := vd.Validate(obj)
+	if ok {
+		return nil
+	}
+
+	messages := make([]string, 0, len(validations))
+	for _, v := range validations {
+		messages = append(messages, string(v.Path)+": "+v.Message)
+	}
+	return fmt.Errorf("%s", strings.Join(messages, "; "))
+}
+
+// commitCSV saves the objects of the valid rows, the invalid ones being skipped.
+func commitCSV(rx *Context, rows []csvRow, conflict folio.Conflict) (folio.ImportResult, error) {
+	var result folio.ImportResult
+	for _, row := range rows {
+		action := row.Action(conflict)
+		switch action {
+		case csvInvalid, csvSkip:
+			result.Skipped++
+			continue
+		}
+
+		if _, err := folio.Upsert(rx.Store, row.Object, rx.username()); err != nil {
+			return result, fmt.Errorf("unable to save the row on line %d, %w", row.Line, err)
+		}
+
+		if action == csvUpdate {
+			result.Updated++
+		} else {
+			result.Created++
+		}
+	}
+	return result, nil
+}
+
+// ---------------------------------- CSV Export ----------------------------------
+
+// exportCSV downloads the objects of a kind matching the query of the list view as CSV.
+func exportCSV(registry folio.Registry, db folio.Storage) http.Handler {
+	return handle(func(r *http.Request, w *Response) error {
+		rx, err := newContext(ModeView, r, registry, db)
+		if err != nil {
+			return errors.BadRequest("invalid request, %v", err)
+		}
+
+		text, err := base64.URLEncoding.DecodeString(r.URL.Query().Get("filter"))
+		if err != nil {
+			return errors.BadRequest("unable to decode query, %v", err)
+		}
+
+		query, err := folio.ParseQuery(string(text), nil, folio.Query{Namespace: rx.Namespace})
+		switch {
+		case err != nil:
+			return errors.BadRequest("unable to parse query, %v", err)
+		case query.Namespace != "" && !rx.Can(folio.ActionRead, folio.URN{Namespace: query.Namespace, Kind: rx.Kind}):
+			return errors.Forbidden("not allowed to export %s in %s", rx.Type.Plural, query.Namespace)
+		}
+
+		// Export all of the matching objects, rather than the current page
+		query.Offset, query.Limit = 0, 0
+		found, err := rx.Store.Search(rx.Kind, query)
+		if err != nil {
+			return errors.Internal("unable to search, %v", err)
+		}
+
+		// Collect the objects first, as resolving the access levels may query the storage
+		objects := slices.Collect(found)
+		w.w.Header().Set("Content-Type", "text/csv")
+		w.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", rx.Kind.String()+".csv"))
+		if err := writeCSV(w.w, rx, slices.Values(objects)); err != nil {
+			return errors.Internal("unable to export %s, %v", rx.Type.Plural, err)
+		}
+		return nil
+	})
+}
+
+// csvOf returns the URL to export the objects matching the query as CSV.
+func csvOf(kind folio.Kind, query folio.Query) string {
+	var sb strings.Builder
+	sb.WriteString("/csv/")
+	sb.WriteString(string(kind))
+	sb.WriteString("?ns=")
+	sb.WriteString(query.Namespace)
+	if filter := convert.Base64(query.String()); filter != "" {
+		sb.WriteString("&filter=")
+		sb.WriteString(filter)
+	}
+	return sb.String()
+}
@@ -0,0 +1,202 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/errors"
+	"github.com/kelindar/folio/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportImport(t *testing.T) {
+	registry := folio.NewRegistry()
+	folio.Register[*Depot](registry)
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	for _, city := range []string{"Paris", "Berlin"} {
+		_, err := folio.Create[*Depot](db, func(d *Depot) error {
+			d.City = city
+			return nil
+		}, "default", "alice")
+		assert.NoError(t, err)
+	}
+
+	handler := New(registry, db)
+
+	// The list offers to export and import
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/content/depot?ns=default", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `href="/export/depot?ns=default"`)
+	assert.Contains(t, w.Body.String(), `hx-get="/import/depot?ns=default"`)
+
+	// Export as an attachment
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/export/depot?ns=default", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `attachment; filename="depot.ndjson"`, w.Header().Get("Content-Disposition"))
+	exported := w.Body.String()
+	assert.Equal(t, 2, strings.Count(exported, "\n"))
+	assert.Contains(t, exported, `"createdBy":"alice"`)
+
+	// The import form
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/import/depot?ns=default", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `name="conflict"`)
+
+	upload := func(data, conflict string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("file", "depot.ndjson")
+		part.Write([]byte(data))
+		form.WriteField("conflict", conflict)
+		form.Close()
+
+		token := strings.Repeat("t", 32)
+		r := httptest.NewRequest("POST", "/import/depot?ns=default", &body)
+		r.Header.Set("Content-Type", form.FormDataContentType())
+		r.Header.Set(csrfHeader, token)
+		r.AddCookie(&http.Cookie{Name: csrfCookie, Value: token})
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// Existing objects are skipped
+	w = upload(exported, "skip")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Skipped")
+	assert.NotContains(t, w.Body.String(), "The import has stopped")
+
+	// Existing objects stop the import by default
+	w = upload(exported, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "The import has stopped")
+
+	// Other kinds are rejected
+	w = upload(`{"kind":"namespace","namespace":"default","id":"cs2ojcbbvpprk6ou9gs0"}`, "skip")
+	assert.Contains(t, w.Body.String(), "unexpected kind")
+}
+
+type Crate struct {
+	folio.Meta `kind:"crate" json:",inline"`
+	Label      string `json:"label" form:"rw"`
+	Weight     int    `json:"weight" form:"rw"`
+}
+
+func (c *Crate) Validate() []errors.Validation {
+	if c.Weight < 0 {
+		return []errors.Validation{{Path: "weight", Message: "weight must not be negative"}}
+	}
+	return nil
+}
+
+func (c *Crate) BeforeInsert(folio.HookContext) error {
+	if c.Label == "forbidden" {
+		return fmt.Errorf("label is not allowed")
+	}
+	return nil
+}
+
+func TestImport_Checked(t *testing.T) {
+	registry := folio.NewRegistry()
+	folio.Register[*Incident](registry)
+	folio.Register[*Crate](registry)
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	handler := New(registry, db,
+		WithAuthenticator(AuthenticatorFunc(func(r *http.Request) (*folio.Principal, error) {
+			return &folio.Principal{Name: "alice", Role: folio.RoleEditor}, nil
+		})),
+		WithAuthorizer(folio.NewPolicy(db)),
+	)
+
+	upload := func(kind, data string) string {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("file", kind+".ndjson")
+		part.Write([]byte(data))
+		form.WriteField("conflict", "overwrite")
+		form.Close()
+
+		token := strings.Repeat("t", 32)
+		r := httptest.NewRequest("POST", "/import/"+kind+"?ns=default", &body)
+		r.Header.Set("Content-Type", form.FormDataContentType())
+		r.Header.Set(csrfHeader, token)
+		r.AddCookie(&http.Cookie{Name: csrfCookie, Value: token})
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	// Fields which are not writable by the principal are rejected
+	out := upload("incident", `{"kind":"incident","namespace":"default","id":"cs2ojcbbvpprk6ou9gs0","title":"Outage","notes":"secret"}`)
+	assert.Contains(t, out, "unable to write path notes")
+	out = upload("incident", `{"kind":"incident","namespace":"default","id":"cs2ojcbbvpprk6ou9gs0","steps":[{"approved":true}]}`)
+	assert.Contains(t, out, "unable to write path steps.0.approved")
+
+	// Objects are validated with their Validate method
+	out = upload("crate", `{"kind":"crate","namespace":"default","id":"cs2ojcbbvpprk6ou9gs1","weight":-1}`)
+	assert.Contains(t, out, "weight must not be negative")
+
+	// Hooks of the objects are run
+	out = upload("crate", `{"kind":"crate","namespace":"default","id":"cs2ojcbbvpprk6ou9gs1","label":"forbidden"}`)
+	assert.Contains(t, out, "label is not allowed")
+
+	out = upload("crate", `{"kind":"crate","namespace":"default","id":"cs2ojcbbvpprk6ou9gs1","label":"fine"}`)
+	assert.NotContains(t, out, "The import has stopped")
+	_, err := db.Fetch(folio.URN{Namespace: "default", Kind: "crate", ID: "cs2ojcbbvpprk6ou9gs1"})
+	assert.NoError(t, err)
+}
+
+func TestExport_Hidden(t *testing.T) {
+	registry := folio.NewRegistry()
+	folio.Register[*Incident](registry)
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	_, err := folio.Create(db, func(v *Incident) error {
+		v.Title = "Outage"
+		v.Notes = "secret"
+		v.Steps = []Step{{Name: "restart", Note: "secret"}}
+		return nil
+	}, "default", "alice")
+	assert.NoError(t, err)
+
+	handler := New(registry, db,
+		WithAuthenticator(AuthenticatorFunc(func(r *http.Request) (*folio.Principal, error) {
+			return &folio.Principal{Name: "alice", Role: folio.Role(r.Header.Get("X-Role"))}, nil
+		})),
+		WithAuthorizer(folio.NewPolicy(db)),
+	)
+
+	export := func(role folio.Role) string {
+		r := httptest.NewRequest("GET", "/export/incident?ns=default", nil)
+		r.Header.Set("X-Role", string(role))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	// Hidden fields are left out of every line, including the ones of nested structs
+	out := export(folio.RoleEditor)
+	assert.Contains(t, out, `"title":"Outage"`)
+	assert.Contains(t, out, `"restart"`)
+	assert.NotContains(t, out, "secret")
+
+	assert.Contains(t, export(folio.RoleAdmin), `"notes":"secret"`)
+}
@@ -0,0 +1,1002 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/errors"
+	"github.com/kelindar/folio/internal/graphql"
+)
+
+// graphQL executes the GraphQL queries and mutations over the objects of the registry.
+func graphQL(registry folio.Registry, db folio.Storage, vd errors.Validator) http.Handler {
+	return handleAPI(func(r *http.Request, w http.ResponseWriter) error {
+		req, err := readGraphQL(r)
+		if err != nil {
+			return err
+		}
+
+		doc, err := graphql.Parse(req.Query)
+		if err != nil {
+			return writeGraphQLError(w, http.StatusBadRequest, err)
+		}
+
+		op, err := doc.Operation(req.OperationName)
+		switch {
+		case err != nil:
+			return writeGraphQLError(w, http.StatusBadRequest, err)
+		case op.Type == "mutation" && r.Method != http.MethodPost:
+			return writeGraphQLError(w, http.StatusMethodNotAllowed, fmt.Errorf("mutations are only allowed with POST"))
+		}
+
+		vars, err := op.Values(req.Variables)
+		if err != nil {
+			return writeGraphQLError(w, http.StatusBadRequest, err)
+		}
+
+		acc := accessOf(r)
+		exec := &gqlExec{
+			r:        r,
+			registry: registry,
+			db:       db,
+			vd:       vd,
+			schema:   graphQLOf(registry),
+			doc:      doc,
+			vars:     vars,
+			store:    folio.Secure(db, acc.authz, acc.who),
+			fetched:  make(map[folio.URN]map[string]any),
+			contexts: make(map[string]*Context),
+		}
+
+		data := exec.operation(op)
+		return writeJSON(w, http.StatusOK, gqlResponse{Data: data, Errors: exec.errors})
+	})
+}
+
+// graphQLSchema serves the GraphQL schema, in the schema definition language.
+func graphQLSchema(registry folio.Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(graphQLOf(registry).String()))
+	})
+}
+
+// gqlRequest represents a GraphQL request, either sent as JSON or as query parameters.
+type gqlRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// gqlResponse represents a GraphQL response.
+type gqlResponse struct {
+	Data   any        `json:"data,omitempty"`
+	Errors []gqlError `json:"errors,omitempty"`
+}
+
+// gqlError represents an error of a GraphQL response.
+type gqlError struct {
+	Message    string         `json:"message"`
+	Path       []any          `json:"path,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+// readGraphQL reads the GraphQL request from the body, or from the query parameters.
+func readGraphQL(r *http.Request) (req gqlRequest, err error) {
+	switch r.Method {
+	case http.MethodGet:
+		params := r.URL.Query()
+		req.Query = params.Get("query")
+		req.OperationName = params.Get("operationName")
+		if v := params.Get("variables"); v != "" {
+			decoder := json.NewDecoder(strings.NewReader(v))
+			decoder.UseNumber()
+			if err := decoder.Decode(&req.Variables); err != nil {
+				return req, errors.BadRequest("unable to decode variables, %v", err)
+			}
+		}
+	default:
+		decoder := json.NewDecoder(r.Body)
+		decoder.UseNumber()
+		if err := decoder.Decode(&req); err != nil {
+			return req, errors.BadRequest("unable to decode request, %v", err)
+		}
+	}
+
+	if strings.TrimSpace(req.Query) == "" {
+		return req, errors.BadRequest("query is required")
+	}
+	return req, nil
+}
+
+// writeGraphQLError writes a response with a single error, when the request can not be executed.
+func writeGraphQLError(w http.ResponseWriter, status int, err error) error {
+	return writeJSON(w, status, gqlResponse{
+		Errors: []gqlError{{Message: err.Error()}},
+	})
+}
+
+// ---------------------------------- Schema ----------------------------------
+
+// gqlSchema represents the GraphQL schema generated from the types of the registry.
+type gqlSchema struct {
+	kinds     map[folio.Kind]*gqlObject   // Object types of the registered kinds
+	nested    map[reflect.Type]*gqlObject // Object types of the nested structs
+	objects   []*gqlObject                // Object types, in order of definition
+	queries   map[string]gqlRoot          // Fields of the query type
+	mutations map[string]gqlRoot          // Fields of the mutation type
+}
+
+// gqlObject represents an object type, either of a registered kind or of a nested struct.
+type gqlObject struct {
+	name   string
+	kind   folio.Kind // Registered kind, empty for nested structs
+	single string     // Name of the query returning a single object
+	plural string     // Name of the query returning a page of objects
+	fields []*gqlField
+	index  map[string]*gqlField
+}
+
+// gqlField represents a field of an object type.
+type gqlField struct {
+	name   string     // Name of the field, same as in JSON
+	desc   string     // Description of the field
+	output string     // Output type (e.g. "[Person!]")
+	input  string     // Input type, empty if the field can not be written
+	ref    folio.Kind // Kind of the referenced objects, for URNs
+	object *gqlObject // Object type of a nested struct
+}
+
+// gqlRoot represents a field of the query or mutation type.
+type gqlRoot struct {
+	op     string     // Operation, one of get, list, create, update or delete
+	object *gqlObject // Object type of the kind
+}
+
+var (
+	typeURN      = reflect.TypeFor[folio.URN]()
+	typeTime     = reflect.TypeFor[time.Time]()
+	typeDuration = reflect.TypeFor[time.Duration]()
+	typeEmbed    = reflect.TypeFor[folio.Embed]()
+)
+
+// graphQLOf generates the GraphQL schema for the types of the registry. URNs which specify
+// the kind they refer to become references to the objects of that kind.
+func graphQLOf(registry folio.Registry) *gqlSchema {
+	s := &gqlSchema{
+		kinds:     make(map[folio.Kind]*gqlObject),
+		nested:    make(map[reflect.Type]*gqlObject),
+		queries:   make(map[string]gqlRoot),
+		mutations: make(map[string]gqlRoot),
+	}
+
+	types := slices.SortedFunc(registry.Types(), func(a, b folio.Type) int {
+		return strings.Compare(string(a.Kind), string(b.Kind))
+	})
+
+	// Declare all of the kinds first, so that they can reference each other
+	for _, typ := range types {
+		name := typ.Type.Name()
+		single := lowerFirst(name)
+		plural := lowerFirst(strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, typ.Plural))
+		if plural == "" || plural == single {
+			plural = pluralOf(single)
+		}
+
+		s.kinds[typ.Kind] = &gqlObject{
+			name:   name,
+			kind:   typ.Kind,
+			single: single,
+			plural: plural,
+			index:  make(map[string]*gqlField),
+		}
+	}
+
+	for _, typ := range types {
+		obj := s.kinds[typ.Kind]
+		obj.add(&gqlField{name: "urn", desc: "Unique resource name of the object", output: "ID!"})
+		s.walk(obj, typ.Type)
+		s.objects = append(s.objects, obj)
+
+		s.queries[obj.single] = gqlRoot{op: "get", object: obj}
+		s.queries[obj.plural] = gqlRoot{op: "list", object: obj}
+		s.mutations["delete"+obj.name] = gqlRoot{op: "delete", object: obj}
+		if obj.writable() {
+			s.mutations["create"+obj.name] = gqlRoot{op: "create", object: obj}
+			s.mutations["update"+obj.name] = gqlRoot{op: "update", object: obj}
+		}
+	}
+
+	return s
+}
+
+// walk adds the fields of the struct type to the object type.
+func (s *gqlSchema) walk(obj *gqlObject, typ reflect.Type) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := jsonName(field)
+		switch {
+		case !field.IsExported():
+			continue
+		case field.Anonymous && field.Type.Kind() == reflect.Struct:
+			s.walk(obj, field.Type)
+			continue
+		case name == "-" || obj.index[name] != nil:
+			continue
+		}
+
+		out := &gqlField{name: name, desc: field.Tag.Get("desc")}
+		out.output, out.input = s.typeOf(out, field.Type, folio.Kind(field.Tag.Get("kind")), obj.name+upperFirst(name))
+		if field.Tag.Get("form") == "-" {
+			out.input = ""
+		}
+
+		obj.add(out)
+	}
+}
+
+// typeOf returns the output and input types of the Go type. References and nested structs are
+// recorded on the field, so that they can be resolved.
+func (s *gqlSchema) typeOf(field *gqlField, typ reflect.Type, kind folio.Kind, name string) (string, string) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch typ {
+	case typeURN:
+		if ref, ok := s.kinds[kind]; ok {
+			field.ref = kind
+			return ref.name, "ID"
+		}
+		return "ID", "ID"
+	case typeTime:
+		return "DateTime", "DateTime"
+	case typeDuration:
+		return "Long", "Long"
+	case typeEmbed:
+		return "JSON", "JSON"
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		return "String", "String"
+	case reflect.Bool:
+		return "Boolean", "Boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return "Int", "Int"
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return "Long", "Long" // Timestamps do not fit into a 32-bit integer
+	case reflect.Float32, reflect.Float64:
+		return "Float", "Float"
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return "String", "String" // Bytes are encoded as base64
+		}
+
+		output, input := s.typeOf(field, typ.Elem(), kind, name)
+		if input != "" {
+			input = "[" + input + "!]"
+		}
+		return "[" + output + "!]", input
+	case reflect.Struct:
+		obj := s.object(typ, name)
+		field.object = obj
+		if !obj.writable() {
+			return obj.name, ""
+		}
+		return obj.name, obj.name + "Input"
+	default:
+		return "JSON", "JSON"
+	}
+}
+
+// object returns the object type of a nested struct, named after the struct if it has a name
+// or after the field otherwise.
+func (s *gqlSchema) object(typ reflect.Type, name string) *gqlObject {
+	if obj, ok := s.nested[typ]; ok {
+		return obj
+	}
+
+	if typ.Name() != "" && !s.isDeclared(typ.Name()) {
+		name = typ.Name()
+	}
+
+	obj := &gqlObject{name: name, index: make(map[string]*gqlField)}
+	s.nested[typ] = obj
+	s.walk(obj, typ)
+	s.objects = append(s.objects, obj)
+	return obj
+}
+
+// isDeclared returns true if an object type with the name is already declared.
+func (s *gqlSchema) isDeclared(name string) bool {
+	for _, obj := range s.kinds {
+		if obj.name == name {
+			return true
+		}
+	}
+	for _, obj := range s.nested {
+		if obj.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// add adds a field to the object type.
+func (o *gqlObject) add(field *gqlField) {
+	o.fields = append(o.fields, field)
+	o.index[field.name] = field
+}
+
+// writable returns true if the object type has at least one field which can be written.
+func (o *gqlObject) writable() bool {
+	for _, f := range o.fields {
+		if f.input != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the schema in the schema definition language.
+func (s *gqlSchema) String() string {
+	var sb strings.Builder
+	sb.WriteString("scalar DateTime\n")
+	sb.WriteString("scalar JSON\n")
+	sb.WriteString("scalar Long\n\n")
+	sb.WriteString("input Filter {\n  field: String!\n  values: [String!]!\n}\n\n")
+
+	kinds := make([]*gqlObject, 0, len(s.kinds))
+	for _, obj := range s.objects {
+		if obj.kind != "" {
+			kinds = append(kinds, obj)
+		}
+	}
+
+	// Queries return a single object, or a page of objects
+	sb.WriteString("type Query {\n")
+	for _, obj := range kinds {
+		fmt.Fprintf(&sb, "  %s(urn: ID!): %s\n", obj.single, obj.name)
+		fmt.Fprintf(&sb, "  %s(namespace: String, state: [String!], filter: [Filter!], match: String, "+
+			"sort: [String!], offset: Int, limit: Int): %sPage!\n", obj.plural, obj.name)
+	}
+	sb.WriteString("}\n\n")
+
+	// Mutations create, update and delete objects
+	sb.WriteString("type Mutation {\n")
+	for _, obj := range kinds {
+		if obj.writable() {
+			fmt.Fprintf(&sb, "  create%s(namespace: String!, input: %sInput!): %s\n", obj.name, obj.name, obj.name)
+			fmt.Fprintf(&sb, "  update%s(urn: ID!, input: %sInput!): %s\n", obj.name, obj.name, obj.name)
+		}
+		fmt.Fprintf(&sb, "  delete%s(urn: ID!): %s\n", obj.name, obj.name)
+	}
+	sb.WriteString("}\n")
+
+	for _, obj := range s.objects {
+		sb.WriteString("\ntype " + obj.name + " {\n")
+		for _, f := range obj.fields {
+			if f.desc != "" {
+				sb.WriteString("  " + strconv.Quote(f.desc) + "\n")
+			}
+			sb.WriteString("  " + f.name + ": " + f.output + "\n")
+		}
+		sb.WriteString("}\n")
+
+		if obj.kind != "" {
+			fmt.Fprintf(&sb, "\ntype %sPage {\n  items: [%s!]!\n  total: Int!\n  offset: Int!\n  limit: Int!\n}\n", obj.name, obj.name)
+		}
+
+		if obj.writable() {
+			sb.WriteString("\ninput " + obj.name + "Input {\n")
+			for _, f := range obj.fields {
+				if f.input != "" {
+					sb.WriteString("  " + f.name + ": " + f.input + "\n")
+				}
+			}
+			sb.WriteString("}\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// ---------------------------------- Execution ----------------------------------
+
+// gqlExec represents the execution of a single GraphQL operation.
+type gqlExec struct {
+	r        *http.Request
+	registry folio.Registry
+	db       folio.Storage
+	vd       errors.Validator
+	schema   *gqlSchema
+	doc      *graphql.Document
+	vars     map[string]any
+	store    folio.Storage                // Storage authorized against the principal
+	fetched  map[folio.URN]map[string]any // Objects fetched during the execution
+	contexts map[string]*Context          // Contexts resolving the access levels, by namespace and kind
+	errors   []gqlError
+}
+
+// operation executes the operation. Fields are resolved in order, so mutations are executed
+// one after another.
+func (x *gqlExec) operation(op *graphql.Operation) any {
+	roots, typename := x.schema.queries, "Query"
+	if op.Type == "mutation" {
+		roots, typename = x.schema.mutations, "Mutation"
+	}
+
+	fields, err := x.doc.Collect(op.Selections, typename, x.vars)
+	if err != nil {
+		return x.fail(nil, errors.BadRequest("%v", err))
+	}
+
+	out := make(gqlMap, 0, len(fields))
+	for _, field := range fields {
+		path := []any{field.Key()}
+		switch root, ok := roots[field.Name]; {
+		case field.Name == "__typename":
+			out = append(out, gqlEntry{field.Key(), typename})
+		case !ok:
+			out = append(out, gqlEntry{field.Key(), x.fail(path, errors.BadRequest("unknown field '%s' on type %s", field.Name, typename))})
+		default:
+			out = append(out, gqlEntry{field.Key(), x.root(root, field, path)})
+		}
+	}
+	return out
+}
+
+// root resolves a field of the query or mutation type.
+func (x *gqlExec) root(root gqlRoot, field *graphql.Field, path []any) any {
+	args := field.Args(x.vars)
+	switch root.op {
+	case "get":
+		urn, err := x.urnOf(root.object, args["urn"])
+		if err != nil {
+			return x.fail(path, err)
+		}
+
+		data, err := x.fetch(urn)
+		if err != nil {
+			return x.fail(path, err)
+		}
+		return x.object(root.object, data, field, path)
+
+	case "list":
+		return x.list(root.object, args, field, path)
+
+	case "create":
+		data, err := x.create(root.object, args)
+		if err != nil {
+			return x.fail(path, err)
+		}
+		return x.object(root.object, data, field, path)
+
+	case "update":
+		data, err := x.update(root.object, args)
+		if err != nil {
+			return x.fail(path, err)
+		}
+		return x.object(root.object, data, field, path)
+
+	case "delete":
+		urn, err := x.urnOf(root.object, args["urn"])
+		if err != nil {
+			return x.fail(path, err)
+		}
+
+		rx, err := contextOf(ModeEdit, x.r, x.registry, x.db, urn.Kind, urn, urn.Namespace)
+		if err != nil {
+			return x.fail(path, err)
+		}
+
+		deleted, err := rx.Store.Delete(urn, rx.username())
+		if err != nil {
+			return x.fail(path, err)
+		}
+
+		delete(x.fetched, urn)
+		data, err := x.encode(deleted)
+		if err != nil {
+			return x.fail(path, err)
+		}
+		return x.object(root.object, data, field, path)
+
+	default:
+		return nil
+	}
+}
+
+// list resolves a page of objects matching the query arguments.
+func (x *gqlExec) list(obj *gqlObject, args map[string]any, field *graphql.Field, path []any) any {
+	query, err := gqlQuery(args)
+	if err != nil {
+		return x.fail(path, errors.BadRequest("invalid query, %v", err))
+	}
+
+	fields, err := x.doc.Collect(field.Selections, obj.name+"Page", x.vars)
+	switch {
+	case err != nil:
+		return x.fail(path, errors.BadRequest("%v", err))
+	case len(fields) == 0:
+		return x.fail(path, errors.BadRequest("field '%s' must have a selection of subfields", field.Name))
+	}
+
+	out := make(gqlMap, 0, len(fields))
+	for _, f := range fields {
+		path := append(slices.Clip(path), f.Key())
+		switch f.Name {
+		case "__typename":
+			out = append(out, gqlEntry{f.Key(), obj.name + "Page"})
+		case "offset":
+			out = append(out, gqlEntry{f.Key(), query.Offset})
+		case "limit":
+			out = append(out, gqlEntry{f.Key(), query.Limit})
+		case "total":
+			count := query
+			count.Offset, count.Limit, count.SortBy = 0, 0, nil
+			total, err := x.store.Count(obj.kind, count)
+			if err != nil {
+				return x.fail(path, err)
+			}
+			out = append(out, gqlEntry{f.Key(), total})
+		case "items":
+			found, err := x.store.Search(obj.kind, query)
+			if err != nil {
+				return x.fail(path, err)
+			}
+
+			// Collect the page first, as resolving references queries the storage again
+			page := make([]folio.Object, 0, query.Limit)
+			for v := range found {
+				page = append(page, v)
+			}
+
+			items := make([]any, 0, len(page))
+			for i, v := range page {
+				data, err := x.encode(v)
+				if err != nil {
+					return x.fail(path, err)
+				}
+
+				x.fetched[v.URN()] = data
+				items = append(items, x.object(obj, data, f, append(slices.Clip(path), i)))
+			}
+			out = append(out, gqlEntry{f.Key(), items})
+		default:
+			return x.fail(path, errors.BadRequest("unknown field '%s' on type %sPage", f.Name, obj.name))
+		}
+	}
+	return out
+}
+
+// create creates a new object from the input, with the same validation as the other APIs.
+func (x *gqlExec) create(obj *gqlObject, args map[string]any) (map[string]any, error) {
+	ns, _ := args["namespace"].(string)
+	input, ok := args["input"].(map[string]any)
+	if !ok {
+		return nil, errors.BadRequest("input is required")
+	}
+
+	urn, err := folio.NewURN(ns, obj.kind)
+	if err != nil {
+		return nil, errors.BadRequest("invalid namespace, %v", err)
+	}
+
+	rx, err := contextOf(ModeCreate, x.r, x.registry, x.db, obj.kind, urn, ns)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := decodeObject(rx, input, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateObject(x.vd, created); err != nil {
+		return nil, err
+	}
+
+	bearer, err := mintNew(rx, created)
+	if err != nil {
+		return nil, err
+	}
+
+	if created, err = rx.Store.Insert(created, rx.username()); err != nil {
+		return nil, err
+	}
+
+	data, err := x.encode(created)
+	if err == nil && bearer != "" {
+		data["secret"] = bearer // Returned once, as only its hash is kept
+	}
+	return data, err
+}
+
+// update merges the input into an existing object, so only the provided fields are changed.
+func (x *gqlExec) update(obj *gqlObject, args map[string]any) (map[string]any, error) {
+	urn, err := x.urnOf(obj, args["urn"])
+	if err != nil {
+		return nil, err
+	}
+
+	input, ok := args["input"].(map[string]any)
+	if !ok {
+		return nil, errors.BadRequest("input is required")
+	}
+
+	rx, err := contextOf(ModeEdit, x.r, x.registry, x.db, obj.kind, urn, urn.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := rx.Store.Fetch(urn)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := encodeObject(current)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := decodeObject(rx, mergePatch(state, input).(map[string]any), current)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateObject(x.vd, updated); err != nil {
+		return nil, err
+	}
+
+	if updated, err = rx.Store.Update(updated, rx.username()); err != nil {
+		return nil, err
+	}
+
+	delete(x.fetched, urn)
+	return x.encode(updated)
+}
+
+// object resolves the selected fields of an object.
+func (x *gqlExec) object(obj *gqlObject, data map[string]any, field *graphql.Field, path []any) any {
+	if data == nil {
+		return nil
+	}
+
+	fields, err := x.doc.Collect(field.Selections, obj.name, x.vars)
+	switch {
+	case err != nil:
+		return x.fail(path, errors.BadRequest("%v", err))
+	case len(fields) == 0:
+		return x.fail(path, errors.BadRequest("field '%s' must have a selection of subfields", field.Name))
+	}
+
+	out := make(gqlMap, 0, len(fields))
+	for _, f := range fields {
+		out = append(out, gqlEntry{f.Key(), x.field(obj, data, f, append(slices.Clip(path), f.Key()))})
+	}
+	return out
+}
+
+// field resolves a single field of an object.
+func (x *gqlExec) field(obj *gqlObject, data map[string]any, f *graphql.Field, path []any) any {
+	if f.Name == "__typename" {
+		return obj.name
+	}
+
+	field, ok := obj.index[f.Name]
+	switch {
+	case !ok:
+		return x.fail(path, errors.BadRequest("unknown field '%s' on type %s", f.Name, obj.name))
+	case field.ref == "" && field.object == nil && len(f.Selections) > 0:
+		return x.fail(path, errors.BadRequest("field '%s' of type %s must not have a selection", f.Name, field.output))
+	}
+
+	switch {
+	case field.ref != "":
+		return x.each(data[f.Name], path, func(v any, path []any) any {
+			return x.reference(x.schema.kinds[field.ref], v, f, path)
+		})
+	case field.object != nil:
+		return x.each(data[f.Name], path, func(v any, path []any) any {
+			nested, _ := v.(map[string]any)
+			return x.object(field.object, nested, f, path)
+		})
+	default:
+		return data[f.Name]
+	}
+}
+
+// reference resolves an object referenced by its URN. The object is only fetched if fields
+// other than the ones in its URN are selected.
+func (x *gqlExec) reference(obj *gqlObject, value any, f *graphql.Field, path []any) any {
+	encoded, _ := value.(string)
+	if encoded == "" {
+		return nil
+	}
+
+	urn, err := folio.ParseURN(encoded)
+	if err != nil {
+		return x.fail(path, errors.Internal("invalid reference, %v", err))
+	}
+
+	fields, err := x.doc.Collect(f.Selections, obj.name, x.vars)
+	if err == nil && isIdentity(fields) {
+		return x.object(obj, map[string]any{
+			"urn":       urn.String(),
+			"id":        urn.ID,
+			"kind":      urn.Kind,
+			"namespace": urn.Namespace,
+		}, f, path)
+	}
+
+	data, err := x.fetch(urn)
+	if err != nil {
+		return x.fail(path, err)
+	}
+	return x.object(obj, data, f, path)
+}
+
+// each applies the function to the value, or to each of its elements if it is a list.
+func (x *gqlExec) each(value any, path []any, fn func(any, []any) any) any {
+	list, ok := value.([]any)
+	if !ok {
+		return fn(value, path)
+	}
+
+	out := make([]any, 0, len(list))
+	for i, v := range list {
+		out = append(out, x.each(v, append(slices.Clip(path), i), fn))
+	}
+	return out
+}
+
+// fetch fetches the object, reusing the objects already fetched during the execution.
+func (x *gqlExec) fetch(urn folio.URN) (map[string]any, error) {
+	if data, ok := x.fetched[urn]; ok {
+		return data, nil
+	}
+
+	obj, err := x.store.Fetch(urn)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := x.encode(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	x.fetched[urn] = data
+	return data, nil
+}
+
+// urnOf parses the URN argument and checks that it refers to an object of the kind.
+func (x *gqlExec) urnOf(obj *gqlObject, value any) (folio.URN, error) {
+	encoded, _ := value.(string)
+	urn, err := folio.ParseURN(encoded)
+	switch {
+	case err != nil:
+		return urn, errors.BadRequest("invalid urn '%s', %v", encoded, err)
+	case urn.Kind != obj.kind:
+		return urn, errors.BadRequest("invalid urn '%s', expected kind %s", encoded, obj.kind)
+	default:
+		return urn, nil
+	}
+}
+
+// fail records the error at the path and returns a null value.
+func (x *gqlExec) fail(path []any, err error) any {
+	out := gqlError{
+		Message:    err.Error(),
+		Path:       path,
+		Extensions: map[string]any{"status": statusOf(err)},
+	}
+
+	if e, ok := err.(*apiError); ok {
+		out.Extensions["status"] = e.Status
+		out.Extensions["validations"] = e.Validations
+	}
+
+	x.errors = append(x.errors, out)
+	return nil
+}
+
+// encode encodes the object as a generic JSON object, along with its URN. The fields which
+// are hidden from the principal are left out, so they resolve to null.
+func (x *gqlExec) encode(obj folio.Object) (map[string]any, error) {
+	urn := obj.URN()
+	key := urn.Namespace + "/" + string(urn.Kind)
+	rx, ok := x.contexts[key]
+	if !ok {
+		var err error
+		if rx, err = contextOf(ModeView, x.r, x.registry, x.db, urn.Kind, urn, urn.Namespace); err != nil {
+			return nil, err
+		}
+		x.contexts[key] = rx
+	}
+
+	data, err := visibleOf(rx, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	data["urn"] = obj.URN().String()
+	return data, nil
+}
+
+// isIdentity returns true if only the fields identifying the object are selected.
+func isIdentity(fields []*graphql.Field) bool {
+	for _, f := range fields {
+		switch f.Name {
+		case "urn", "id", "kind", "namespace", "__typename":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ---------------------------------- Arguments ----------------------------------
+
+// gqlQuery builds the query from the arguments of a list field.
+func gqlQuery(args map[string]any) (folio.Query, error) {
+	query := folio.Query{Limit: apiLimit}
+	query.Namespace, _ = args["namespace"].(string)
+	query.Match, _ = args["match"].(string)
+	query.States = stringsOf(args["state"])
+	query.SortBy = stringsOf(args["sort"])
+
+	// Filters are specified as a list of {field, values}
+	for _, v := range listOf(args["filter"]) {
+		filter, _ := v.(map[string]any)
+		field, _ := filter["field"].(string)
+		if field == "" {
+			return query, fmt.Errorf("invalid filter, field is required")
+		}
+
+		if query.Filters == nil {
+			query.Filters = make(map[string][]string)
+		}
+		query.Filters[field] = append(query.Filters[field], stringsOf(filter["values"])...)
+	}
+
+	var err error
+	if v, ok := args["offset"]; ok && v != nil {
+		if query.Offset, err = intOf(v); err != nil || query.Offset < 0 {
+			return query, fmt.Errorf("invalid offset '%v'", v)
+		}
+	}
+
+	if v, ok := args["limit"]; ok && v != nil {
+		if query.Limit, err = intOf(v); err != nil || query.Limit <= 0 || query.Limit > apiMaxLimit {
+			return query, fmt.Errorf("invalid limit '%v', expected 1 to %d", v, apiMaxLimit)
+		}
+	}
+
+	return query, nil
+}
+
+// listOf returns the value as a list, following the input coercion of GraphQL where a single
+// value is accepted in place of a list.
+func listOf(value any) []any {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []any:
+		return v
+	default:
+		return []any{v}
+	}
+}
+
+// stringsOf returns the value as a list of strings.
+func stringsOf(value any) []string {
+	var out []string
+	for _, v := range listOf(value) {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// intOf returns the value as an integer.
+func intOf(value any) (int, error) {
+	switch v := value.(type) {
+	case int64:
+		return int(v), nil
+	case json.Number:
+		n, err := v.Int64()
+		return int(n), err
+	case float64:
+		if v != float64(int(v)) {
+			return 0, fmt.Errorf("%v is not an integer", v)
+		}
+		return int(v), nil
+	default:
+		return 0, fmt.Errorf("%v is not an integer", v)
+	}
+}
+
+// lowerFirst returns the string with its first letter in lower case.
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// pluralOf returns the English plural of the name, for kinds without a plural name.
+func pluralOf(name string) string {
+	switch {
+	case strings.HasSuffix(name, "s"), strings.HasSuffix(name, "x"),
+		strings.HasSuffix(name, "ch"), strings.HasSuffix(name, "sh"):
+		return name + "es"
+	case strings.HasSuffix(name, "y") && len(name) > 1 && !strings.ContainsAny(name[len(name)-2:len(name)-1], "aeiou"):
+		return name[:len(name)-1] + "ies"
+	default:
+		return name + "s"
+	}
+}
+
+// upperFirst returns the string with its first letter in upper case.
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// ---------------------------------- Response ----------------------------------
+
+// gqlMap represents a JSON object which keeps the order of the selected fields.
+type gqlMap []gqlEntry
+
+// gqlEntry represents a single field of the response.
+type gqlEntry struct {
+	key   string
+	value any
+}
+
+// MarshalJSON encodes the fields in order.
+func (m gqlMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, entry := range m {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(entry.key)
+		value, err := json.Marshal(entry.value)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
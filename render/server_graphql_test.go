@@ -0,0 +1,259 @@
+package render
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/internal/graphql"
+	"github.com/kelindar/folio/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+type Truck struct {
+	folio.Meta `kind:"truck" json:",inline"`
+	Model      string      `json:"model" form:"rw" is:"required"`
+	Drivers    []folio.URN `json:"drivers" form:"rw" kind:"driver"`
+	Engine     struct {
+		Power int `json:"power" form:"rw"`
+	} `json:"engine" form:"rw"`
+}
+
+type Driver struct {
+	folio.Meta `kind:"driver" json:",inline"`
+	Name       string    `json:"name" form:"rw" is:"required" desc:"Full name"`
+	Depot      folio.URN `json:"depot" form:"rw" kind:"depot"`
+}
+
+func (d *Driver) Title() string {
+	return d.Name
+}
+
+type Depot struct {
+	folio.Meta `kind:"depot" json:",inline"`
+	City       string `json:"city" form:"rw"`
+}
+
+func TestGraphQL(t *testing.T) {
+	registry := folio.NewRegistry()
+	folio.Register[*Truck](registry)
+	folio.Register[*Driver](registry)
+	folio.Register[*Depot](registry)
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	handler := New(registry, db)
+	call := func(query string, vars map[string]any) (*httptest.ResponseRecorder, gqlResult) {
+		body, _ := json.Marshal(map[string]any{"query": query, "variables": vars})
+		r := httptest.NewRequest("POST", "/api/v1/graphql", strings.NewReader(string(body)))
+		r.Header.Set("Content-Type", "application/json")
+		r.AddCookie(&http.Cookie{Name: csrfCookie, Value: "0123456789abcdefghijklmnopqrstuv"})
+		r.Header.Set(csrfHeader, "0123456789abcdefghijklmnopqrstuv")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		var out gqlResult
+		json.Unmarshal(w.Body.Bytes(), &out)
+		return w, out
+	}
+
+	// Create the objects, referencing each other
+	create := `mutation($ns: String!, $name: String!, $depot: ID) {
+		createDriver(namespace: $ns, input: {name: $name, depot: $depot}) { urn }
+	}`
+
+	_, out := call(`mutation { createDepot(namespace: "default", input: {city: "Paris"}) { urn city } }`, nil)
+	assert.Empty(t, out.Errors)
+	depot := out.Data["createDepot"].(map[string]any)["urn"]
+
+	_, out = call(create, map[string]any{"ns": "default", "name": "Alice", "depot": depot})
+	assert.Empty(t, out.Errors)
+	alice := out.Data["createDriver"].(map[string]any)["urn"]
+
+	_, out = call(create, map[string]any{"ns": "default", "name": "Bob"})
+	assert.Empty(t, out.Errors)
+	bob := out.Data["createDriver"].(map[string]any)["urn"]
+
+	_, out = call(`mutation($drivers: [ID!]) {
+		createTruck(namespace: "default", input: {model: "Actros", drivers: $drivers, engine: {power: 450}}) { urn }
+	}`, map[string]any{"drivers": []any{alice, bob}})
+	assert.Empty(t, out.Errors)
+	truck := out.Data["createTruck"].(map[string]any)["urn"].(string)
+
+	// Fetch the truck along with its drivers and their depot, in one round trip
+	w, out := call(`query($urn: ID!) {
+		truck(urn: $urn) {
+			model
+			engine { power }
+			drivers { ...driver }
+		}
+	}
+	fragment driver on Driver { name depot { city } }`, map[string]any{"urn": truck})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, out.Errors)
+	assert.JSONEq(t, `{"truck": {
+		"model": "Actros",
+		"engine": {"power": 450},
+		"drivers": [
+			{"name": "Alice", "depot": {"city": "Paris"}},
+			{"name": "Bob", "depot": null}
+		]
+	}}`, string(must(json.Marshal(out.Data))))
+
+	// The fields are returned in the order they were selected
+	assert.True(t, strings.Contains(w.Body.String(), `{"model":"Actros","engine":`), w.Body.String())
+
+	// List with a filter, sort and pagination
+	_, out = call(`{
+		drivers(namespace: "default", sort: ["-name"], limit: 1) {
+			total
+			items { name }
+		}
+		named: drivers(filter: [{field: "name", values: ["Bob"]}]) {
+			items { name }
+		}
+	}`, nil)
+	assert.Empty(t, out.Errors)
+	assert.JSONEq(t, `{
+		"drivers": {"total": 2, "items": [{"name": "Bob"}]},
+		"named": {"items": [{"name": "Bob"}]}
+	}`, string(must(json.Marshal(out.Data))))
+
+	// Validation errors are reported with their paths
+	_, out = call(`mutation { createDriver(namespace: "default", input: {}) { urn } }`, nil)
+	assert.Len(t, out.Errors, 1)
+	assert.Equal(t, []any{"createDriver"}, out.Errors[0].Path)
+	assert.Equal(t, float64(http.StatusBadRequest), out.Errors[0].Extensions["status"])
+	assert.Len(t, out.Errors[0].Extensions["validations"], 1)
+
+	// Update only changes the provided fields
+	_, out = call(`mutation($urn: ID!) { updateTruck(urn: $urn, input: {model: "Arocs"}) { model engine { power } } }`,
+		map[string]any{"urn": truck})
+	assert.Empty(t, out.Errors)
+	assert.JSONEq(t, `{"updateTruck": {"model": "Arocs", "engine": {"power": 450}}}`, string(must(json.Marshal(out.Data))))
+
+	// Delete
+	_, out = call(`mutation($urn: ID!) { deleteTruck(urn: $urn) { model } }`, map[string]any{"urn": truck})
+	assert.Empty(t, out.Errors)
+	_, out = call(`query($urn: ID!) { truck(urn: $urn) { model } }`, map[string]any{"urn": truck})
+	assert.Len(t, out.Errors, 1)
+	assert.Equal(t, float64(http.StatusNotFound), out.Errors[0].Extensions["status"])
+
+	// Unknown fields
+	_, out = call(`{ drivers { items { salary } } }`, nil)
+	assert.NotEmpty(t, out.Errors)
+	assert.Contains(t, out.Errors[0].Message, "unknown field 'salary' on type Driver")
+
+	// Syntax errors
+	w, out = call(`{ drivers { items { name }`, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Len(t, out.Errors, 1)
+
+	// Fragments which spread themselves through a reference are rejected before execution
+	w, out = call(`{ drivers { items { ...driver } } }
+	fragment driver on Driver { name depot { ...depot } }
+	fragment depot on Depot { city ...driver }`, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, out.Errors[0].Message, "spreads itself")
+
+	// As are the queries which select too deeply
+	w, out = call(`{ truck(urn: "x") { drivers { depot { `+strings.Repeat("a { ", graphql.MaxDepth)+"b "+strings.Repeat("}", graphql.MaxDepth+3)+` }`, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, out.Errors[0].Message, "maximum depth")
+
+	// Mutations are not allowed with GET
+	r := httptest.NewRequest("GET", "/api/v1/graphql?query="+url.QueryEscape(`mutation { deleteDepot(urn: "x") { urn } }`), nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestGraphQL_Schema(t *testing.T) {
+	registry := folio.NewRegistry()
+	folio.Register[*Truck](registry)
+	folio.Register[*Driver](registry)
+	folio.Register[*Depot](registry)
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	w := httptest.NewRecorder()
+	New(registry, db).ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/schema.graphql", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	schema := w.Body.String()
+	for _, expect := range []string{
+		"  truck(urn: ID!): Truck\n",
+		"  trucks(namespace: String, state: [String!], filter: [Filter!], match: String, sort: [String!], offset: Int, limit: Int): TruckPage!\n",
+		"  createTruck(namespace: String!, input: TruckInput!): Truck\n",
+		"  drivers: [Driver!]\n",
+		"  engine: TruckEngine\n",
+		"  engine: TruckEngineInput\n",
+		"  depot: Depot\n",
+		"  depot: ID\n",
+		"  \"Full name\"\n  name: String\n",
+		"  createdAt: Long\n",
+	} {
+		assert.Contains(t, schema, expect)
+	}
+}
+
+// gqlResult represents a decoded GraphQL response.
+type gqlResult struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Path       []any          `json:"path"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func TestGraphQL_Hidden(t *testing.T) {
+	registry := folio.NewRegistry()
+	folio.Register[*Incident](registry)
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	call := apiCaller(New(registry, db,
+		WithAuthenticator(AuthenticatorFunc(func(r *http.Request) (*folio.Principal, error) {
+			return &folio.Principal{Name: "alice", Role: folio.Role(r.Header.Get("X-Role"))}, nil
+		})),
+		WithAuthorizer(folio.NewPolicy(db)),
+	))
+
+	query := func(role folio.Role, query string) gqlResult {
+		body, _ := json.Marshal(map[string]any{"query": query})
+		w, _ := call(role, "POST", "/api/v1/graphql", string(body))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var out gqlResult
+		json.Unmarshal(w.Body.Bytes(), &out)
+		return out
+	}
+
+	out := query(folio.RoleAdmin, `mutation {
+		createIncident(namespace: "default", input: {
+			title: "Outage", notes: "secret", steps: [{name: "restart", note: "secret"}]
+		}) { urn notes }
+	}`)
+	assert.Empty(t, out.Errors)
+	assert.Equal(t, "secret", out.Data["createIncident"].(map[string]any)["notes"])
+
+	// Hidden fields resolve to null, including the ones of nested structs
+	out = query(folio.RoleEditor, `{ incidents(namespace: "default") { items { title notes steps { name note } } } }`)
+	assert.Empty(t, out.Errors)
+	assert.JSONEq(t, `{"incidents": {"items": [
+		{"title": "Outage", "notes": null, "steps": [{"name": "restart", "note": null}]}
+	]}}`, string(must(json.Marshal(out.Data))))
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"math/rand/v2"
 	"net/http"
 	"reflect"
@@ -69,8 +70,9 @@ func content(registry folio.Registry, db folio.Storage) http.Handler {
 			return err
 		}
 
+		url := link(r.Context(), fmt.Sprintf("/%s?ns=%s", rx.Kind, rx.Namespace))
 		return w.RenderWith(hxNavigate(rx, ns, list), func(r htmx.Response) htmx.Response {
-			return r.PushURL(fmt.Sprintf("/%s?ns=%s", rx.Kind, rx.Namespace))
+			return r.PushURL(url)
 		})
 	})
 }
@@ -144,6 +146,11 @@ func renderList(rx *Context, r *http.Request, defaultQuery folio.Query) (templ.C
 		return nil, errors.BadRequest("unable to parse query, %v", err)
 	}
 
+	// When listing across all namespaces, the storage only returns the readable objects
+	if query.Namespace != "" && !rx.Can(folio.ActionRead, folio.URN{Namespace: query.Namespace, Kind: rx.Kind}) {
+		return nil, errors.Forbidden("not allowed to view %s in %s", rx.Type.Plural, query.Namespace)
+	}
+
 	// Count the number of objects
 	count, err := rx.Store.Count(rx.Kind, query)
 	if err != nil {
@@ -174,10 +181,14 @@ func editObject(mode Mode, registry folio.Registry, db folio.Storage) http.Handl
 			return errors.BadRequest("invalid request, %v", err)
 		case !rx.URN.IsValid():
 			return errors.BadRequest("invalid URN")
+		case mode == ModeView && !rx.Can(folio.ActionRead, rx.URN):
+			return errors.Forbidden("not allowed to view %s", rx.URN)
+		case mode == ModeEdit && !rx.Can(folio.ActionWrite, rx.URN):
+			return errors.Forbidden("not allowed to edit %s", rx.URN)
 		}
 
 		// Get the person from the database
-		document, err := db.Fetch(rx.URN)
+		document, err := rx.Store.Fetch(rx.URN)
 		if err != nil {
 			return errors.Internal("Unable to fetch object, %v", err)
 		}
@@ -195,6 +206,8 @@ func makeObject(registry folio.Registry, db folio.Storage) http.Handler {
 			return errors.BadRequest("invalid request, %v", err)
 		case len(rx.Namespace) <= 1:
 			return errors.BadRequest("invalid namespace")
+		case !rx.Can(folio.ActionWrite, folio.URN{Namespace: rx.Namespace, Kind: rx.Kind}):
+			return errors.Forbidden("not allowed to create %s in %s", rx.Type.Plural, rx.Namespace)
 		}
 
 		// Create a new object
@@ -224,15 +237,27 @@ func makeObject(registry folio.Registry, db folio.Storage) http.Handler {
 	})
 }
 
-func deleteObject(db folio.Storage) http.Handler {
+func deleteObject(registry folio.Registry, db folio.Storage) http.Handler {
 	return handle(func(r *http.Request, w *Response) error {
 		urn, err := folio.ParseURN(r.PathValue("urn"))
 		if err != nil {
 			return errors.BadRequest("Unable to decode URN, %v", err)
 		}
 
+		rx, err := newContext(ModeView, r, registry, db)
+		switch {
+		case err != nil:
+			return errors.BadRequest("invalid request, %v", err)
+		case !rx.Can(folio.ActionDelete, urn):
+			return errors.Forbidden("not allowed to delete %s", urn)
+		}
+
 		// Get the latest instance from the database
-		if _, err := db.Delete(urn, "sys"); err != nil {
+		_, err = rx.Store.Delete(urn, rx.username())
+		switch {
+		case folio.IsRejected(err):
+			return errors.BadRequest("Unable to delete object, %v", err)
+		case err != nil:
 			return errors.Internal("Unable to delete object, %v", err)
 		}
 
@@ -240,6 +265,41 @@ func deleteObject(db folio.Storage) http.Handler {
 	})
 }
 
+// revealSecret renders the plain value of a secret field, which is masked otherwise.
+func revealSecret(registry folio.Registry, db folio.Storage) http.Handler {
+	return handle(func(r *http.Request, w *Response) error {
+		rx, err := newContext(ModeView, r, registry, db)
+		switch {
+		case err != nil:
+			return errors.BadRequest("invalid request, %v", err)
+		case !rx.Can(folio.ActionRead, rx.URN):
+			return errors.Forbidden("not allowed to view %s", rx.URN)
+		}
+
+		field, ok := rx.Type.Field(rx.Path)
+		switch {
+		case !ok || !isSecret(field):
+			return errors.BadRequest("%s is not a secret", rx.Path)
+		case rx.levelOf(field) == levelHidden:
+			return errors.Forbidden("not allowed to view %s", rx.Path)
+		}
+
+		obj, err := rx.Store.Fetch(rx.URN)
+		if err != nil {
+			return errors.NotFound("unable to find %s, %v", rx.URN, err)
+		}
+
+		fields, err := encodeObject(obj)
+		if err != nil {
+			return errors.Internal("unable to encode %s, %v", rx.URN, err)
+		}
+
+		slog.Info("secret revealed", "urn", rx.URN.String(), "path", rx.Path, "by", rx.username())
+		value, _ := valueAt(fields, string(rx.Path)).(string)
+		return w.Render(hxSecret(value))
+	})
+}
+
 func saveObject(registry folio.Registry, db folio.Storage, vd errors.Validator) http.Handler {
 	return handle(func(r *http.Request, w *Response) error {
 		urn, err := folio.ParseURN(r.PathValue("urn"))
@@ -248,21 +308,28 @@ func saveObject(registry folio.Registry, db folio.Storage, vd errors.Validator)
 		}
 
 		// Make sure this kind exists
-		typ, err := registry.Resolve(urn.Kind)
-		if err != nil {
+		rx, err := newContext(ModeEdit, r, registry, db)
+		switch {
+		case err != nil:
 			return errors.BadRequest("invalid kind, %v", err)
+		case !rx.Can(folio.ActionWrite, urn):
+			return errors.Forbidden("not allowed to save %s", urn)
 		}
 
 		// Get the latest instance from the database
-		instance, err := fetchOrCreate(registry, db, urn)
+		typ := rx.Type
+		instance, err := fetchOrCreate(registry, rx.Store, urn)
 		if err != nil {
 			return errors.Internal("unable to fetch or create object, %v", err)
 		}
 
 		// Hydrate the instance with the new data we've received
 		defer r.Body.Close()
-		validations, err := hydrate(r.Body, typ, instance, vd)
-		if err != nil {
+		validations, err := hydrate(rx, r.Body, instance, vd)
+		switch {
+		case folio.IsForbidden(err):
+			return errors.Forbidden("unable to save %s, %v", urn, err)
+		case err != nil:
 			return errors.BadRequest("unable to decode request, %v", err)
 		}
 
@@ -275,33 +342,59 @@ func saveObject(registry folio.Registry, db folio.Storage, vd errors.Validator)
 			})
 		}
 
+		// A new token gets its secret minted, which can only be shown once
+		var bearer string
+		if token, ok := instance.(*folio.Token); ok && token.CreatedAt == 0 {
+			if bearer, err = mintToken(rx, token); err != nil {
+				return err
+			}
+		}
+
 		// Save the instance back to the database
-		updated, err := folio.Upsert(db, instance, "sys")
-		if err != nil {
+		updated, err := folio.Upsert(rx.Store, instance, rx.username())
+		switch {
+		case folio.IsRejected(err):
+			return errors.BadRequest("unable to save %T, %v", instance, err)
+		case err != nil:
 			return errors.Internal("unable to save %T, %v", instance, err)
 		}
 
+		view := &Context{
+			Mode:      ModeView,
+			Kind:      typ.Kind,
+			Type:      typ,
+			Store:     rx.Store,
+			Registry:  registry,
+			Namespace: urn.Namespace,
+			User:      rx.User,
+			Access:    rx.Access,
+		}
+
 		switch {
+		case bearer != "":
+			return w.Render(hxTokenCreated(view, updated, bearer))
 		case isCreated(updated):
-			return w.Render(hxListElementCreate(&Context{
-				Mode:     ModeView,
-				Kind:     typ.Kind,
-				Type:     typ,
-				Store:    db,
-				Registry: registry,
-			}, updated))
+			return w.Render(hxListElementCreate(view, updated))
 		default:
-			return w.Render(hxListElementUpdate(&Context{
-				Mode:     ModeView,
-				Kind:     typ.Kind,
-				Type:     typ,
-				Store:    db,
-				Registry: registry,
-			}, updated))
+			return w.Render(hxListElementUpdate(view, updated))
 		}
 	})
 }
 
+// mintToken mints the secret of a new token, owned by the principal unless specified otherwise,
+// and returns the bearer value. Every API creating tokens goes through here.
+func mintToken(rx *Context, token *folio.Token) (string, error) {
+	if token.Owner == "" {
+		token.Owner = rx.username()
+	}
+
+	bearer, err := token.Mint()
+	if err != nil {
+		return "", errors.Internal("unable to mint token, %v", err)
+	}
+	return bearer, nil
+}
+
 // ---------------------------------- Field CRUD ----------------------------------
 
 // fetchOrCreate fetches or creates an object from the database.
@@ -336,20 +429,30 @@ func newContext(mode Mode, r *http.Request, reg folio.Registry, db folio.Storage
 		kind = folio.Kind("namespace")
 	}
 
+	return contextOf(mode, r, reg, db, kind, urn, ns)
+}
+
+// contextOf creates a new context for the kind and object, authorized against the principal
+// of the request.
+func contextOf(mode Mode, r *http.Request, reg folio.Registry, db folio.Storage, kind folio.Kind, urn folio.URN, ns string) (*Context, error) {
 	// Resolve the metadata for the kind
 	typ, err := reg.Resolve(kind)
 	if err != nil {
 		return nil, errors.BadRequest("invalid kind, %v", err)
 	}
 
+	// Every storage operation is authorized against the principal
+	acc := accessOf(r)
 	return &Context{
 		Mode:      mode,
 		Path:      Path(r.URL.Query().Get("path")),
 		Kind:      typ.Kind,
 		Type:      typ,
-		Store:     db,
+		Store:     folio.Secure(db, acc.authz, acc.who),
 		Registry:  reg,
 		URN:       urn,
 		Namespace: ns,
+		User:      acc.who,
+		Access:    acc.authz,
 	}, nil
 }
@@ -0,0 +1,145 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+type Credential struct {
+	folio.Meta `kind:"credential" json:",inline"`
+	Name       string `json:"name" form:"rw"`
+	Token      string `json:"token" form:"rw" secret:"true"`
+}
+
+func TestSecret(t *testing.T) {
+	registry := folio.NewRegistry()
+	folio.Register[*Credential](registry)
+	db := sqlite.OpenEphemeral(registry, sqlite.WithKeys(sqlite.KeyFunc(func() ([]byte, error) {
+		return bytes.Repeat([]byte{1}, 32), nil
+	})))
+	defer db.Close()
+
+	credential, err := folio.Create[*Credential](db, func(c *Credential) error {
+		c.Name = "github"
+		c.Token = "s3cr3t"
+		return nil
+	}, "default", "alice")
+	assert.NoError(t, err)
+
+	handler := New(registry, db)
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w
+	}
+
+	// The secret is masked when viewed
+	w := get("/view/" + credential.URN().String())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "••••••••")
+	assert.Contains(t, w.Body.String(), "/reveal/"+credential.URN().String()+"?path=token")
+	assert.NotContains(t, w.Body.String(), "s3cr3t")
+
+	// And never rendered into the form
+	w = get("/edit/" + credential.URN().String())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "s3cr3t")
+
+	// Unless explicitly revealed
+	w = get("/reveal/" + credential.URN().String() + "?path=token")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "s3cr3t")
+
+	w = get("/reveal/" + credential.URN().String() + "?path=name")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// The API leaves the secret out, as well as the export
+	for _, url := range []string{
+		"/api/v1/obj/" + credential.URN().String(),
+		"/api/v1/credential?ns=default",
+		"/export/credential",
+		"/csv/credential",
+		"/api/v1/graphql?query={credentials{items{name%20token}}}",
+	} {
+		w = get(url)
+		assert.Equal(t, http.StatusOK, w.Code, url)
+		assert.Contains(t, w.Body.String(), "github", url)
+		assert.NotContains(t, w.Body.String(), "s3cr3t", url)
+	}
+
+	// Saving an empty secret keeps the current value
+	token := strings.Repeat("t", 32)
+	r := httptest.NewRequest("PUT", "/obj/"+credential.URN().String(), strings.NewReader(`{"name":"gitlab","token":""}`))
+	r.Header.Set(csrfHeader, token)
+	r.AddCookie(&http.Cookie{Name: csrfCookie, Value: token})
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	updated, err := folio.Fetch[*Credential](db, credential.URN())
+	assert.NoError(t, err)
+	assert.Equal(t, "gitlab", updated.Name)
+	assert.Equal(t, "s3cr3t", updated.Token)
+}
+
+type Contract struct {
+	folio.Meta `kind:"contract" json:",inline"`
+	Name       string `json:"name" form:"rw"`
+	Signed     bool   `json:"signed" form:"rw"`
+}
+
+func (c *Contract) BeforeUpdate(ctx folio.HookContext, old folio.Object) error {
+	if old.(*Contract).Signed {
+		return fmt.Errorf("a signed contract cannot be changed")
+	}
+	return nil
+}
+
+func (c *Contract) BeforeDelete() error {
+	if c.Signed {
+		return fmt.Errorf("a signed contract cannot be deleted")
+	}
+	return nil
+}
+
+func TestHooks(t *testing.T) {
+	registry := folio.NewRegistry()
+	folio.Register[*Contract](registry)
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	contract, err := folio.Create[*Contract](db, func(c *Contract) error {
+		c.Name = "lease"
+		c.Signed = true
+		return nil
+	}, "default", "alice")
+	assert.NoError(t, err)
+
+	handler := New(registry, db)
+	request := func(method, body string) *httptest.ResponseRecorder {
+		token := strings.Repeat("t", 32)
+		r := httptest.NewRequest(method, "/obj/"+contract.URN().String(), strings.NewReader(body))
+		r.Header.Set(csrfHeader, token)
+		r.AddCookie(&http.Cookie{Name: csrfCookie, Value: token})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// The errors of the hooks are reported as bad requests
+	w := request("PUT", `{"name":"rental","signed":true}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "a signed contract cannot be changed")
+
+	w = request("DELETE", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "a signed contract cannot be deleted")
+}
@@ -0,0 +1,564 @@
+package render
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/errors"
+)
+
+const (
+	oidcCookie = "folio_oidc"
+	oidcTTL    = 10 * time.Minute
+	oidcSkew   = time.Minute
+	oidcReload = time.Minute
+)
+
+// OIDC represents the configuration of an OpenID Connect provider used for single sign-on.
+type OIDC struct {
+	Name         string                // Name of the provider shown on the login page
+	Issuer       string                // Issuer URL used for discovery (e.g. "https://accounts.google.com")
+	ClientID     string                // Client identifier registered with the provider
+	ClientSecret string                // Client secret registered with the provider
+	RedirectURL  string                // Callback URL, defaults to "/oidc/callback" on the requested host
+	Scopes       []string              // Additional scopes to request, besides "openid email profile"
+	GroupsClaim  string                // Claim containing the groups, defaults to "groups"
+	Role         folio.Role            // Role granted to every signed in user
+	Roles        map[string]folio.Role // Roles granted to specific groups or emails
+	Client       *http.Client          // HTTP client used to reach the provider
+}
+
+// WithOIDC enables the single sign-on using an OpenID Connect provider with the authorization
+// code flow. The verified email of the ID token becomes the name of the principal, or else its
+// issuer and subject, and its groups are mapped to roles using the configuration.
+func WithOIDC(config OIDC) Option {
+	return func(o *options) {
+		o.oidc = newOIDC(config)
+	}
+}
+
+// ---------------------------------- Provider ----------------------------------
+
+// oidcMetadata represents the discovery document of the provider.
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+// oidcState represents the signed state of an authorization request.
+type oidcState struct {
+	State    string `json:"s"`
+	Nonce    string `json:"n"`
+	Verifier string `json:"v"`
+	Expires  int64  `json:"e"`
+}
+
+// oidcProvider performs the authorization code flow with an OpenID Connect provider.
+type oidcProvider struct {
+	config OIDC
+	client *http.Client
+	mu     sync.Mutex
+	meta   *oidcMetadata
+	keys   map[string]crypto.PublicKey
+	loaded time.Time
+}
+
+// newOIDC creates a new provider, the discovery happens lazily on the first sign in.
+func newOIDC(config OIDC) *oidcProvider {
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+
+	client := config.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &oidcProvider{
+		config: config,
+		client: client,
+	}
+}
+
+// name returns the display name of the provider.
+func (p *oidcProvider) name() string {
+	if p.config.Name != "" {
+		return p.config.Name
+	}
+	return "SSO"
+}
+
+// metadata returns the discovery document, fetching it if necessary.
+func (p *oidcProvider) metadata() (*oidcMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	var meta oidcMetadata
+	issuer := strings.TrimSuffix(p.config.Issuer, "/")
+	if err := p.fetch(issuer+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, err
+	}
+
+	// The issuer must match exactly, otherwise tokens can not be validated
+	if meta.Issuer != issuer && meta.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("oidc: issuer mismatch, expected %s but got %s", p.config.Issuer, meta.Issuer)
+	}
+
+	p.meta = &meta
+	return p.meta, nil
+}
+
+// keyOf returns the public key with the specified identifier, refreshing the keys if it is
+// unknown since the provider may have rotated them. The keys are refreshed at most once per
+// minute, so that tokens with made up identifiers can not flood the provider.
+func (p *oidcProvider) keyOf(kid string) (crypto.PublicKey, error) {
+	meta, err := p.metadata()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	if time.Since(p.loaded) < oidcReload {
+		return nil, fmt.Errorf("oidc: unknown signing key '%s'", kid)
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.fetch(meta.JwksURI, &jwks); err != nil {
+		return nil, err
+	}
+
+	p.loaded = time.Now()
+	p.keys = make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if key, err := jwk.PublicKey(); err == nil {
+			p.keys[jwk.Kid] = key
+		}
+	}
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("oidc: unknown signing key '%s'", kid)
+}
+
+// fetch retrieves a JSON document from the provider.
+func (p *oidcProvider) fetch(uri string, dst any) error {
+	resp, err := p.client.Get(uri)
+	if err != nil {
+		return fmt.Errorf("oidc: unable to fetch %s, %w", uri, err)
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: unable to fetch %s, status %d", uri, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
+
+// redirectURL returns the callback URL for the request.
+func (p *oidcProvider) redirectURL(r *http.Request) string {
+	if p.config.RedirectURL != "" {
+		return p.config.RedirectURL
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, r.Host, link(r.Context(), "/oidc/callback"))
+}
+
+// exchange exchanges the authorization code for an ID token.
+func (p *oidcProvider) exchange(r *http.Request, code, verifier string) (string, error) {
+	meta, err := p.metadata()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURL(r)},
+		"code_verifier": {verifier},
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("oidc: unable to exchange code, %w", err)
+	}
+
+	defer resp.Body.Close()
+	var token struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+
+	switch {
+	case json.NewDecoder(resp.Body).Decode(&token) != nil:
+		return "", fmt.Errorf("oidc: unable to decode token response, status %d", resp.StatusCode)
+	case token.Error != "":
+		return "", fmt.Errorf("oidc: unable to exchange code, %s", token.Error)
+	case token.IDToken == "":
+		return "", fmt.Errorf("oidc: no id token in the response")
+	default:
+		return token.IDToken, nil
+	}
+}
+
+// ---------------------------------- ID Token ----------------------------------
+
+// idClaims represents the claims of an ID token.
+type idClaims map[string]any
+
+// verify checks the signature and the standard claims of the ID token.
+func (p *oidcProvider) verify(token, nonce string) (idClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("oidc: malformed id token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("oidc: malformed id token header, %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("oidc: malformed id token signature, %w", err)
+	}
+
+	key, err := p.keyOf(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims idClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("oidc: malformed id token claims, %w", err)
+	}
+
+	meta, err := p.metadata()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	switch {
+	case claims.string("iss") != meta.Issuer:
+		return nil, fmt.Errorf("oidc: invalid issuer '%s'", claims.string("iss"))
+	case !slices.Contains(claims.strings("aud"), p.config.ClientID):
+		return nil, fmt.Errorf("oidc: invalid audience")
+	case now.After(claims.time("exp").Add(oidcSkew)):
+		return nil, fmt.Errorf("oidc: id token expired")
+	case claims.string("nonce") != nonce:
+		return nil, fmt.Errorf("oidc: invalid nonce")
+	default:
+		return claims, nil
+	}
+}
+
+// principal maps the claims of the ID token to a principal. Unless the email is explicitly
+// verified, the subject is qualified by the issuer so that it can not be mistaken for another
+// user, as some providers let users set any email without verifying it.
+func (p *oidcProvider) principal(claims idClaims) (*folio.Principal, error) {
+	name := claims.string("email")
+	if verified, _ := claims["email_verified"].(bool); !verified {
+		name = ""
+	}
+
+	if name == "" {
+		if claims.string("sub") == "" {
+			return nil, fmt.Errorf("oidc: no subject in the id token")
+		}
+		name = "oidc:" + claims.string("iss") + "/" + claims.string("sub")
+	}
+
+	who := &folio.Principal{
+		Name:   name,
+		Groups: claims.strings(p.config.GroupsClaim),
+		Role:   p.config.Role,
+	}
+
+	for subject, role := range p.config.Roles {
+		if who.Is(subject) && role.Includes(who.Role) {
+			who.Role = role
+		}
+	}
+
+	return who, nil
+}
+
+// string returns the claim as a string.
+func (c idClaims) string(name string) string {
+	v, _ := c[name].(string)
+	return v
+}
+
+// strings returns the claim as a list of strings, which can be a single string or an array.
+func (c idClaims) strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// time returns the claim as a time, from the number of seconds since epoch.
+func (c idClaims) time(name string) time.Time {
+	v, _ := c[name].(float64)
+	return time.Unix(int64(v), 0)
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token.
+func decodeSegment(segment string, dst any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+// verifySignature verifies the signature of the signed content with the public key.
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg != "RS256" {
+			return fmt.Errorf("oidc: unsupported algorithm '%s' for RSA key", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature); err != nil {
+			return fmt.Errorf("oidc: invalid id token signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if alg != "ES256" || len(signature) != 64 {
+			return fmt.Errorf("oidc: unsupported algorithm '%s' for EC key", alg)
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(k, digest[:], r, s) {
+			return fmt.Errorf("oidc: invalid id token signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("oidc: unsupported key type %T", key)
+	}
+}
+
+// jsonWebKey represents a public key in the JWK format.
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// PublicKey decodes the public key.
+func (k *jsonWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch {
+	case k.Kty == "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case k.Kty == "EC" && k.Crv == "P-256":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	default:
+		return nil, fmt.Errorf("oidc: unsupported key type '%s'", k.Kty)
+	}
+}
+
+// ---------------------------------- Handlers ----------------------------------
+
+// oidcLogin redirects the browser to the authorization endpoint of the provider.
+func oidcLogin(p *oidcProvider, s *sessions) http.Handler {
+	return handle(func(r *http.Request, w *Response) error {
+		meta, err := p.metadata()
+		if err != nil {
+			return errors.Internal("unable to reach the identity provider, %v", err)
+		}
+
+		state := oidcState{
+			State:    randomString(),
+			Nonce:    randomString(),
+			Verifier: randomString() + randomString(),
+			Expires:  time.Now().Add(oidcTTL).Unix(),
+		}
+
+		// Keep the state in a signed cookie, so it can be checked on the callback
+		value, err := s.encode(state)
+		if err != nil {
+			return err
+		}
+
+		http.SetCookie(w.w, &http.Cookie{
+			Name:     oidcCookie,
+			Value:    value,
+			Path:     link(r.Context(), "/oidc/"),
+			MaxAge:   int(oidcTTL.Seconds()),
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		challenge := sha256.Sum256([]byte(state.Verifier))
+		query := url.Values{
+			"response_type":         {"code"},
+			"client_id":             {p.config.ClientID},
+			"redirect_uri":          {p.redirectURL(r)},
+			"scope":                 {strings.Join(append([]string{"openid", "email", "profile"}, p.config.Scopes...), " ")},
+			"state":                 {state.State},
+			"nonce":                 {state.Nonce},
+			"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+			"code_challenge_method": {"S256"},
+		}
+
+		separator := "?"
+		if strings.Contains(meta.AuthorizationEndpoint, "?") {
+			separator = "&"
+		}
+
+		http.Redirect(w.w, r, meta.AuthorizationEndpoint+separator+query.Encode(), http.StatusFound)
+		return nil
+	})
+}
+
+// oidcCallback completes the authorization code flow and signs the principal in.
+func oidcCallback(o *options) http.Handler {
+	p, s := o.oidc, o.sessions
+	fail := func(w *Response, format string, args ...any) error {
+		w.w.WriteHeader(http.StatusUnauthorized)
+		return w.Render(hxLayout("Folio - Sign in", hxLogin(o, fmt.Sprintf(format, args...))))
+	}
+
+	return handle(func(r *http.Request, w *Response) error {
+		cookie, err := r.Cookie(oidcCookie)
+		if err != nil {
+			return fail(w, "Sign in has expired, please try again")
+		}
+
+		// The state is single use, so clear the cookie right away
+		http.SetCookie(w.w, &http.Cookie{Name: oidcCookie, Path: link(r.Context(), "/oidc/"), MaxAge: -1})
+
+		var state oidcState
+		switch {
+		case s.decode(cookie.Value, &state) != nil:
+			return fail(w, "Sign in has expired, please try again")
+		case time.Now().Unix() > state.Expires:
+			return fail(w, "Sign in has expired, please try again")
+		case r.URL.Query().Get("state") != state.State:
+			return fail(w, "Invalid sign in state, please try again")
+		case r.URL.Query().Get("error") != "":
+			return fail(w, "Unable to sign in, %s", r.URL.Query().Get("error"))
+		}
+
+		token, err := p.exchange(r, r.URL.Query().Get("code"), state.Verifier)
+		if err != nil {
+			return fail(w, "Unable to sign in, %v", err)
+		}
+
+		claims, err := p.verify(token, state.Nonce)
+		if err != nil {
+			return fail(w, "Unable to sign in, %v", err)
+		}
+
+		who, err := p.principal(claims)
+		if err != nil {
+			return fail(w, "Unable to sign in, %v", err)
+		}
+
+		if err := s.issue(w.w, r, session{
+			Name:     who.Name,
+			Groups:   who.Groups,
+			Role:     who.Role,
+			External: true,
+		}); err != nil {
+			return err
+		}
+
+		http.Redirect(w.w, r, link(r.Context(), "/"), http.StatusSeeOther)
+		return nil
+	})
+}
+
+// randomString returns a random URL-safe string.
+func randomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
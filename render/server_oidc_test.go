@@ -0,0 +1,257 @@
+package render
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kelindar/folio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOIDC_Login(t *testing.T) {
+	issuer := newMockIssuer(t)
+	defer issuer.Close()
+
+	o := &options{
+		sessions: newSessions([]byte("secret")),
+		oidc: newOIDC(OIDC{
+			Issuer:       issuer.URL,
+			ClientID:     "folio",
+			ClientSecret: "secret",
+			Role:         folio.RoleViewer,
+			Roles:        map[string]folio.Role{"hr": folio.RoleEditor},
+		}),
+	}
+
+	// Start the login, which redirects to the provider
+	w := httptest.NewRecorder()
+	oidcLogin(o.oidc, o.sessions).ServeHTTP(w, httptest.NewRequest("GET", "http://folio.local/oidc/login", nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, "/authorize", location.Path)
+	assert.Equal(t, "http://folio.local/oidc/callback", location.Query().Get("redirect_uri"))
+	assert.Equal(t, "S256", location.Query().Get("code_challenge_method"))
+	state := w.Result().Cookies()[0]
+
+	// The provider redirects back with the code
+	issuer.nonce = location.Query().Get("nonce")
+	issuer.challenge = location.Query().Get("code_challenge")
+	r := httptest.NewRequest("GET", "http://folio.local/oidc/callback?code=abc&state="+location.Query().Get("state"), nil)
+	r.AddCookie(state)
+	w = httptest.NewRecorder()
+	oidcCallback(o).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+
+	// The session carries the principal with the mapped role
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionCookie {
+			cookie = c
+		}
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(cookie)
+	who, err := o.sessions.Authenticate(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice@example.com", who.Name)
+	assert.Equal(t, []string{"hr"}, who.Groups)
+	assert.Equal(t, folio.RoleEditor, who.Role)
+}
+
+func TestOIDC_InvalidState(t *testing.T) {
+	issuer := newMockIssuer(t)
+	defer issuer.Close()
+
+	o := &options{
+		sessions: newSessions([]byte("secret")),
+		oidc:     newOIDC(OIDC{Issuer: issuer.URL, ClientID: "folio"}),
+	}
+
+	w := httptest.NewRecorder()
+	oidcLogin(o.oidc, o.sessions).ServeHTTP(w, httptest.NewRequest("GET", "/oidc/login", nil))
+	state := w.Result().Cookies()[0]
+
+	r := httptest.NewRequest("GET", "/oidc/callback?code=abc&state=forged", nil)
+	r.AddCookie(state)
+	w = httptest.NewRecorder()
+	oidcCallback(o).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid sign in state")
+}
+
+func TestOIDC_Verify(t *testing.T) {
+	issuer := newMockIssuer(t)
+	defer issuer.Close()
+
+	p := newOIDC(OIDC{Issuer: issuer.URL, ClientID: "folio"})
+	claims := issuer.claims("nonce")
+
+	// Valid token
+	_, err := p.verify(issuer.sign(claims), "nonce")
+	assert.NoError(t, err)
+
+	// Wrong nonce
+	_, err = p.verify(issuer.sign(claims), "other")
+	assert.Error(t, err)
+
+	// Wrong audience
+	claims["aud"] = "other"
+	_, err = p.verify(issuer.sign(claims), "nonce")
+	assert.Error(t, err)
+
+	// Expired token
+	claims = issuer.claims("nonce")
+	claims["exp"] = time.Now().Add(-time.Hour).Unix()
+	_, err = p.verify(issuer.sign(claims), "nonce")
+	assert.Error(t, err)
+
+	// Tampered token
+	token := issuer.sign(issuer.claims("nonce"))
+	_, err = p.verify(token[:len(token)-4]+"AAAA", "nonce")
+	assert.Error(t, err)
+}
+
+func TestOIDC_UnknownKey(t *testing.T) {
+	issuer := newMockIssuer(t)
+	defer issuer.Close()
+
+	p := newOIDC(OIDC{Issuer: issuer.URL, ClientID: "folio"})
+	_, err := p.verify(issuer.sign(issuer.claims("nonce")), "nonce")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), issuer.fetches.Load())
+
+	// Unknown keys only refresh the keys once per interval
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","kid":"rotated"}`))
+	for i := 0; i < 3; i++ {
+		_, err = p.verify(header+".e30.AAAA", "nonce")
+		assert.ErrorContains(t, err, "unknown signing key")
+	}
+	assert.Equal(t, int32(1), issuer.fetches.Load())
+
+	p.loaded = time.Now().Add(-oidcReload)
+	_, err = p.verify(header+".e30.AAAA", "nonce")
+	assert.ErrorContains(t, err, "unknown signing key")
+	assert.Equal(t, int32(2), issuer.fetches.Load())
+}
+
+func TestOIDC_Principal(t *testing.T) {
+	p := newOIDC(OIDC{Issuer: "https://issuer.example.com", ClientID: "folio"})
+	claims := idClaims{
+		"iss":            "https://issuer.example.com",
+		"sub":            "12345",
+		"email":          "alice@example.com",
+		"email_verified": true,
+	}
+
+	who, err := p.principal(claims)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice@example.com", who.Name)
+
+	// Without a verified email, the subject is qualified by the issuer
+	claims["email_verified"] = false
+	who, err = p.principal(claims)
+	assert.NoError(t, err)
+	assert.Equal(t, "oidc:https://issuer.example.com/12345", who.Name)
+
+	// Or when the claim is absent
+	delete(claims, "email_verified")
+	who, err = p.principal(claims)
+	assert.NoError(t, err)
+	assert.Equal(t, "oidc:https://issuer.example.com/12345", who.Name)
+
+	delete(claims, "sub")
+	_, err = p.principal(claims)
+	assert.Error(t, err)
+}
+
+// ---------------------------------- Mock Issuer ----------------------------------
+
+type mockIssuer struct {
+	*httptest.Server
+	t         *testing.T
+	key       *rsa.PrivateKey
+	nonce     string
+	challenge string
+	fetches   atomic.Int32
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	m := &mockIssuer{t: t, key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.URL,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"jwks_uri":               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		m.fetches.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kid": "test",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		verifier := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		switch {
+		case id != "folio" || secret != "secret" || r.FormValue("code") != "abc":
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		case base64.RawURLEncoding.EncodeToString(verifier[:]) != m.challenge:
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		default:
+			json.NewEncoder(w).Encode(map[string]string{"id_token": m.sign(m.claims(m.nonce))})
+		}
+	})
+
+	m.Server = httptest.NewServer(mux)
+	return m
+}
+
+func (m *mockIssuer) claims(nonce string) map[string]any {
+	return map[string]any{
+		"iss":            m.URL,
+		"sub":            "12345",
+		"aud":            []string{"folio"},
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"groups":         []string{"hr"},
+	}
+}
+
+func (m *mockIssuer) sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, digest[:])
+	assert.NoError(m.t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
@@ -0,0 +1,215 @@
+package render
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/kelindar/folio"
+)
+
+// openAPI serves the OpenAPI document describing the JSON API.
+func openAPI(registry folio.Registry) http.Handler {
+	return handleAPI(func(r *http.Request, w http.ResponseWriter) error {
+		return writeJSON(w, http.StatusOK, openAPIOf(registry, link(r.Context(), "")))
+	})
+}
+
+// openAPIOf generates the OpenAPI 3.1 document for the types of the registry.
+func openAPIOf(registry folio.Registry, prefix string) map[string]any {
+	schemas := map[string]any{
+		"Error": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"status": map[string]any{"type": "integer"},
+				"error":  map[string]any{"type": "string"},
+				"validations": map[string]any{
+					"type": "array",
+					"items": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"path":    map[string]any{"type": "string"},
+							"message": map[string]any{"type": "string"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	paths := make(map[string]any)
+	objects := make([]any, 0, 8)
+	types := slices.SortedFunc(registry.Types(), func(a, b folio.Type) int {
+		return strings.Compare(string(a.Kind), string(b.Kind))
+	})
+
+	for _, typ := range types {
+		name := typ.Type.Name()
+		ref := refOf(name)
+		schemas[name] = typ.Schema()
+		objects = append(objects, ref)
+
+		paths[prefix+"/api/v1/"+string(typ.Kind)] = map[string]any{
+			"get": map[string]any{
+				"operationId": "list" + name,
+				"summary":     "List " + typ.Plural,
+				"tags":        []string{typ.Title},
+				"parameters": []any{
+					queryParam("ns", "Namespace, or * for all namespaces", "string"),
+					queryParam("state", "Comma-separated states", "string"),
+					queryParam("filter", "Filter as field:value, can be repeated", "string"),
+					queryParam("match", "Full-text search", "string"),
+					queryParam("sort", "Comma-separated fields to sort by, prefixed by - for descending order", "string"),
+					queryParam("offset", "Number of objects to skip", "integer"),
+					queryParam("limit", "Maximum number of objects to return", "integer"),
+				},
+				"responses": map[string]any{
+					"200": jsonResponse("A page of "+typ.Plural, map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"items":  map[string]any{"type": "array", "items": ref},
+							"total":  map[string]any{"type": "integer"},
+							"offset": map[string]any{"type": "integer"},
+							"limit":  map[string]any{"type": "integer"},
+						},
+					}),
+					"default": errorResponse(),
+				},
+			},
+			"post": map[string]any{
+				"operationId": "create" + name,
+				"summary":     "Create a " + typ.Title,
+				"tags":        []string{typ.Title},
+				"parameters":  []any{queryParam("ns", "Namespace, unless specified in the body", "string")},
+				"requestBody": jsonBody(ref),
+				"responses": map[string]any{
+					"201":     jsonResponse("The created "+typ.Title, ref),
+					"default": errorResponse(),
+				},
+			},
+		}
+	}
+
+	object := map[string]any{
+		"oneOf":         objects,
+		"discriminator": map[string]any{"propertyName": "kind"},
+	}
+
+	paths[prefix+"/api/v1/obj/{urn}"] = map[string]any{
+		"parameters": []any{map[string]any{
+			"name":     "urn",
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": "string"},
+		}},
+		"get": map[string]any{
+			"operationId": "fetchObject",
+			"summary":     "Fetch an object",
+			"responses": map[string]any{
+				"200":     jsonResponse("The object, with its version in the ETag header", object),
+				"default": errorResponse(),
+			},
+		},
+		"put": map[string]any{
+			"operationId": "updateObject",
+			"summary":     "Replace an object",
+			"parameters":  []any{ifMatchParam()},
+			"requestBody": jsonBody(object),
+			"responses": map[string]any{
+				"200":     jsonResponse("The updated object", object),
+				"default": errorResponse(),
+			},
+		},
+		"patch": map[string]any{
+			"operationId": "patchObject",
+			"summary":     "Apply a JSON merge patch to an object",
+			"parameters":  []any{ifMatchParam()},
+			"requestBody": map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/merge-patch+json": map[string]any{"schema": map[string]any{"type": "object"}},
+					"application/json":             map[string]any{"schema": map[string]any{"type": "object"}},
+				},
+			},
+			"responses": map[string]any{
+				"200":     jsonResponse("The updated object", object),
+				"default": errorResponse(),
+			},
+		},
+		"delete": map[string]any{
+			"operationId": "deleteObject",
+			"summary":     "Delete an object",
+			"parameters":  []any{ifMatchParam()},
+			"responses": map[string]any{
+				"200":     jsonResponse("The deleted object", object),
+				"default": errorResponse(),
+			},
+		},
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":   "Folio",
+			"version": "v1",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+		"security": []any{map[string]any{"bearer": []string{}}},
+	}
+}
+
+// refOf returns a reference to a schema component.
+func refOf(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// queryParam returns an optional query parameter.
+func queryParam(name, description, typ string) map[string]any {
+	return map[string]any{
+		"name":        name,
+		"in":          "query",
+		"description": description,
+		"schema":      map[string]any{"type": typ},
+	}
+}
+
+// ifMatchParam returns the If-Match header parameter.
+func ifMatchParam() map[string]any {
+	return map[string]any{
+		"name":        "If-Match",
+		"in":          "header",
+		"description": "ETag of the version being modified, fails with 412 if outdated",
+		"schema":      map[string]any{"type": "string"},
+	}
+}
+
+// jsonBody returns a required JSON request body.
+func jsonBody(schema any) map[string]any {
+	return map[string]any{
+		"required": true,
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schema},
+		},
+	}
+}
+
+// jsonResponse returns a JSON response.
+func jsonResponse(description string, schema any) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schema},
+		},
+	}
+}
+
+// errorResponse returns the error response.
+func errorResponse() map[string]any {
+	return jsonResponse("Error", refOf("Error"))
+}
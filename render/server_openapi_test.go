@@ -0,0 +1,49 @@
+package render
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestOpenAPI(t *testing.T) {
+	registry := folio.NewRegistry()
+	folio.Register[*Ticket](registry)
+
+	out, err := json.MarshalIndent(openAPIOf(registry, ""), "", "  ")
+	assert.NoError(t, err)
+
+	const golden = "testdata/openapi.json"
+	if *update {
+		assert.NoError(t, os.MkdirAll("testdata", 0o755))
+		assert.NoError(t, os.WriteFile(golden, out, 0o644))
+	}
+
+	expect, err := os.ReadFile(golden)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(expect), string(out))
+}
+
+func TestOpenAPI_Serve(t *testing.T) {
+	registry := folio.NewRegistry()
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	w := httptest.NewRecorder()
+	New(registry, db, WithPrefix("/admin")).ServeHTTP(w, httptest.NewRequest("GET", "/admin/api/v1/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var doc map[string]any
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "3.1.0", doc["openapi"])
+	assert.Contains(t, doc["paths"], "/admin/api/v1/namespace")
+}
@@ -0,0 +1,272 @@
+package render
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kelindar/folio"
+)
+
+const (
+	sessionCookie = "folio_session"
+	sessionTTL    = 12 * time.Hour
+)
+
+// WithUsers enables the built-in user accounts, with a login page and session cookies signed
+// with the secret. If the secret is empty, a random one is generated and the sessions do not
+// survive a restart. When there are no users, an "admin" user is created with the password
+// from the FOLIO_ADMIN_PASSWORD environment variable, or a random one which is logged.
+func WithUsers(secret []byte) Option {
+	return func(o *options) {
+		o.sessions = newSessions(secret)
+		o.sessions.users = true
+	}
+}
+
+// ---------------------------------- Sessions ----------------------------------
+
+// session represents the signed content of a session cookie.
+type session struct {
+	Name     string     `json:"n"`           // Name of the principal
+	Groups   []string   `json:"g,omitempty"` // Groups of an external principal
+	Role     folio.Role `json:"r,omitempty"` // Role of an external principal
+	External bool       `json:"x,omitempty"` // Whether the principal is not a built-in user
+	Expires  int64      `json:"e"`           // Expiration time, in unix seconds
+}
+
+// sessions issues and verifies signed session cookies.
+type sessions struct {
+	secret []byte
+	ttl    time.Duration
+	store  folio.Storage
+	users  bool // Whether the built-in users can sign in
+}
+
+// newSessions creates a new session manager. If the secret is empty, a random one is used.
+func newSessions(secret []byte) *sessions {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	}
+
+	return &sessions{
+		secret: secret,
+		ttl:    sessionTTL,
+	}
+}
+
+// Authenticate returns the principal of the user of the session.
+func (s *sessions) Authenticate(r *http.Request) (*folio.Principal, error) {
+	sess, err := s.verify(r)
+	switch {
+	case err != nil:
+		return nil, err
+	case sess.External:
+		return &folio.Principal{
+			Name:   sess.Name,
+			Groups: sess.Groups,
+			Role:   sess.Role,
+		}, nil
+	}
+
+	// Always load the user, so that changes of roles or deleted users apply immediately
+	user, err := folio.FindUser(s.store, sess.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return user.Principal(), nil
+}
+
+// issue sets a new session cookie.
+func (s *sessions) issue(w http.ResponseWriter, r *http.Request, sess session) error {
+	expires := time.Now().Add(s.ttl)
+	sess.Expires = expires.Unix()
+	value, err := s.encode(sess)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    value,
+		Path:     link(r.Context(), "/"),
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// verify decodes the session cookie and checks its signature and expiration.
+func (s *sessions) verify(r *http.Request) (*session, error) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil, fmt.Errorf("%w, no session", folio.ErrUnauthorized)
+	}
+
+	var out session
+	switch {
+	case s.decode(cookie.Value, &out) != nil:
+		return nil, fmt.Errorf("%w, invalid session", folio.ErrUnauthorized)
+	case time.Now().Unix() > out.Expires:
+		return nil, fmt.Errorf("%w, session expired", folio.ErrUnauthorized)
+	default:
+		return &out, nil
+	}
+}
+
+// encode encodes the value as JSON and signs it.
+func (s *sessions) encode(v any) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	value := base64.RawURLEncoding.EncodeToString(payload)
+	return value + "." + s.sign(value), nil
+}
+
+// decode verifies the signature of the value and decodes it.
+func (s *sessions) decode(text string, v any) error {
+	value, signature, ok := strings.Cut(text, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(value))) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(payload, v)
+}
+
+// sign returns the signature of the value.
+func (s *sessions) sign(value string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// clear removes the session cookie.
+func (s *sessions) clear(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     link(r.Context(), "/"),
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// bootstrap creates the initial administrator if there are no users yet.
+func (s *sessions) bootstrap() error {
+	password := os.Getenv("FOLIO_ADMIN_PASSWORD")
+	generated := password == ""
+	if generated {
+		secret := make([]byte, 12)
+		rand.Read(secret)
+		password = base64.RawURLEncoding.EncodeToString(secret)
+	}
+
+	created, err := folio.Bootstrap(s.store, "admin", password)
+	switch {
+	case err != nil:
+		return err
+	case created && generated:
+		slog.Warn("created initial user, please change the password", "user", "admin", "password", password)
+	case created:
+		slog.Info("created initial user", "user", "admin")
+	}
+	return nil
+}
+
+// ---------------------------------- Handlers ----------------------------------
+
+// loginPage renders the login page.
+func loginPage(o *options) http.Handler {
+	return handle(func(r *http.Request, w *Response) error {
+		return w.Render(hxLayout("Folio - Sign in", hxLogin(o, "")))
+	})
+}
+
+// login signs the user in with the username and password.
+func login(o *options) http.Handler {
+	s := o.sessions
+	return handle(func(r *http.Request, w *Response) error {
+		user, err := folio.Authenticate(s.store, r.FormValue("username"), r.FormValue("password"))
+		switch {
+		case folio.IsUnauthorized(err):
+			w.w.WriteHeader(http.StatusUnauthorized)
+			return w.Render(hxLayout("Folio - Sign in", hxLogin(o, "Invalid username or password")))
+		case err != nil:
+			return err
+		}
+
+		if err := s.issue(w.w, r, session{Name: user.Name}); err != nil {
+			return err
+		}
+
+		http.Redirect(w.w, r, link(r.Context(), "/"), http.StatusSeeOther)
+		return nil
+	})
+}
+
+// passwordPage renders the page where users change their own password.
+func passwordPage(o *options) http.Handler {
+	return handle(func(r *http.Request, w *Response) error {
+		return w.Render(hxLayout("Folio - Change password", hxPassword(o, "")))
+	})
+}
+
+// changePassword changes the password of the signed in user, who must provide the current one.
+func changePassword(o *options) http.Handler {
+	s := o.sessions
+	return handle(func(r *http.Request, w *Response) error {
+		name := accessOf(r).who.Name
+		err := folio.ChangePassword(s.store, name, r.FormValue("current"), r.FormValue("password"))
+		switch {
+		case folio.IsUnauthorized(err):
+			w.w.WriteHeader(http.StatusUnauthorized)
+			return w.Render(hxLayout("Folio - Change password", hxPassword(o, "Invalid current password")))
+		case folio.IsRejected(err):
+			w.w.WriteHeader(http.StatusBadRequest)
+			return w.Render(hxLayout("Folio - Change password", hxPassword(o, "The new password must not be empty")))
+		case err != nil:
+			return err
+		}
+
+		http.Redirect(w.w, r, link(r.Context(), "/"), http.StatusSeeOther)
+		return nil
+	})
+}
+
+// logout signs the user out and redirects to the login page.
+func logout(s *sessions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.clear(w, r)
+		redirect(w, r, link(r.Context(), "/login"))
+	})
+}
+
+// redirect redirects the browser, using a client-side redirect for htmx requests.
+func redirect(w http.ResponseWriter, r *http.Request, url string) {
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", url)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	http.Redirect(w, r, url, http.StatusSeeOther)
+}
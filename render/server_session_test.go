@@ -0,0 +1,132 @@
+package render
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessions_Login(t *testing.T) {
+	db := sqlite.OpenEphemeral(folio.NewRegistry())
+	defer db.Close()
+
+	s := &sessions{secret: []byte("secret"), ttl: time.Hour, store: db, users: true}
+	_, err := folio.Bootstrap(db, "admin", "password")
+	assert.NoError(t, err)
+
+	// Wrong password
+	w := httptest.NewRecorder()
+	login(&options{sessions: s}).ServeHTTP(w, newLogin("admin", "wrong"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, w.Result().Cookies())
+
+	// Correct password
+	w = httptest.NewRecorder()
+	login(&options{sessions: s}).ServeHTTP(w, newLogin("admin", "password"))
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Len(t, w.Result().Cookies(), 1)
+
+	// The session is authenticated
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(w.Result().Cookies()[0])
+	who, err := s.Authenticate(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", who.Name)
+	assert.Equal(t, folio.RoleAdmin, who.Role)
+}
+
+func TestSessions_ChangePassword(t *testing.T) {
+	db := sqlite.OpenEphemeral(folio.NewRegistry())
+	defer db.Close()
+
+	o := &options{sessions: &sessions{secret: []byte("secret"), ttl: time.Hour, store: db, users: true}}
+	_, err := folio.Bootstrap(db, "admin", "password")
+	assert.NoError(t, err)
+
+	change := func(current, next string) int {
+		form := url.Values{"current": {current}, "password": {next}}
+		r := httptest.NewRequest("POST", "/password", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r = r.WithContext(context.WithValue(r.Context(), accessKey{}, &access{
+			who: &folio.Principal{Name: "admin"},
+		}))
+
+		w := httptest.NewRecorder()
+		changePassword(o).ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, change("wrong", "changed"))
+	assert.Equal(t, http.StatusBadRequest, change("password", ""))
+	assert.Equal(t, http.StatusSeeOther, change("password", "changed"))
+
+	_, err = folio.Authenticate(db, "admin", "changed")
+	assert.NoError(t, err)
+}
+
+func TestSessions_Invalid(t *testing.T) {
+	s := &sessions{secret: []byte("secret"), ttl: time.Hour}
+	w := httptest.NewRecorder()
+	assert.NoError(t, s.issue(w, httptest.NewRequest("GET", "/", nil), session{Name: "admin"}))
+	cookie := w.Result().Cookies()[0]
+
+	// Valid signature
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(cookie)
+	sess, err := s.verify(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", sess.Name)
+
+	// Tampered signature
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: sessionCookie, Value: cookie.Value + "x"})
+	_, err = s.verify(r)
+	assert.True(t, folio.IsUnauthorized(err))
+
+	// Signed with another secret
+	other := &sessions{secret: []byte("other"), ttl: time.Hour}
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(cookie)
+	_, err = other.verify(r)
+	assert.True(t, folio.IsUnauthorized(err))
+
+	// Expired session
+	expired := &sessions{secret: []byte("secret"), ttl: -time.Hour}
+	w = httptest.NewRecorder()
+	assert.NoError(t, expired.issue(w, httptest.NewRequest("GET", "/", nil), session{Name: "admin"}))
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(w.Result().Cookies()[0])
+	_, err = s.verify(r)
+	assert.True(t, folio.IsUnauthorized(err))
+}
+
+func TestWithAccess_Redirect(t *testing.T) {
+	s := &sessions{secret: []byte("secret"), ttl: time.Hour}
+	handler := withAccess(&options{authenticator: s, sessions: s}, http.NotFoundHandler())
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+
+	r := httptest.NewRequest("GET", "/content/user", nil)
+	r.Header.Set("HX-Request", "true")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, "/login", w.Header().Get("HX-Redirect"))
+}
+
+func newLogin(username, password string) *http.Request {
+	form := url.Values{"username": {username}, "password": {password}}
+	r := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
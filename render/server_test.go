@@ -0,0 +1,126 @@
+package render
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kelindar/folio"
+	"github.com/kelindar/folio/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_Prefix(t *testing.T) {
+	registry := folio.NewRegistry()
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	var order []string
+	middleware := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/admin/", New(registry, db, WithPrefix("/admin/"), WithMiddleware(middleware("a"), middleware("b"))))
+	mux.Handle("/other/", New(registry, db, WithPrefix("other")))
+
+	// Links are rendered under the prefix
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/admin/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `src="/admin/assets/scripts.js"`)
+	assert.Contains(t, w.Body.String(), `hx-get="/admin/content/namespace`)
+	assert.Equal(t, []string{"a", "b"}, order)
+
+	// Assets are served under the prefix
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/other/assets/scripts.js", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"a", "b"}, order)
+
+	// The prefix without a trailing slash redirects
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/admin", nil))
+	assert.Equal(t, "/admin/", w.Header().Get("Location"))
+}
+
+func TestServe_Shutdown(t *testing.T) {
+	registry := folio.NewRegistry()
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, 0, registry, db, WithServer(&http.Server{Addr: "127.0.0.1:0"}))
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+}
+
+func TestOffline_Vendored(t *testing.T) {
+	for _, name := range vendored {
+		_, err := fs.Stat(assets, name)
+		assert.NoError(t, err, "run go generate in the render package and commit %s", name)
+	}
+}
+
+func TestServe_OfflineMissing(t *testing.T) {
+	if len(missingAssets()) == 0 {
+		t.Skip("the offline assets are vendored")
+	}
+
+	registry := folio.NewRegistry()
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	err := Serve(context.Background(), 0, registry, db, WithOffline(), WithServer(&http.Server{Addr: "127.0.0.1:0"}))
+	assert.ErrorContains(t, err, "offline assets are missing")
+}
+
+func TestOffline_Assets(t *testing.T) {
+	if missing := missingAssets(); len(missing) > 0 {
+		t.Skipf("offline assets are missing, run go generate in the render package: %v", missing)
+	}
+
+	registry := folio.NewRegistry()
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	handler := New(registry, db, WithOffline())
+	for _, name := range vendored {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/"+name, nil))
+		assert.Equal(t, http.StatusOK, w.Code, name)
+		assert.NotZero(t, w.Body.Len(), name)
+	}
+}
+
+func TestNew_Offline(t *testing.T) {
+	registry := folio.NewRegistry()
+	db := sqlite.OpenEphemeral(registry)
+	defer db.Close()
+
+	w := httptest.NewRecorder()
+	New(registry, db, WithOffline()).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `src="/assets/vendor/htmx.min.js"`)
+	assert.Contains(t, w.Body.String(), `script-src 'self' 'unsafe-eval';`)
+	assert.NotContains(t, w.Body.String(), "https://")
+}
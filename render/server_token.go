@@ -0,0 +1,43 @@
+package render
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/kelindar/folio"
+)
+
+// bearerOf returns the bearer token of the request, if any.
+func bearerOf(r *http.Request) (string, bool) {
+	scheme, value, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	value = strings.TrimSpace(value)
+	return value, value != ""
+}
+
+// tokenAccess authenticates the API token and returns the access of its owner, restricted
+// to the scopes of the token.
+func tokenAccess(o *options, bearer string) (*access, error) {
+	if o.store == nil {
+		return nil, folio.ErrUnauthorized
+	}
+
+	token, err := folio.AuthenticateToken(o.store, bearer)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens act on behalf of their owner, which may be a service without a user account
+	who := &folio.Principal{Name: token.Owner}
+	if user, err := folio.FindUser(o.store, token.Owner); err == nil {
+		who = user.Principal()
+	}
+
+	return &access{
+		who:   who,
+		authz: folio.Restrict(o.authorizer, token.Scopes...),
+	}, nil
+}
@@ -0,0 +1,376 @@
+package folio
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	typeURN      = reflect.TypeOf(URN{})
+	typeTime     = reflect.TypeOf(time.Time{})
+	typeDuration = reflect.TypeOf(time.Duration(0))
+	regexRule    = regexp.MustCompile(`^(!?\w+)(?:\(([^)]+)\))?$`)
+)
+
+// SchemaDialect is the JSON Schema dialect of the generated schemas.
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema represents a JSON Schema (draft 2020-12) describing an object or one of its fields.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	Not                  *Schema            `json:"not,omitempty"`
+	Kind                 Kind               `json:"x-kind,omitempty"`
+}
+
+// Schema returns the schema of the objects of this kind, with the constraints translated
+// from the "is" tags of the fields.
+func (t *Type) Schema() *Schema {
+	out := schemaOf(t.Type)
+	out.Title = t.Title
+	return out
+}
+
+// JSONSchema returns a standalone JSON Schema (draft 2020-12) document for the objects of
+// this kind, which can be used to validate them outside of Go.
+func (t *Type) JSONSchema() *Schema {
+	out := t.Schema()
+	out.Schema = SchemaDialect
+	out.Kind = t.Kind
+	return out
+}
+
+// schemaOf returns the schema for the type.
+func schemaOf(typ reflect.Type) *Schema {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch {
+	case typ == typeURN:
+		return &Schema{Type: "string", Pattern: patternOf("", false)}
+	case typ == typeTime:
+		return &Schema{Type: "string", Format: "date-time"}
+	case typ == typeDuration:
+		return &Schema{Type: "integer"}
+	case typ == typeEmbedded:
+		return &Schema{Type: "object"}
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaOf(typ.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaOf(typ.Elem())}
+	case reflect.Struct:
+		out := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		walkSchema(typ, out)
+		return out
+	default:
+		return &Schema{}
+	}
+}
+
+// walkSchema adds the fields of the struct type as the properties of the schema.
+func walkSchema(typ reflect.Type, out *Schema) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, inline := jsonName(field)
+		switch {
+		case !field.IsExported():
+			continue
+		case inline:
+			walkSchema(field.Type, out)
+			continue
+		case name == "":
+			continue // Skip fields with JSON tag "-"
+		}
+
+		schema := schemaOf(field.Type)
+		schema.Description = field.Tag.Get("desc")
+		required := applyRules(schema, field.Tag.Get("is"))
+		if required {
+			out.Required = append(out.Required, name)
+		}
+
+		// References to other objects must be valid URNs of the specified kind
+		if deref(field.Type) == typeURN {
+			schema.Kind = Kind(field.Tag.Get("kind"))
+			schema.Pattern = patternOf(schema.Kind, required)
+		}
+
+		// Pointers are optional, so they can also be null
+		if field.Type.Kind() == reflect.Pointer && !required {
+			schema = &Schema{
+				Description: schema.Description,
+				AnyOf:       []*Schema{schema, {Type: "null"}},
+			}
+			schema.AnyOf[0].Description = ""
+		}
+
+		out.Properties[name] = schema
+	}
+}
+
+// deref returns the type pointed to.
+func deref(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+// patternOf returns the pattern of a URN of the kind. Empty URNs are only allowed if they
+// are not required.
+func patternOf(kind Kind, required bool) string {
+	name := `[a-z][a-z0-9_]{1,19}`
+	if kind != "" {
+		name = regexp.QuoteMeta(string(kind))
+	}
+
+	urn := `urn:[a-z][a-z0-9_]{1,19}:` + name + `:[0-9a-v]{20}`
+	if required {
+		return "^" + urn + "$"
+	}
+	return "^(" + urn + ")?$"
+}
+
+// applyRules translates the validation rules of an "is" tag into constraints of the schema,
+// and returns whether the field is required. Negated rules (e.g. "!in(a|b)") are translated
+// into a "not" constraint.
+func applyRules(schema *Schema, tag string) (required bool) {
+	for _, rule := range strings.Split(tag, ",") {
+		rule, _, _ = strings.Cut(strings.TrimSpace(rule), "~")
+		match := regexRule.FindStringSubmatch(rule)
+		if match == nil {
+			continue
+		}
+
+		name, negated := strings.CutPrefix(match[1], "!")
+		if name == "required" {
+			required = !negated
+			continue
+		}
+
+		// Constraints of a collection apply to its elements, except for its size
+		target := schema
+		if schema.Items != nil && !sizeRules[name] {
+			target = schema.Items
+		}
+
+		// Translate the rule into a constraint of the same type as the target
+		constraint := ruleOf(target.Type, name, match[2])
+		switch {
+		case constraint == nil:
+			continue
+		case !negated:
+			merge(target, constraint)
+		case target.Not == nil:
+			target.Not = constraint
+		default:
+			target.AllOf = append(target.AllOf, &Schema{Not: constraint})
+		}
+	}
+	return
+}
+
+// ruleOf returns the constraint for a validation rule, or nil if it can not be expressed.
+func ruleOf(typ, name, param string) *Schema {
+	params := strings.Split(param, "|")
+	out := new(Schema)
+	if typ == "array" {
+		return sizeOf(name, params)
+	}
+
+	switch name {
+	case "in":
+		for _, param := range params {
+			out.Enum = append(out.Enum, valueOf(typ, param))
+		}
+	case "range":
+		if len(params) != 2 {
+			return nil
+		}
+		out.Minimum, out.Maximum = numberOf(params[0]), numberOf(params[1])
+	case "min":
+		out.Minimum = numberOf(params[0])
+	case "max":
+		out.Maximum = numberOf(params[0])
+	case "minlen":
+		out.MinLength = lengthOf(params[0])
+	case "maxlen":
+		out.MaxLength = lengthOf(params[0])
+	case "length", "runelength", "stringlength":
+		if len(params) != 2 {
+			return nil
+		}
+		out.MinLength, out.MaxLength = lengthOf(params[0]), lengthOf(params[1])
+	case "matches":
+		out.Pattern = param
+	default:
+		if format, ok := formats[name]; ok {
+			out.Format = format
+		} else if pattern, ok := patterns[name]; ok {
+			out.Pattern = pattern
+		} else {
+			return nil
+		}
+	}
+	return out
+}
+
+// sizeRules are the validation rules which constrain the number of elements of a collection.
+var sizeRules = map[string]bool{
+	"min": true, "max": true, "range": true, "minlen": true, "maxlen": true,
+	"length": true, "runelength": true, "stringlength": true,
+}
+
+// sizeOf returns the constraint on the number of elements of a collection, or nil if the rule
+// does not constrain its size.
+func sizeOf(name string, params []string) *Schema {
+	out := new(Schema)
+	switch name {
+	case "min", "minlen":
+		out.MinItems = lengthOf(params[0])
+	case "max", "maxlen":
+		out.MaxItems = lengthOf(params[0])
+	case "range", "length", "runelength", "stringlength":
+		if len(params) != 2 {
+			return nil
+		}
+		out.MinItems, out.MaxItems = lengthOf(params[0]), lengthOf(params[1])
+	default:
+		return nil
+	}
+	return out
+}
+
+// merge merges the constraint into the schema. A pattern, format or enumeration which is
+// already set is kept, and the constraint is added to "allOf" so that both apply.
+func merge(schema, constraint *Schema) {
+	switch {
+	case constraint.Pattern != "" && schema.Pattern != "":
+		schema.AllOf = append(schema.AllOf, &Schema{Pattern: constraint.Pattern})
+	case constraint.Pattern != "":
+		schema.Pattern = constraint.Pattern
+	}
+	switch {
+	case constraint.Format != "" && schema.Format != "":
+		schema.AllOf = append(schema.AllOf, &Schema{Format: constraint.Format})
+	case constraint.Format != "":
+		schema.Format = constraint.Format
+	}
+	switch {
+	case constraint.Enum != nil && schema.Enum != nil:
+		schema.AllOf = append(schema.AllOf, &Schema{Enum: constraint.Enum})
+	case constraint.Enum != nil:
+		schema.Enum = constraint.Enum
+	}
+	if constraint.Minimum != nil {
+		schema.Minimum = constraint.Minimum
+	}
+	if constraint.Maximum != nil {
+		schema.Maximum = constraint.Maximum
+	}
+	if constraint.MinLength != nil {
+		schema.MinLength = constraint.MinLength
+	}
+	if constraint.MaxLength != nil {
+		schema.MaxLength = constraint.MaxLength
+	}
+	if constraint.MinItems != nil {
+		schema.MinItems = constraint.MinItems
+	}
+	if constraint.MaxItems != nil {
+		schema.MaxItems = constraint.MaxItems
+	}
+}
+
+// formats maps the validators to their equivalent JSON Schema formats.
+var formats = map[string]string{
+	"email":   "email",
+	"url":     "uri",
+	"requrl":  "uri",
+	"requri":  "uri-reference",
+	"uuid":    "uuid",
+	"ipv4":    "ipv4",
+	"ipv6":    "ipv6",
+	"dns":     "hostname",
+	"rfc3339": "date-time",
+}
+
+// patterns maps the validators to their equivalent regular expressions.
+var patterns = map[string]string{
+	"alpha":       `^[a-zA-Z]+$`,
+	"alphanum":    `^[a-zA-Z0-9]+$`,
+	"numeric":     `^[0-9]+$`,
+	"hexadecimal": `^[0-9a-fA-F]+$`,
+	"hexcolor":    `^#?([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`,
+	"snake":       `^[a-z][a-z0-9_]+$`,
+	"lowercase":   `^[^A-Z]*$`,
+	"uppercase":   `^[^a-z]*$`,
+	"ascii":       `^[\x00-\x7F]+$`,
+}
+
+// valueOf converts the parameter of a rule to the type of the schema.
+func valueOf(typ, param string) any {
+	switch typ {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(param, 64); err == nil {
+			return json.Number(param)
+		}
+	case "boolean":
+		if v, err := strconv.ParseBool(param); err == nil {
+			return v
+		}
+	}
+	return param
+}
+
+// numberOf parses a numeric parameter of a rule.
+func numberOf(param string) *float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(param), 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// lengthOf parses a length parameter of a rule.
+func lengthOf(param string) *int {
+	v, err := strconv.Atoi(strings.TrimSpace(param))
+	if err != nil {
+		return nil
+	}
+	return &v
+}
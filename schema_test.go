@@ -0,0 +1,101 @@
+package folio_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kelindar/folio"
+	"github.com/stretchr/testify/assert"
+)
+
+type Server struct {
+	folio.Meta `kind:"server" json:",inline"`
+	Name       string            `json:"name" is:"required,minlen(2),maxlen(20)" desc:"Name of the server"`
+	Region     string            `json:"region" is:"in(eu|us)"`
+	Cores      int               `json:"cores" is:"range(1|64)"`
+	Contact    string            `json:"contact" is:"email"`
+	Tags       []string          `json:"tags" is:"in(web|db),max(2)"`
+	Labels     map[string]string `json:"labels"`
+	Disk       struct {
+		Size int `json:"size" is:"min(10)"`
+	} `json:"disk"`
+	Owner  folio.URN `json:"owner" kind:"person" is:"required"`
+	Backup *Volume   `json:"backup,omitempty"`
+	Host   string    `json:"host" is:"!in(localhost|local),!email,lowercase"`
+}
+
+type Volume struct {
+	Size int `json:"size"`
+}
+
+func TestSchema(t *testing.T) {
+	registry := folio.NewRegistry()
+	typ, err := folio.Register[*Server](registry)
+	assert.NoError(t, err)
+
+	schema := typ.Schema()
+	assert.Equal(t, "object", schema.Type)
+	assert.Equal(t, []string{"name", "owner"}, schema.Required)
+	assert.Contains(t, schema.Properties, "id")
+	assert.Contains(t, schema.Properties, "namespace")
+
+	name := schema.Properties["name"]
+	assert.Equal(t, "Name of the server", name.Description)
+	assert.Equal(t, 2, *name.MinLength)
+	assert.Equal(t, 20, *name.MaxLength)
+
+	assert.Equal(t, []any{"eu", "us"}, schema.Properties["region"].Enum)
+	assert.Equal(t, 1.0, *schema.Properties["cores"].Minimum)
+	assert.Equal(t, 64.0, *schema.Properties["cores"].Maximum)
+	assert.Equal(t, "email", schema.Properties["contact"].Format)
+	assert.Equal(t, []any{"web", "db"}, schema.Properties["tags"].Items.Enum)
+	assert.Equal(t, 2, *schema.Properties["tags"].MaxItems)
+	assert.Nil(t, schema.Properties["tags"].Items.Maximum)
+	assert.Equal(t, "string", schema.Properties["labels"].AdditionalProperties.Type)
+	assert.Equal(t, 10.0, *schema.Properties["disk"].Properties["size"].Minimum)
+
+	_, err = json.Marshal(schema)
+	assert.NoError(t, err)
+}
+
+func TestJSONSchema(t *testing.T) {
+	registry := folio.NewRegistry()
+	typ, err := folio.Register[*Server](registry)
+	assert.NoError(t, err)
+
+	schema := typ.JSONSchema()
+	assert.Equal(t, "https://json-schema.org/draft/2020-12/schema", schema.Schema)
+	assert.Equal(t, folio.Kind("server"), schema.Kind)
+	assert.Equal(t, []string{"name", "owner"}, schema.Required)
+
+	// References to other objects
+	owner := schema.Properties["owner"]
+	assert.Equal(t, folio.Kind("person"), owner.Kind)
+	assert.Regexp(t, owner.Pattern, "urn:default:person:9m4e2mr0ui3e8a215n4g")
+	assert.NotRegexp(t, owner.Pattern, "urn:default:company:9m4e2mr0ui3e8a215n4g")
+	assert.NotRegexp(t, owner.Pattern, "")
+
+	// Pointers are nullable
+	backup := schema.Properties["backup"]
+	assert.Len(t, backup.AnyOf, 2)
+	assert.Equal(t, "object", backup.AnyOf[0].Type)
+	assert.Equal(t, "null", backup.AnyOf[1].Type)
+
+	// Negated rules
+	host := schema.Properties["host"]
+	assert.Equal(t, []any{"localhost", "local"}, host.Not.Enum)
+	assert.Equal(t, "email", host.AllOf[0].Not.Format)
+	assert.Equal(t, "^[^A-Z]*$", host.Pattern)
+}
+
+func TestSchema_Patterns(t *testing.T) {
+	registry := folio.NewRegistry()
+	typ, err := folio.Register[*folio.Namespace](registry)
+	assert.NoError(t, err)
+
+	// Both "lowercase" and "alphanum" must be kept
+	name := typ.Schema().Properties["name"]
+	assert.Equal(t, "^[^A-Z]*$", name.Pattern)
+	assert.Len(t, name.AllOf, 1)
+	assert.Equal(t, "^[a-zA-Z0-9]+$", name.AllOf[0].Pattern)
+}
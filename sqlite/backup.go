@@ -0,0 +1,274 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kelindar/folio"
+)
+
+// Backuper represents a storage which can be backed up while it is in use.
+type Backuper interface {
+	Backup(w io.Writer) error
+	Snapshot(path string) error
+}
+
+// Snapshot writes a consistent copy of the database into a new file, while it is in use. The
+// copy is written next to the destination first, so that a partial copy is never visible.
+func (s *rds) Snapshot(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("storage: unable to snapshot, %s already exists", path)
+	}
+
+	temp := path + ".tmp"
+	_ = os.Remove(temp)
+	if _, err := s.db.Exec(`VACUUM INTO ?`, temp); err != nil {
+		return fmt.Errorf("storage: unable to snapshot, %w", err)
+	}
+
+	if err := os.Rename(temp, path); err != nil {
+		_ = os.Remove(temp)
+		return fmt.Errorf("storage: unable to snapshot, %w", err)
+	}
+	return nil
+}
+
+// Backup writes a consistent copy of the database into the writer, while it is in use.
+func (s *rds) Backup(w io.Writer) error {
+	dir, err := os.MkdirTemp("", "folio-backup-")
+	if err != nil {
+		return fmt.Errorf("storage: unable to backup, %w", err)
+	}
+
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "backup.db")
+	if err := s.Snapshot(path); err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("storage: unable to backup, %w", err)
+	}
+
+	defer f.Close()
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("storage: unable to backup, %w", err)
+	}
+	return nil
+}
+
+// ---------------------------------- Schedule ----------------------------------
+
+// Retention represents the schedule of the backups and how many of them are kept.
+type Retention struct {
+	Dir   string        // Directory where the snapshots are written
+	Every time.Duration // Interval between two snapshots, hourly by default
+	Keep  int           // Number of snapshots to keep, 24 by default
+}
+
+// ScheduleBackups takes a snapshot of the database at every interval until the context is
+// cancelled, and deletes the oldest snapshots beyond the retention. Failed snapshots are
+// logged, so that a transient error does not stop the schedule.
+func ScheduleBackups(ctx context.Context, db folio.Storage, policy Retention) error {
+	backuper, ok := db.(Backuper)
+	switch {
+	case !ok:
+		return fmt.Errorf("storage: unable to schedule backups, %T does not support them", db)
+	case policy.Dir == "":
+		return fmt.Errorf("storage: unable to schedule backups, directory is required")
+	case policy.Every <= 0:
+		policy.Every = time.Hour
+	}
+
+	if policy.Keep <= 0 {
+		policy.Keep = 24
+	}
+
+	if err := os.MkdirAll(policy.Dir, 0o755); err != nil {
+		return fmt.Errorf("storage: unable to schedule backups, %w", err)
+	}
+
+	ticker := time.NewTicker(policy.Every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			path, err := snapshotInto(backuper, policy)
+			if err != nil {
+				slog.Error("unable to backup the database", "error", err)
+				continue
+			}
+
+			slog.Info("database backed up", "path", path)
+		}
+	}
+}
+
+// snapshotInto takes a snapshot into the directory and deletes the oldest ones.
+func snapshotInto(db Backuper, policy Retention) (string, error) {
+	path := filepath.Join(policy.Dir, "folio-"+time.Now().UTC().Format("20060102-150405.000000000")+".db")
+	if err := db.Snapshot(path); err != nil {
+		return "", err
+	}
+
+	entries, err := os.ReadDir(policy.Dir)
+	if err != nil {
+		return path, err
+	}
+
+	// The names contain the time of the snapshot, so they are sorted from the oldest
+	snapshots := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if name := entry.Name(); strings.HasPrefix(name, "folio-") && strings.HasSuffix(name, ".db") {
+			snapshots = append(snapshots, name)
+		}
+	}
+
+	slices.Sort(snapshots)
+	for len(snapshots) > policy.Keep {
+		if err := os.Remove(filepath.Join(policy.Dir, snapshots[0])); err != nil {
+			return path, err
+		}
+		snapshots = snapshots[1:]
+	}
+	return path, nil
+}
+
+// ---------------------------------- Restore ----------------------------------
+
+// RestoreSnapshot replaces the database file at the path with the snapshot, once its integrity
+// is checked. The database must be closed: in WAL mode, an open connection is detected through
+// its lock and the restore is refused, but in the rollback journal mode an idle connection holds
+// no lock and can not be detected. The live database and its write-ahead log are moved aside
+// until the snapshot is in place, so that the log is never replayed onto the restored file and
+// the database is put back if the swap fails.
+func RestoreSnapshot(snapshot, path string) error {
+	if err := checkIntegrity(snapshot); err != nil {
+		return err
+	}
+
+	if err := checkUnused(path); err != nil {
+		return err
+	}
+
+	// Copy the snapshot next to the database, so that the swap is a rename
+	temp := path + ".restore"
+	if err := copyFile(snapshot, temp); err != nil {
+		_ = os.Remove(temp)
+		return fmt.Errorf("storage: unable to restore, %w", err)
+	}
+
+	aside := path + ".old"
+	moved, err := moveFiles(path, aside)
+	if err != nil {
+		_ = os.Remove(temp)
+		return fmt.Errorf("storage: unable to restore, %w", err)
+	}
+
+	if err := os.Rename(temp, path); err != nil {
+		_ = os.Remove(temp)
+		_, _ = moveFiles(aside, path)
+		return fmt.Errorf("storage: unable to restore, %w", err)
+	}
+
+	for _, suffix := range moved {
+		_ = os.Remove(aside + suffix)
+	}
+	return nil
+}
+
+// checkUnused returns an error if the database at the path is used by another connection, by
+// acquiring an exclusive lock on it.
+func checkUnused(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?_pragma=locking_mode(exclusive)")
+	if err != nil {
+		return fmt.Errorf("storage: unable to restore, %w", err)
+	}
+
+	defer db.Close()
+	if _, err := db.Exec(`BEGIN EXCLUSIVE; COMMIT`); err != nil {
+		return fmt.Errorf("storage: unable to restore, the database is in use: %w", err)
+	}
+	return nil
+}
+
+// moveFiles renames the database file and its journal files, and returns the suffixes of the
+// files which were moved. If a rename fails, the files already moved are put back.
+func moveFiles(from, to string) ([]string, error) {
+	var moved []string
+	for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+		switch err := os.Rename(from+suffix, to+suffix); {
+		case err == nil:
+			moved = append(moved, suffix)
+		case !os.IsNotExist(err):
+			for _, suffix := range moved {
+				_ = os.Rename(to+suffix, from+suffix)
+			}
+			return nil, err
+		}
+	}
+	return moved, nil
+}
+
+// checkIntegrity opens the database file read-only and runs an integrity check.
+func checkIntegrity(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("storage: unable to restore, %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("storage: unable to restore, %w", err)
+	}
+
+	defer db.Close()
+	var result string
+	switch err := db.QueryRow(`PRAGMA integrity_check`).Scan(&result); {
+	case err != nil:
+		return fmt.Errorf("storage: unable to restore, integrity check failed: %w", err)
+	case result != "ok":
+		return fmt.Errorf("storage: unable to restore, integrity check failed: %s", result)
+	default:
+		return nil
+	}
+}
+
+// copyFile copies the file and flushes it to disk.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,113 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kelindar/folio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackup(t *testing.T) {
+	testStorage(func(db folio.Storage, _ folio.Registry) {
+		app, err := folio.Create[*App](db, func(a *App) error {
+			a.Name = "backup"
+			return nil
+		}, "my_project", "test")
+		assert.NoError(t, err)
+
+		// Snapshot into a new file
+		dir := t.TempDir()
+		path := filepath.Join(dir, "snapshot.db")
+		assert.NoError(t, db.(Backuper).Snapshot(path))
+		assert.Error(t, db.(Backuper).Snapshot(path))
+
+		// Backup into a writer
+		var buffer bytes.Buffer
+		assert.NoError(t, db.(Backuper).Backup(&buffer))
+		assert.True(t, bytes.HasPrefix(buffer.Bytes(), []byte("SQLite format 3\x00")))
+
+		// Restore over an existing database
+		target := filepath.Join(dir, "data.db")
+		other, err := Open("file:"+target, newRegistry())
+		assert.NoError(t, err)
+		assert.NoError(t, other.Close())
+		assert.NoError(t, os.WriteFile(target+"-wal", []byte("stale"), 0o644))
+
+		assert.NoError(t, RestoreSnapshot(path, target))
+		assert.NoFileExists(t, target+"-wal")
+		assert.NoFileExists(t, target+".old")
+		assert.NoFileExists(t, target+".old-wal")
+
+		restored, err := Open("file:"+target, newRegistry())
+		assert.NoError(t, err)
+		defer restored.Close()
+
+		found, err := folio.Fetch[*App](restored, app.URN())
+		assert.NoError(t, err)
+		assert.Equal(t, "backup", found.Name)
+	})
+}
+
+func TestRestoreSnapshot_Corrupted(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "data.db")
+	assert.NoError(t, os.WriteFile(target, []byte("original"), 0o644))
+
+	// A file which is not a database is rejected
+	corrupted := filepath.Join(dir, "corrupted.db")
+	assert.NoError(t, os.WriteFile(corrupted, bytes.Repeat([]byte("x"), 4096), 0o644))
+	assert.ErrorContains(t, RestoreSnapshot(corrupted, target), "integrity check failed")
+	assert.ErrorContains(t, RestoreSnapshot(filepath.Join(dir, "missing.db"), target), "unable to restore")
+
+	// The database is left untouched
+	data, err := os.ReadFile(target)
+	assert.NoError(t, err)
+	assert.Equal(t, "original", string(data))
+}
+
+func TestRestoreSnapshot_InUse(t *testing.T) {
+	dir := t.TempDir()
+	snapshot := filepath.Join(dir, "snapshot.db")
+	source, err := Open("file:"+snapshot, newRegistry())
+	assert.NoError(t, err)
+	assert.NoError(t, source.Close())
+
+	// An open database in WAL mode holds a lock which prevents the restore
+	target := filepath.Join(dir, "data.db")
+	db, err := Open("file:"+target+"?_pragma=journal_mode(WAL)", newRegistry())
+	assert.NoError(t, err)
+	assert.ErrorContains(t, RestoreSnapshot(snapshot, target), "in use")
+	assert.FileExists(t, target)
+	assert.NoFileExists(t, target+".restore")
+
+	// Once closed, the snapshot replaces it
+	assert.NoError(t, db.Close())
+	assert.NoError(t, RestoreSnapshot(snapshot, target))
+	assert.NoFileExists(t, target+".old")
+}
+
+func TestScheduleBackups(t *testing.T) {
+	testStorage(func(db folio.Storage, _ folio.Registry) {
+		dir := t.TempDir()
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+
+		assert.NoError(t, ScheduleBackups(ctx, db, Retention{
+			Dir:   dir,
+			Every: 20 * time.Millisecond,
+			Keep:  2,
+		}))
+
+		entries, err := os.ReadDir(dir)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+
+		// Only the sqlite storage supports backups
+		assert.Error(t, ScheduleBackups(ctx, struct{ folio.Storage }{db}, Retention{Dir: dir}))
+	})
+}
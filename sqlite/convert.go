@@ -19,7 +19,8 @@ func withMeta(v Record, createdBy, updatedBy string, createdAt, updatedAt time.T
 	return v
 }
 
-func read(scan func(...any) error, r folio.Registry) (Record, error) {
+// read reads a record from the row, decrypting its secret fields.
+func (s *rds) read(scan func(...any) error) (Record, error) {
 	var record struct {
 		ID        string
 		Namespace string
@@ -38,11 +39,15 @@ func read(scan func(...any) error, r folio.Registry) (Record, error) {
 		return nil, err
 	}
 
-	obj, err := folio.FromJSON(r, record.Data)
+	obj, err := folio.FromJSON(s.registry, record.Data)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := s.decrypt(obj); err != nil {
+		return nil, err
+	}
+
 	return withMeta(obj,
 		record.CreatedBy,
 		record.UpdatedBy,
@@ -39,3 +39,10 @@ func TestFilterJSON(t *testing.T) {
 		assert.Equal(t, out, queryFilterByJSON(in, []string{"x"}))
 	}
 }
+
+func TestFilterJSON_Quotes(t *testing.T) {
+	assert.Equal(t,
+		`(json_extract(data, '$.name') IN ('o''brien','x'') OR 1=1 --'))`,
+		queryFilterByJSON("name", []string{"o'brien", "x') OR 1=1 --"}),
+	)
+}
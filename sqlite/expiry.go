@@ -0,0 +1,129 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/kelindar/folio"
+)
+
+// notExpired is the condition which hides the expired records, given the current time.
+const notExpired = `(COALESCE(expires_at, 0) = 0 OR expires_at > ?)`
+
+// Sweeper represents a storage which purges its expired objects.
+type Sweeper interface {
+	Sweep() ([]Record, error)
+}
+
+// WithSweeper purges the expired objects at every interval in the background, until the storage
+// is closed. The deleted function, if specified, is called with every object which was purged,
+// including the expired objects which are replaced by an insert before being swept.
+func WithSweeper(every time.Duration, deleted func(Record)) Option {
+	return func(o *options) {
+		o.sweepEvery = every
+		o.sweepDeleted = deleted
+	}
+}
+
+// expiryOf returns the expiry of the record updated at the specified time, in unix nanoseconds,
+// or zero if it never expires.
+func (s *rds) expiryOf(v Record, updatedAt time.Time) int64 {
+	typ, err := s.registry.Resolve(v.URN().Kind)
+	if err != nil {
+		return 0
+	}
+
+	expires := folio.ExpiryOf(typ, v, updatedAt)
+	if expires.IsZero() {
+		return 0
+	}
+	return expires.UnixNano()
+}
+
+// Sweep deletes the expired objects of every kind and returns them.
+func (s *rds) Sweep() ([]Record, error) {
+	now := time.Now().UnixNano()
+
+	var deleted []Record
+	var errs []error
+	for typ := range s.registry.Types() {
+		rows, err := s.db.Query(`DELETE FROM `+tableOf(typ.Kind)+
+			` WHERE COALESCE(expires_at, 0) > 0 AND expires_at <= ?`+
+			` RETURNING data, created_by, updated_by, created_at, updated_at`, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("storage: unable to sweep %s, %w", typ.Kind, err))
+			continue
+		}
+
+		deleted, errs = s.collect(rows, deleted, errs)
+	}
+
+	return deleted, errors.Join(errs...)
+}
+
+// purge deletes the record with the URN if it has expired at the specified time, and reports it
+// to the deleted function of the sweeper.
+func (s *rds) purge(urn folio.URN, now time.Time) error {
+	rows, err := s.db.Query(`DELETE FROM `+tableOf(urn.Kind)+` WHERE id = ? AND NOT `+notExpired+
+		` RETURNING data, created_by, updated_by, created_at, updated_at`, urn.ID, now.UnixNano())
+	if err != nil {
+		return err
+	}
+
+	deleted, errs := s.collect(rows, nil, nil)
+	s.notify(deleted)
+	return errors.Join(errs...)
+}
+
+// notify calls the deleted function of the sweeper with every purged record, if specified.
+func (s *rds) notify(deleted []Record) {
+	if s.deleted == nil {
+		return
+	}
+
+	for _, obj := range deleted {
+		s.deleted(obj)
+	}
+}
+
+// collect reads all of the deleted records, since the rows must be consumed for the
+// deletion to complete.
+func (s *rds) collect(rows *sql.Rows, deleted []Record, errs []error) ([]Record, []error) {
+	defer rows.Close()
+	for rows.Next() {
+		obj, err := s.read(rows.Scan)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("storage: unable to read, %w", err))
+			continue
+		}
+		deleted = append(deleted, obj)
+	}
+
+	if err := rows.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return deleted, errs
+}
+
+// sweep purges the expired objects at every interval, until the storage is closed.
+func (s *rds) sweep(every time.Duration) {
+	defer close(s.swept)
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.closing:
+			return
+		case <-ticker.C:
+			deleted, err := s.Sweep()
+			if err != nil {
+				slog.Error("unable to sweep the expired objects", "error", err)
+			}
+
+			s.notify(deleted)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kelindar/folio"
+	"github.com/stretchr/testify/assert"
+)
+
+type Lease struct {
+	folio.Meta `kind:"lease" json:",inline"`
+	Holder     string    `json:"holder"`
+	Until      time.Time `json:"until"`
+}
+
+func (l *Lease) ExpiresAt() time.Time {
+	return l.Until
+}
+
+type Ticket struct {
+	folio.Meta `kind:"ticket" json:",inline"`
+	Name       string `json:"name"`
+}
+
+func TestExpiry(t *testing.T) {
+	registry := newRegistry()
+	folio.Register[*Lease](registry)
+	folio.Register[*Ticket](registry, folio.Options{TTL: 50 * time.Millisecond})
+	db := OpenEphemeral(registry)
+	defer db.Close()
+
+	// Objects expire at the time they specify
+	expired, err := folio.Create[*Lease](db, func(l *Lease) error {
+		l.Holder = "alice"
+		l.Until = time.Now().Add(-time.Minute)
+		return nil
+	}, "my_project", "test")
+	assert.NoError(t, err)
+
+	active, err := folio.Create[*Lease](db, func(l *Lease) error {
+		l.Holder = "bob"
+		l.Until = time.Now().Add(time.Hour)
+		return nil
+	}, "my_project", "test")
+	assert.NoError(t, err)
+
+	_, err = folio.Fetch[*Lease](db, expired.URN())
+	assert.True(t, folio.IsNotFound(err))
+
+	found, err := folio.Fetch[*Lease](db, active.URN())
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", found.Holder)
+
+	count, err := db.Count("lease", folio.Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	leases, err := folio.Search[*Lease](db, folio.Query{})
+	assert.NoError(t, err)
+	for lease := range leases {
+		assert.Equal(t, "bob", lease.Holder)
+	}
+
+	// Or after the TTL of their kind, since their last update
+	ticket, err := folio.Create[*Ticket](db, func(t *Ticket) error {
+		t.Name = "first"
+		return nil
+	}, "my_project", "test")
+	assert.NoError(t, err)
+
+	_, err = folio.Fetch[*Ticket](db, ticket.URN())
+	assert.NoError(t, err)
+
+	time.Sleep(60 * time.Millisecond)
+	_, err = folio.Fetch[*Ticket](db, ticket.URN())
+	assert.True(t, folio.IsNotFound(err))
+
+	// An expired object which was not swept yet can be replaced
+	ticket.Name = "second"
+	_, err = db.Upsert(ticket, "test")
+	assert.NoError(t, err)
+
+	renewed, err := folio.Fetch[*Ticket](db, ticket.URN())
+	assert.NoError(t, err)
+	assert.Equal(t, "second", renewed.Name)
+
+	// Sweeping purges only the expired objects
+	deleted, err := db.(Sweeper).Sweep()
+	assert.NoError(t, err)
+	assert.Len(t, deleted, 1)
+	assert.Equal(t, expired.URN(), deleted[0].URN())
+
+	var rows int
+	assert.NoError(t, db.(*rds).db.QueryRow(`SELECT COUNT(*) FROM lease`).Scan(&rows))
+	assert.Equal(t, 1, rows)
+}
+
+func TestWithSweeper(t *testing.T) {
+	registry := newRegistry()
+	folio.Register[*Lease](registry)
+
+	deleted := make(chan Record, 1)
+	db, err := Open(":memory:", registry, WithSweeper(10*time.Millisecond, func(v Record) {
+		deleted <- v
+	}))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	lease, err := folio.Create[*Lease](db, func(l *Lease) error {
+		l.Holder = "alice"
+		l.Until = time.Now().Add(20 * time.Millisecond)
+		return nil
+	}, "my_project", "test")
+	assert.NoError(t, err)
+
+	select {
+	case v := <-deleted:
+		assert.Equal(t, lease.URN(), v.URN())
+		assert.Equal(t, "alice", v.(*Lease).Holder)
+	case <-time.After(time.Second):
+		assert.Fail(t, "expected the lease to be swept")
+	}
+}
+
+func TestExpiry_Replaced(t *testing.T) {
+	registry := newRegistry()
+	folio.Register[*Lease](registry)
+
+	var deleted []Record
+	db, err := Open(":memory:", registry, WithSweeper(time.Hour, func(v Record) {
+		deleted = append(deleted, v)
+	}))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	lease, err := folio.Create[*Lease](db, func(l *Lease) error {
+		l.Holder = "alice"
+		l.Until = time.Now().Add(-time.Minute)
+		return nil
+	}, "my_project", "test")
+	assert.NoError(t, err)
+
+	// Replacing an expired object which was not swept yet reports it as deleted
+	lease.Holder = "bob"
+	lease.Until = time.Now().Add(time.Hour)
+	_, err = db.Upsert(lease, "test")
+	assert.NoError(t, err)
+
+	assert.Len(t, deleted, 1)
+	assert.Equal(t, lease.URN(), deleted[0].URN())
+	assert.Equal(t, "alice", deleted[0].(*Lease).Holder)
+}
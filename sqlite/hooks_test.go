@@ -0,0 +1,155 @@
+package sqlite
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kelindar/folio"
+	"github.com/stretchr/testify/assert"
+)
+
+type Release struct {
+	folio.Meta `kind:"release" json:",inline"`
+	Name       string `json:"name"`
+	Slug       string `json:"slug"`
+	Version    int    `json:"version"`
+	Locked     bool   `json:"locked"`
+	saved      int
+}
+
+func (r *Release) BeforeInsert(ctx folio.HookContext) error {
+	if ctx.By == "" {
+		return errors.New("author is required")
+	}
+
+	r.Name = strings.TrimSpace(r.Name)
+	r.Slug = strings.ToLower(strings.ReplaceAll(r.Name, " ", "-"))
+	return nil
+}
+
+func (r *Release) BeforeUpdate(ctx folio.HookContext, old folio.Object) error {
+	if r.Version < old.(*Release).Version {
+		return errors.New("version cannot be lowered")
+	}
+
+	r.Slug = strings.ToLower(strings.ReplaceAll(r.Name, " ", "-"))
+	return nil
+}
+
+func (r *Release) AfterSave() {
+	r.saved++
+}
+
+func (r *Release) BeforeDelete() error {
+	if r.Locked {
+		return errors.New("release is locked")
+	}
+	return nil
+}
+
+func TestHooks(t *testing.T) {
+	registry := newRegistry()
+	folio.Register[*Release](registry)
+	db := OpenEphemeral(registry)
+	defer db.Close()
+
+	// Derived fields are filled before the insert
+	release, err := folio.Create[*Release](db, func(r *Release) error {
+		r.Name = "  Spring Release "
+		r.Version = 2
+		return nil
+	}, "my_project", "test")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, release.saved)
+
+	found, err := folio.Fetch[*Release](db, release.URN())
+	assert.NoError(t, err)
+	assert.Equal(t, "Spring Release", found.Name)
+	assert.Equal(t, "spring-release", found.Slug)
+
+	// And an error aborts the insert
+	_, err = folio.Create[*Release](db, func(r *Release) error { return nil }, "my_project", "")
+	assert.True(t, folio.IsRejected(err))
+	assert.ErrorContains(t, err, "author is required")
+
+	// The update hook receives the current version
+	found.Version = 1
+	_, err = folio.Update(db, found, "test")
+	assert.True(t, folio.IsRejected(err))
+
+	found.Version = 3
+	found.Name = "Summer Release"
+	updated, err := folio.Update(db, found, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, "summer-release", updated.Slug)
+	assert.Equal(t, 1, updated.saved)
+
+	// A locked release cannot be deleted
+	updated.Locked = true
+	updated, err = folio.Update(db, updated, "test")
+	assert.NoError(t, err)
+
+	_, err = db.Delete(release.URN(), "test")
+	assert.True(t, folio.IsRejected(err))
+	assert.ErrorContains(t, err, "release is locked")
+
+	_, err = folio.Fetch[*Release](db, release.URN())
+	assert.NoError(t, err)
+}
+
+func TestHooks_Restore(t *testing.T) {
+	registry := newRegistry()
+	folio.Register[*Release](registry)
+	db := OpenEphemeral(registry)
+	defer db.Close()
+
+	release, err := folio.New[*Release]("my_project", func(r *Release) error {
+		r.Name = " Spring Release "
+		r.Version = 2
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// A restored object which does not exist runs the insert hook
+	_, err = db.(folio.Restorer).Restore(release)
+	assert.True(t, folio.IsRejected(err))
+
+	release.CreatedBy, release.UpdatedBy = "test", "test"
+	restored, err := db.(folio.Restorer).Restore(release)
+	assert.NoError(t, err)
+	assert.Equal(t, "spring-release", restored.(*Release).Slug)
+	assert.Equal(t, 1, release.saved)
+
+	// And one which exists runs the update hook
+	release.Version = 1
+	_, err = db.(folio.Restorer).Restore(release)
+	assert.True(t, folio.IsRejected(err))
+	assert.ErrorContains(t, err, "version cannot be lowered")
+
+	found, err := folio.Fetch[*Release](db, release.URN())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, found.Version)
+}
+
+func TestHooks_Sweep(t *testing.T) {
+	registry := newRegistry()
+	folio.Register[*Release](registry, folio.Options{TTL: 10 * time.Millisecond})
+	db := OpenEphemeral(registry)
+	defer db.Close()
+
+	release, err := folio.Create[*Release](db, func(r *Release) error {
+		r.Name = "Spring Release"
+		r.Locked = true
+		return nil
+	}, "my_project", "test")
+	assert.NoError(t, err)
+
+	// Expired objects are purged without their delete hook, which can not keep them
+	time.Sleep(20 * time.Millisecond)
+	deleted, err := db.(Sweeper).Sweep()
+	assert.NoError(t, err)
+	assert.Len(t, deleted, 1)
+	assert.Equal(t, release.URN(), deleted[0].URN())
+}
@@ -0,0 +1,246 @@
+package sqlite
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/kelindar/folio"
+)
+
+// sealed is the prefix of the encrypted values
+const sealed = "enc:v1:"
+
+// KeyProvider provides the 32-byte key used to encrypt the fields tagged with `secret:"true"`.
+type KeyProvider interface {
+	Key() ([]byte, error)
+}
+
+// KeyFunc adapts a function into a KeyProvider.
+type KeyFunc func() ([]byte, error)
+
+// Key returns the key.
+func (f KeyFunc) Key() ([]byte, error) {
+	return f()
+}
+
+// KeyFile returns a provider which reads the key from a local file, encoded as base64. The file
+// is created with a random key if it does not exist, readable only by its owner.
+func KeyFile(path string) KeyProvider {
+	return KeyFunc(func() ([]byte, error) {
+		data, err := os.ReadFile(path)
+		switch {
+		case os.IsNotExist(err):
+			key := make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return nil, err
+			}
+
+			encoded := base64.StdEncoding.EncodeToString(key)
+			if err := os.WriteFile(path, []byte(encoded+"\n"), 0o600); err != nil {
+				return nil, err
+			}
+			return key, nil
+		case err != nil:
+			return nil, err
+		}
+
+		return base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	})
+}
+
+// newCipher creates the cipher used to encrypt the secrets with the key of the provider.
+func newCipher(keys KeyProvider) (cipher.AEAD, error) {
+	key, err := keys.Key()
+	if err != nil {
+		return nil, fmt.Errorf("storage: unable to load the key, %w", err)
+	}
+
+	if len(key) != 32 {
+		return nil, fmt.Errorf("storage: invalid key, expected 32 bytes but got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// ---------------------------------- Secret Fields ----------------------------------
+
+// secretField represents a field tagged as secret, possibly within nested structs.
+type secretField struct {
+	index []int    // Index of the field, for reflection
+	path  []string // JSON path of the field
+}
+
+// cache of the secret fields, by type
+var secrets sync.Map
+
+// secretsOf returns the string fields of the type which are tagged as secret. The fields of
+// nested structs are included, but not the ones within lists or maps.
+func secretsOf(typ reflect.Type) []secretField {
+	if v, ok := secrets.Load(typ); ok {
+		return v.([]secretField)
+	}
+
+	out := walkSecrets(typ, nil, nil, nil)
+	secrets.Store(typ, out)
+	return out
+}
+
+// walkSecrets appends the secret fields of the struct type.
+func walkSecrets(typ reflect.Type, index []int, path []string, out []secretField) []secretField {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		fieldIndex := append(append([]int{}, index...), i)
+		switch {
+		case !field.IsExported() || name == "-":
+			continue
+		case field.Anonymous && field.Type.Kind() == reflect.Struct:
+			out = walkSecrets(field.Type, fieldIndex, path, out)
+		case field.Tag.Get("secret") == "true" && field.Type.Kind() == reflect.String:
+			if name == "" {
+				name = field.Name
+			}
+			out = append(out, secretField{
+				index: fieldIndex,
+				path:  append(append([]string{}, path...), name),
+			})
+		case field.Type.Kind() == reflect.Struct:
+			if name == "" {
+				name = field.Name
+			}
+			out = walkSecrets(field.Type, fieldIndex, append(append([]string{}, path...), name), out)
+		}
+	}
+	return out
+}
+
+// excludedOf returns the JSON paths of the secret fields of the kind, for the search index.
+func excludedOf(typ reflect.Type) []string {
+	var out []string
+	for _, field := range secretsOf(typ) {
+		out = append(out, "$."+strings.Join(field.path, "."))
+	}
+	return out
+}
+
+// ---------------------------------- Encoding ----------------------------------
+
+// encode encodes the record as JSON, encrypting its secret fields.
+func (s *rds) encode(v Record) ([]byte, error) {
+	data, err := folio.ToStorageJSON(v)
+	fields := secretsOf(reflect.TypeOf(v).Elem())
+	if err != nil || len(fields) == 0 {
+		return data, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc map[string]any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	for _, field := range fields {
+		parent := doc
+		for _, name := range field.path[:len(field.path)-1] {
+			if parent, _ = parent[name].(map[string]any); parent == nil {
+				break
+			}
+		}
+
+		name := field.path[len(field.path)-1]
+		value, _ := parent[name].(string)
+		if parent == nil || value == "" {
+			continue
+		}
+
+		if parent[name], err = s.seal(value, additionalOf(v, field)); err != nil {
+			return nil, fmt.Errorf("storage: unable to encrypt %s, %w", strings.Join(field.path, "."), err)
+		}
+	}
+
+	return json.Marshal(doc)
+}
+
+// decrypt decrypts the secret fields of the record in place. Values which are not encrypted
+// are rejected, unless the storage was opened WithPlaintextSecrets to migrate existing data.
+func (s *rds) decrypt(v Record) error {
+	rv := reflect.ValueOf(v).Elem()
+	for _, field := range secretsOf(rv.Type()) {
+		fv, err := rv.FieldByIndexErr(field.index)
+		switch {
+		case err != nil || fv.String() == "":
+			continue
+		case !strings.HasPrefix(fv.String(), sealed) && s.plaintext:
+			continue
+		case !strings.HasPrefix(fv.String(), sealed):
+			return fmt.Errorf("storage: unable to decrypt %s, value is not encrypted", strings.Join(field.path, "."))
+		}
+
+		plain, err := s.unseal(fv.String(), additionalOf(v, field))
+		if err != nil {
+			return fmt.Errorf("storage: unable to decrypt %s, %w", strings.Join(field.path, "."), err)
+		}
+		fv.SetString(plain)
+	}
+	return nil
+}
+
+// additionalOf returns the additional data authenticated with a secret, which binds it to its
+// record and field so that an encrypted value can not be copied into another one.
+func additionalOf(v Record, field secretField) []byte {
+	return []byte(v.URN().String() + "#" + strings.Join(field.path, "."))
+}
+
+// seal encrypts the value with a random nonce, authenticating the additional data.
+func (s *rds) seal(value string, additional []byte) (string, error) {
+	if s.cipher == nil {
+		return "", errors.New("no key provider")
+	}
+
+	nonce := make([]byte, s.cipher.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	out := s.cipher.Seal(nonce, nonce, []byte(value), additional)
+	return sealed + base64.StdEncoding.EncodeToString(out), nil
+}
+
+// unseal decrypts a value encrypted by seal with the same additional data.
+func (s *rds) unseal(value string, additional []byte) (string, error) {
+	if s.cipher == nil {
+		return "", errors.New("no key provider")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, sealed))
+	switch {
+	case err != nil:
+		return "", err
+	case len(data) < s.cipher.NonceSize():
+		return "", errors.New("invalid ciphertext")
+	}
+
+	nonce, ciphertext := data[:s.cipher.NonceSize()], data[s.cipher.NonceSize():]
+	plain, err := s.cipher.Open(nil, nonce, ciphertext, additional)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,173 @@
+package sqlite
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kelindar/folio"
+	"github.com/stretchr/testify/assert"
+)
+
+type Vault struct {
+	folio.Meta `kind:"vault" json:",inline"`
+	Name       string `json:"name"`
+	APIKey     string `json:"apiKey" secret:"true"`
+	Database   struct {
+		Password string `json:"password" secret:"true"`
+	} `json:"database"`
+}
+
+func TestSecret(t *testing.T) {
+	registry := newRegistry()
+	folio.Register[*Vault](registry)
+
+	keyfile := filepath.Join(t.TempDir(), "folio.key")
+	db, err := Open(":memory:", registry, WithKeys(KeyFile(keyfile)))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	vault, err := folio.Create[*Vault](db, func(v *Vault) error {
+		v.Name = "production"
+		v.APIKey = "topsecretkey"
+		v.Database.Password = "hunter2"
+		return nil
+	}, "my_project", "test")
+	assert.NoError(t, err)
+	assert.Equal(t, "topsecretkey", vault.APIKey)
+
+	// The values are encrypted in the data
+	var data string
+	assert.NoError(t, db.(*rds).db.QueryRow(`SELECT data FROM vault`).Scan(&data))
+	assert.NotContains(t, data, "topsecretkey")
+	assert.NotContains(t, data, "hunter2")
+	assert.Contains(t, data, `"apiKey":"enc:v1:`)
+
+	// And decrypted on read
+	found, err := folio.Fetch[*Vault](db, vault.URN())
+	assert.NoError(t, err)
+	assert.Equal(t, "topsecretkey", found.APIKey)
+	assert.Equal(t, "hunter2", found.Database.Password)
+
+	// The values are kept out of the search index
+	assert.Equal(t, 1, countOf(t, db, "production"))
+	assert.Equal(t, 0, countOf(t, db, "enc"))
+	assert.Equal(t, 0, countOf(t, db, "v1"))
+
+	// The key file is created once, readable only by its owner
+	info, err := os.Stat(keyfile)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	first, err := KeyFile(keyfile).Key()
+	assert.NoError(t, err)
+	second, err := KeyFile(keyfile).Key()
+	assert.NoError(t, err)
+	assert.Len(t, first, 32)
+	assert.Equal(t, first, second)
+}
+
+func TestSecret_NoKeys(t *testing.T) {
+	registry := newRegistry()
+	folio.Register[*Vault](registry)
+	db := OpenEphemeral(registry)
+	defer db.Close()
+
+	_, err := folio.Create[*Vault](db, func(v *Vault) error {
+		v.APIKey = "topsecretkey"
+		return nil
+	}, "my_project", "test")
+	assert.ErrorContains(t, err, "unable to encrypt apiKey")
+
+	// Empty secrets do not need to be encrypted
+	_, err = folio.Create[*Vault](db, func(v *Vault) error { return nil }, "my_project", "test")
+	assert.NoError(t, err)
+
+	// Invalid keys are rejected when opening
+	_, err = Open(":memory:", registry, WithKeys(KeyFunc(func() ([]byte, error) {
+		return []byte("short"), nil
+	})))
+	assert.ErrorContains(t, err, "invalid key")
+}
+
+func countOf(t *testing.T, db folio.Storage, match string) int {
+	found, err := db.Search("vault", folio.Query{Match: match})
+	assert.NoError(t, err)
+
+	count := 0
+	for range found {
+		count++
+	}
+	return count
+}
+
+func TestSecret_Bound(t *testing.T) {
+	registry := newRegistry()
+	folio.Register[*Vault](registry)
+	db, err := Open(":memory:", registry, WithKeys(KeyFile(filepath.Join(t.TempDir(), "folio.key"))))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	create := func(key string) *Vault {
+		vault, err := folio.Create[*Vault](db, func(v *Vault) error {
+			v.APIKey = key
+			v.Database.Password = key
+			return nil
+		}, "my_project", "test")
+		assert.NoError(t, err)
+		return vault
+	}
+
+	first, second := create("first"), create("second")
+	sql := db.(*rds).db
+
+	// An encrypted value copied into another field can not be decrypted
+	_, err = sql.Exec(`UPDATE vault SET data = json_set(data, '$.apiKey', json_extract(data, '$.database.password')) WHERE id = ?`, first.ID)
+	assert.NoError(t, err)
+	_, err = folio.Fetch[*Vault](db, first.URN())
+	assert.ErrorContains(t, err, "unable to decrypt apiKey")
+
+	// Nor into another record
+	_, err = sql.Exec(`UPDATE vault SET data = json_set(data, '$.apiKey', (SELECT json_extract(data, '$.apiKey') FROM vault WHERE id = ?)) WHERE id = ?`, first.ID, second.ID)
+	assert.NoError(t, err)
+	_, err = folio.Fetch[*Vault](db, second.URN())
+	assert.ErrorContains(t, err, "unable to decrypt apiKey")
+}
+
+func TestSecret_Plaintext(t *testing.T) {
+	registry := newRegistry()
+	folio.Register[*Vault](registry)
+	path := filepath.Join(t.TempDir(), "data.db")
+	keys := KeyFile(filepath.Join(t.TempDir(), "folio.key"))
+
+	// Secrets stored before the encryption was enabled
+	db, err := Open("file:"+path, registry, WithKeys(keys))
+	assert.NoError(t, err)
+	vault, err := folio.Create[*Vault](db, func(v *Vault) error {
+		v.Name = "legacy"
+		return nil
+	}, "my_project", "test")
+	assert.NoError(t, err)
+	_, err = db.(*rds).db.Exec(`UPDATE vault SET data = json_set(data, '$.apiKey', 'plain')`)
+	assert.NoError(t, err)
+
+	// They are rejected by default
+	_, err = folio.Fetch[*Vault](db, vault.URN())
+	assert.ErrorContains(t, err, "value is not encrypted")
+	assert.NoError(t, db.Close())
+
+	// And accepted while migrating, then encrypted on the next update
+	db, err = Open("file:"+path, registry, WithKeys(keys), WithPlaintextSecrets())
+	assert.NoError(t, err)
+	defer db.Close()
+
+	found, err := folio.Fetch[*Vault](db, vault.URN())
+	assert.NoError(t, err)
+	assert.Equal(t, "plain", found.APIKey)
+	_, err = db.Upsert(found, "test")
+	assert.NoError(t, err)
+
+	var data string
+	assert.NoError(t, db.(*rds).db.QueryRow(`SELECT data FROM vault`).Scan(&data))
+	assert.Contains(t, data, `"apiKey":"enc:v1:`)
+}
@@ -1,9 +1,11 @@
 package sqlite
 
 import (
+	"crypto/cipher"
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/kelindar/folio"
 	_ "github.com/ncruces/go-sqlite3/driver" // cgo-free, uses wazero
@@ -14,12 +16,57 @@ type Record = folio.Object
 
 // rds represents a relational storage layer for resources.
 type rds struct {
-	db       *sql.DB
-	registry folio.Registry
+	db        *sql.DB
+	registry  folio.Registry
+	cipher    cipher.AEAD   // Cipher of the secret fields, nil if no key provider is set
+	plaintext bool          // Whether secrets which are not encrypted are accepted
+	closing   chan struct{} // Closed when the storage is closing, to stop the sweeper
+	swept     chan struct{} // Closed when the sweeper has stopped, nil if there is none
+	deleted   func(Record)  // Called with every expired record which is purged, if specified
+}
+
+// Option represents an option of the storage.
+type Option func(*options)
+
+// options represents the options of the storage.
+type options struct {
+	keys         KeyProvider
+	plaintext    bool
+	sweepEvery   time.Duration
+	sweepDeleted func(Record)
+}
+
+// WithKeys sets the provider of the key used to encrypt the fields tagged as secret.
+func WithKeys(keys KeyProvider) Option {
+	return func(o *options) {
+		o.keys = keys
+	}
+}
+
+// WithPlaintextSecrets accepts the secret fields which are stored in plain text, so that the
+// existing data can be encrypted on its next update. It is only meant for the migration of a
+// database to encryption, since anyone able to write to the file could replace a secret.
+func WithPlaintextSecrets() Option {
+	return func(o *options) {
+		o.plaintext = true
+	}
 }
 
 // Open opens a storage database
-func Open(dsn string, registry folio.Registry) (folio.Storage, error) {
+func Open(dsn string, registry folio.Registry, opts ...Option) (folio.Storage, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var aead cipher.AEAD
+	if o.keys != nil {
+		var err error
+		if aead, err = newCipher(o.keys); err != nil {
+			return nil, err
+		}
+	}
+
 	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("storage: unable to open database: %w", err)
@@ -33,15 +80,27 @@ func Open(dsn string, registry folio.Registry) (folio.Storage, error) {
 		return nil, err
 	}
 
-	return &rds{
-		db:       db,
-		registry: registry,
-	}, nil
+	s := &rds{
+		db:        db,
+		registry:  registry,
+		cipher:    aead,
+		plaintext: o.plaintext,
+		deleted:   o.sweepDeleted,
+		closing:   make(chan struct{}),
+	}
+
+	// Purge the expired objects in the background
+	if o.sweepEvery > 0 {
+		s.swept = make(chan struct{})
+		go s.sweep(o.sweepEvery)
+	}
+
+	return s, nil
 }
 
 // OpenEphemeral opens an ephemeral storage
-func OpenEphemeral(registry folio.Registry) folio.Storage {
-	s, err := Open(":memory:", registry)
+func OpenEphemeral(registry folio.Registry, opts ...Option) folio.Storage {
+	s, err := Open(":memory:", registry, opts...)
 	if err != nil {
 		panic(err)
 	}
@@ -50,6 +109,11 @@ func OpenEphemeral(registry folio.Registry) folio.Storage {
 
 // Close closes the storage gracefully.
 func (s *rds) Close() error {
+	close(s.closing)
+	if s.swept != nil {
+		<-s.swept
+	}
+
 	return s.db.Close()
 }
 
@@ -75,8 +139,8 @@ func (s *rds) query(projection string, kind folio.Kind, q folio.Query) (*sql.Row
 	}
 
 	// Build the query
-	where := make([]string, 0, 4)
-	args := make([]any, 0, 4)
+	where := []string{notExpired}
+	args := []any{time.Now().UnixNano()}
 
 	// Filter by namespaces
 	if len(q.Namespace) > 0 {
@@ -170,7 +234,7 @@ func queryFilterByJSON(path string, values []string) string {
 	// Write the values as a SQL 'IN' list
 	for i, v := range values {
 		sb.WriteString("'")
-		sb.WriteString(v)
+		sb.WriteString(strings.ReplaceAll(v, "'", "''"))
 		sb.WriteString("'")
 		if i < len(values)-1 {
 			sb.WriteString(",")
@@ -192,3 +256,8 @@ func sanitizeTerm(query string) string {
 	nearClause := "NEAR(" + strings.Join(tokens, " ") + ", 30)"
 	return nearClause
 }
+
+// Stats returns the statistics of the underlying database.
+func (s *rds) Stats() sql.DBStats {
+	return s.db.Stats()
+}
@@ -25,7 +25,11 @@ func (s *rds) Upsert(v Record, updatedBy string) (Record, error) {
 
 // Insert inserts a new resource into the storage.
 func (s *rds) Insert(v Record, createdBy string) (Record, error) {
-	data, err := folio.ToJSON(v)
+	if err := folio.BeforeInsert(folio.HookContext{Storage: s, By: createdBy}, v); err != nil {
+		return nil, err
+	}
+
+	data, err := s.encode(v)
 	if err != nil {
 		return nil, err
 	}
@@ -33,9 +37,16 @@ func (s *rds) Insert(v Record, createdBy string) (Record, error) {
 	// Prepare the statement
 	urn := v.URN()
 	now := time.Now()
+	withMeta(v, createdBy, createdBy, now, now)
 	sql := `INSERT INTO ` + tableOf(urn.Kind) +
-		` (id, namespace, state, indexed_by, data, created_by, updated_by, created_at, updated_at)` +
-		` VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		` (id, namespace, state, indexed_by, data, created_by, updated_by, created_at, updated_at, expires_at)` +
+		` VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	// An expired record which was not yet swept does not prevent the insert, and is reported
+	// as deleted like the ones purged by the sweeper
+	if err := s.purge(urn, now); err != nil {
+		return nil, fmt.Errorf("storage: unable to insert, %w", err)
+	}
 
 	// Insert the record
 	if _, err := s.db.Exec(sql,
@@ -48,47 +59,114 @@ func (s *rds) Insert(v Record, createdBy string) (Record, error) {
 		createdBy, // same as created_by
 		now.UnixNano(),
 		now.UnixNano(), // same as created_at
+		s.expiryOf(v, now),
 	); err != nil {
 		return nil, fmt.Errorf("storage: unable to insert, %w", err)
 	}
 
-	return withMeta(v, createdBy, createdBy, now, now), nil
+	folio.AfterSave(v)
+	return v, nil
 }
 
 // Update updates an existing resource in the storage.
 func (s *rds) Update(v Record, updatedBy string) (Record, error) {
-	data, err := folio.ToJSON(v)
+	urn := v.URN()
+	if err := folio.BeforeUpdate(folio.HookContext{Storage: s, By: updatedBy}, v, func() (Record, error) {
+		return s.Fetch(urn)
+	}); err != nil {
+		return nil, err
+	}
+
+	data, err := s.encode(v)
 	if err != nil {
 		return nil, err
 	}
 
 	_, version := v.Updated()
-	urn := v.URN()
 	now := time.Now()
 	sql := `UPDATE ` + tableOf(urn.Kind) +
-		` SET state = ?, indexed_by = ?, data = ?, updated_by = ?, updated_at = ?` +
-		` WHERE id = ? AND updated_at = ?`
+		` SET state = ?, indexed_by = ?, data = ?, updated_by = ?, updated_at = ?, expires_at = ?` +
+		` WHERE id = ? AND updated_at = ? AND ` + notExpired
 
 	// Update the record
-	r, err := s.db.Exec(sql, v.Status(), indexOf(v), data, updatedBy, now.UnixNano(), urn.ID, version.UnixNano())
+	r, err := s.db.Exec(sql, v.Status(), indexOf(v), data, updatedBy, now.UnixNano(), s.expiryOf(v, now),
+		urn.ID, version.UnixNano(), now.UnixNano())
 	n, _ := r.RowsAffected()
 	switch {
 	case err != nil:
 		return nil, fmt.Errorf("storage: unable to update, %w", err)
 	case n == 0:
 		return nil, fmt.Errorf("%w (%v)", folio.ErrConflict, urn.String())
-	default:
-		return s.Fetch(urn)
 	}
+
+	updated, err := s.Fetch(urn)
+	if err != nil {
+		return nil, err
+	}
+
+	folio.AfterSave(updated)
+	return updated, nil
+}
+
+// Restore writes the resource as-is, keeping its metadata, whether it already exists or not. The
+// hooks of an insert or an update are run, depending on whether the resource exists.
+func (s *rds) Restore(v Record) (Record, error) {
+	urn := v.URN()
+	createdBy, createdAt := v.Created()
+	updatedBy, updatedAt := v.Updated()
+
+	// Run the same hooks as an insert or an update, depending on whether the record exists
+	current, err := s.Fetch(urn)
+	switch {
+	case folio.IsNotFound(err):
+		err = folio.BeforeInsert(folio.HookContext{Storage: s, By: createdBy}, v)
+	case err == nil:
+		err = folio.BeforeUpdate(folio.HookContext{Storage: s, By: updatedBy}, v, func() (Record, error) { return current, nil })
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := s.encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	sql := `INSERT INTO ` + tableOf(urn.Kind) +
+		` (id, namespace, state, indexed_by, data, created_by, updated_by, created_at, updated_at, expires_at)` +
+		` VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)` +
+		` ON CONFLICT(id) DO UPDATE SET namespace = excluded.namespace, state = excluded.state,` +
+		` indexed_by = excluded.indexed_by, data = excluded.data,` +
+		` created_by = excluded.created_by, updated_by = excluded.updated_by,` +
+		` created_at = excluded.created_at, updated_at = excluded.updated_at, expires_at = excluded.expires_at`
+
+	if _, err := s.db.Exec(sql,
+		urn.ID,
+		urn.Namespace,
+		v.Status(),
+		indexOf(v),
+		data,
+		createdBy,
+		updatedBy,
+		createdAt.UnixNano(),
+		updatedAt.UnixNano(),
+		s.expiryOf(v, updatedAt),
+	); err != nil {
+		return nil, fmt.Errorf("storage: unable to restore, %w", err)
+	}
+
+	// Return the record as written, since it may have already expired
+	folio.AfterSave(v)
+	return v, nil
 }
 
 // Fetch retrieves a resource by URN.
 func (s *rds) Fetch(urn folio.URN) (Record, error) {
 	selectSQL := `SELECT  data, created_by, updated_by, created_at, updated_at` +
-		` FROM ` + tableOf(urn.Kind) + ` WHERE id = ?`
+		` FROM ` + tableOf(urn.Kind) + ` WHERE id = ? AND ` + notExpired
 
-	row := s.db.QueryRow(selectSQL, urn.ID)
-	obj, err := read(row.Scan, s.registry)
+	row := s.db.QueryRow(selectSQL, urn.ID, time.Now().UnixNano())
+	obj, err := s.read(row.Scan)
 	switch {
 	case errors.Is(err, sql.ErrNoRows):
 		return nil, fmt.Errorf("%w (%v)", folio.ErrNotFound, urn.String())
@@ -106,6 +184,10 @@ func (s *rds) Delete(urn folio.URN, deletedBy string) (Record, error) {
 		return nil, err
 	}
 
+	if err := folio.BeforeDelete(deleted); err != nil {
+		return nil, err
+	}
+
 	if _, err := s.db.Exec(`DELETE FROM `+tableOf(urn.Kind)+` WHERE id = ?`, urn.ID); err != nil {
 		return nil, fmt.Errorf("failed to delete record: %w", err)
 	}
@@ -125,7 +207,7 @@ func (s *rds) Search(kind folio.Kind, q folio.Query) (iter.Seq[Record], error) {
 	return func(yield func(Record) bool) {
 		defer rows.Close()
 		for rows.Next() {
-			obj, err := read(rows.Scan, s.registry)
+			obj, err := s.read(rows.Scan)
 			if err != nil {
 				innerErr = fmt.Errorf("storage: unable to read, %w", err)
 				return
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/kelindar/folio"
 )
@@ -12,7 +13,7 @@ func autoMigrate(db *sql.DB, registry folio.Registry) error {
 	for t := range registry.Types() {
 		if err := errors.Join(
 			createTable(db, tableOf(t.Kind)),
-			createSearchIndex(db, tableOf(t.Kind)),
+			createSearchIndex(db, tableOf(t.Kind), excludedOf(t.Type)),
 		); err != nil {
 			return err
 		}
@@ -22,14 +23,33 @@ func autoMigrate(db *sql.DB, registry folio.Registry) error {
 
 func createTable(db *sql.DB, table string) error {
 	return errors.Join(
-		execf(db, `CREATE TABLE IF NOT EXISTS %s ( id TEXT PRIMARY KEY, namespace TEXT, state TEXT, data JSON, indexed_by TEXT, created_by TEXT, updated_by TEXT, created_at INTEGER, updated_at INTEGER)`, table),
+		execf(db, `CREATE TABLE IF NOT EXISTS %s ( id TEXT PRIMARY KEY, namespace TEXT, state TEXT, data JSON, indexed_by TEXT, created_by TEXT, updated_by TEXT, created_at INTEGER, updated_at INTEGER, expires_at INTEGER)`, table),
+		addColumn(db, table, "expires_at", "INTEGER"),
 		execf(db, `CREATE INDEX IF NOT EXISTS %s_idx_namespace ON %s(namespace)`, table, table),
 		execf(db, `CREATE INDEX IF NOT EXISTS %s_idx_state ON %s(state)`, table, table),
 		execf(db, `CREATE INDEX IF NOT EXISTS %s_idx_index ON %s(indexed_by)`, table, table),
+		execf(db, `CREATE INDEX IF NOT EXISTS %s_idx_expires ON %s(expires_at)`, table, table),
 	)
 }
 
-func createSearchIndex(db *sql.DB, table string) error {
+// addColumn adds the column to a table created by an earlier version, if it is missing.
+func addColumn(db *sql.DB, table, column, typ string) error {
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&count); err != nil || count > 0 {
+		return err
+	}
+
+	return execf(db, `ALTER TABLE %s ADD COLUMN %s %s`, table, column, typ)
+}
+
+// createSearchIndex creates the full-text index of the table, which is kept up to date with
+// triggers. The excluded JSON paths, such as the secret fields, are removed from the index.
+func createSearchIndex(db *sql.DB, table string, excluded []string) error {
+	data := "new.data"
+	if len(excluded) > 0 {
+		data = fmt.Sprintf("json_remove(new.data, '%s')", strings.Join(excluded, "', '"))
+	}
+
 	return errors.Join(
 		execf(db, `CREATE VIRTUAL TABLE IF NOT EXISTS %s_fts USING fts5(id, data)`,
 			table),
@@ -37,10 +57,12 @@ func createSearchIndex(db *sql.DB, table string) error {
 			table, table, table),
 		execf(db, `CREATE TRIGGER IF NOT EXISTS %s_fts_before_delete BEFORE DELETE ON %s BEGIN DELETE FROM %s_fts WHERE rowid = old.rowid; END`,
 			table, table, table),
-		execf(db, `CREATE TRIGGER IF NOT EXISTS %s_after_update AFTER UPDATE ON %s BEGIN INSERT INTO %s_fts(rowid, id, data) VALUES (new.rowid, new.id, new.data); END`,
-			table, table, table),
-		execf(db, `CREATE TRIGGER IF NOT EXISTS %s_after_insert AFTER INSERT ON %s BEGIN INSERT INTO %s_fts(rowid, id, data) VALUES (new.rowid, new.id, new.data); END`,
-			table, table, table),
+		execf(db, `DROP TRIGGER IF EXISTS %s_after_update`, table),
+		execf(db, `CREATE TRIGGER %s_after_update AFTER UPDATE ON %s BEGIN INSERT INTO %s_fts(rowid, id, data) VALUES (new.rowid, new.id, %s); END`,
+			table, table, table, data),
+		execf(db, `DROP TRIGGER IF EXISTS %s_after_insert`, table),
+		execf(db, `CREATE TRIGGER %s_after_insert AFTER INSERT ON %s BEGIN INSERT INTO %s_fts(rowid, id, data) VALUES (new.rowid, new.id, %s); END`,
+			table, table, table, data),
 	)
 }
 
@@ -9,6 +9,7 @@ import (
 	"reflect"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/kelindar/folio/internal/convert"
 )
@@ -129,10 +130,11 @@ func walkEmbeds(r Registry, value any, fn func(reflect.Value)) error {
 
 // Options represents the options for a document
 type Options struct {
-	Icon   string `json:"icon,omitempty"`   // Icon name from https://lucide.dev/icons
-	Title  string `json:"title,omitempty"`  // Title of the document (e.g. Person)
-	Plural string `json:"plural,omitempty"` // Plural name of the document (e.g. People)
-	Sort   string `json:"sort,omitempty"`   // Sort field
+	Icon   string        `json:"icon,omitempty"`   // Icon name from https://lucide.dev/icons
+	Title  string        `json:"title,omitempty"`  // Title of the document (e.g. Person)
+	Plural string        `json:"plural,omitempty"` // Plural name of the document (e.g. People)
+	Sort   string        `json:"sort,omitempty"`   // Sort field
+	TTL    time.Duration `json:"ttl,omitempty"`    // Time to live since the last update, never expires if zero
 }
 
 // defaultOptions returns the default options for the specified kind
@@ -145,6 +147,24 @@ func defaultOptions(kind Kind) Options {
 	}
 }
 
+// Expirer represents an object which expires at a point in time, such as a temporary token.
+type Expirer interface {
+	ExpiresAt() time.Time
+}
+
+// ExpiryOf returns the time at which the object expires when updated at the specified time, or
+// zero if it never expires. The ExpiresAt method of the object takes precedence over the TTL.
+func ExpiryOf(typ Type, obj Object, updatedAt time.Time) time.Time {
+	if v, ok := obj.(Expirer); ok {
+		return v.ExpiresAt()
+	}
+
+	if typ.TTL > 0 {
+		return updatedAt.Add(typ.TTL)
+	}
+	return time.Time{}
+}
+
 // ---------------------------------- Query Parsing ----------------------------------
 
 // Query represents a query to filter records.
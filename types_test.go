@@ -2,6 +2,7 @@ package folio
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -142,3 +143,31 @@ func TestEncodeQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestExpiryOf(t *testing.T) {
+	now := time.Now()
+	assert.True(t, ExpiryOf(Type{}, &Kind1{}, now).IsZero())
+	assert.Equal(t, now.Add(time.Hour), ExpiryOf(Type{
+		Options: Options{TTL: time.Hour},
+	}, &Kind1{}, now))
+
+	// The object takes precedence over the TTL of its kind
+	assert.Equal(t, now.AddDate(0, 0, 1), ExpiryOf(Type{
+		Options: Options{TTL: time.Hour},
+	}, &MockLease{Until: now.AddDate(0, 0, 1)}, now))
+
+	// Tokens are not purged once expired
+	token := &Token{Days: 1}
+	token.CreatedAt = now.UnixNano()
+	assert.True(t, ExpiryOf(Type{}, token, now).IsZero())
+}
+
+// MockLease is a sample object which expires at a point in time.
+type MockLease struct {
+	Meta  `kind:"lease" json:",inline"`
+	Until time.Time
+}
+
+func (l *MockLease) ExpiresAt() time.Time {
+	return l.Until
+}
@@ -11,8 +11,6 @@ import (
 	"strings"
 	"sync"
 	"unicode"
-
-	"github.com/kelindar/folio/internal/walk"
 )
 
 // Validation function that replaces the original Struct function
@@ -31,106 +29,13 @@ func Struct(s interface{}) (bool, error) {
 		return false, fmt.Errorf("function only accepts pointer to struct; got %s", val.Kind())
 	}
 
-	var errs Errors
-
-	err := walk.Walk(s, func(v reflect.Value, field *reflect.StructField, path []string) error {
-		// Skip if field is nil (e.g., map or slice elements)
-		if field == nil {
-			return nil
-		}
-
-		// Get the validation tag from the field
-		tag := field.Tag.Get(rsTagName)
-		if tag == "-" {
-			return nil
-		}
-
-		// Parse options from the tag
-		options := parseOpts(tag)
-
-		// Check for empty values and required fields
-		if walk.IsEmpty(v) {
-			isValid, err := checkRequired(field, options, path)
-			if !isValid {
-				if name := nameOf(field); name != "" {
-					err = withName(err, name)
-				}
-				errs = append(errs, err)
-			}
-			return nil
-		}
-
-		delete(options, "required")
-
-		// Perform validation based on options
-		isValid, err := typeCheck(v, field, options, path)
-		if !isValid && err != nil {
-			if name := nameOf(field); name != "" {
-				err = withName(err, name)
-			}
-			errs = append(errs, err)
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		return false, err
-	}
-
-	if len(errs) > 0 {
+	if errs := planOf(val.Type()).validate(val, nil, nil); len(errs) > 0 {
 		return false, errs
 	}
 
 	return true, nil
 }
 
-// typeCheck performs validation on a single field value
-func typeCheck(v reflect.Value, field *reflect.StructField, options opts, path []string) (bool, error) {
-	optionsOrder := options.orderedKeys()
-	for _, validator := range optionsOrder {
-
-		// Parse the validator and parameters
-		matches := rxValidator.FindStringSubmatch(validator)
-		if len(matches) == 0 {
-			err := errorf(field, path, validator, "validator is invalid or can't be applied to the field: %q", validator)
-			return false, err
-		}
-
-		// Find an appropriate validator by its name
-		if vd, ok := lookup(matches[1]); ok {
-			delete(options, validator)
-
-			// Perform the validation
-			params := strings.Split(matches[2], "|")
-			if v := fmt.Sprint(v.Interface()); !vd.Validate(v, params...) {
-				args := make([]any, 0, len(params)+1)
-				args = append(args, nameOf(field))
-				for _, param := range params {
-					args = append(args, param)
-				}
-
-				return false, errorf(field, path, validator, vd.format, args...)
-			}
-		} else {
-			err := errorf(field, path, validator, "unknown validator %q for field %s", validator, field.Name)
-			return false, err
-		}
-	}
-
-	// Check for any unprocessed options
-	delete(options, "required")
-	if len(options) != 0 {
-		optionsOrder := options.orderedKeys()
-		for _, validator := range optionsOrder {
-			err := errorf(field, path, validator, "validator is invalid or can't be applied to the field: %q", validator)
-			return false, err
-		}
-	}
-
-	return true, nil
-}
-
 // parseOpts parses a struct tag `valid:required~Some error message,length(2|3)` into map[string]string{"required": "Some error message", "length(2|3)": ""}
 func parseOpts(tag string) opts {
 	opts := make(opts)
@@ -172,17 +77,6 @@ func isValidTag(s string) bool {
 	return true
 }
 
-// checkRequired checks whether a field is required or not
-func checkRequired(t *reflect.StructField, options opts, path []string) (bool, error) {
-	_, isRequired := options["required"]
-	switch {
-	case isRequired:
-		return false, errorf(t, path, "required", "%s is a required field", nameOf(t))
-	default:
-		return true, nil
-	}
-}
-
 // nameOf returns the JSON name of a field
 func nameOf(field *reflect.StructField) string {
 	if field == nil {
@@ -213,14 +107,15 @@ func nameOf(field *reflect.StructField) string {
 var validators sync.Map
 
 // variadic returns a variadic function by currying the given function
-func variadic(fn func(string) bool) func(str string, _ ...string) bool {
-	return func(str string, _ ...string) bool {
+func variadic(fn func(string) bool) Func {
+	return String(func(str string, _ ...string) bool {
 		return fn(str)
-	}
+	})
 }
 
 // Register registers a new validator function with additional parameters
 func Register(name, format string, fn Func) {
+	defer plans.Clear()
 	validators.Store(name, &validator{
 		name:   name,
 		format: format,
@@ -232,7 +127,7 @@ func Register(name, format string, fn Func) {
 	validators.Store(negated, &validator{
 		name:   negated,
 		format: strings.ReplaceAll(format, "must ", "must not "),
-		fn:     func(str string, params ...string) bool { return !fn(str, params...) },
+		fn:     func(v reflect.Value, params ...string) bool { return !fn(v, params...) },
 	})
 }
 
@@ -242,8 +137,8 @@ type validator struct {
 	fn     Func   // The function to call
 }
 
-func (v *validator) Validate(str string, params ...string) bool {
-	return v.fn(str, params...)
+func (v *validator) Validate(value reflect.Value, params ...string) bool {
+	return v.fn(value, params...)
 }
 
 func lookup(name string) (*validator, bool) {
@@ -0,0 +1,136 @@
+package validate
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/kelindar/folio/internal/walk"
+)
+
+// Condition is a validator which also depends on the sibling fields of the value, such as a field
+// which is required only when another one is set. It is called even if the value is empty.
+type Condition func(value reflect.Value, parent reflect.Value, params ...string) bool
+
+var conditions sync.Map
+
+func init() {
+	RegisterCondition("required_if", "%s is required when %s is %s", func(v, parent reflect.Value, params ...string) bool {
+		return !siblingsEqual(parent, params) || !walk.IsEmpty(v)
+	})
+
+	RegisterCondition("required_unless", "%s is required unless %s is %s", func(v, parent reflect.Value, params ...string) bool {
+		return siblingsEqual(parent, params) || !walk.IsEmpty(v)
+	})
+
+	RegisterCondition("required_with", "%s is required when %s is set", func(v, parent reflect.Value, params ...string) bool {
+		return !anySiblingSet(parent, params) || !walk.IsEmpty(v)
+	})
+
+	RegisterCondition("required_without", "%s is required when %s is not set", func(v, parent reflect.Value, params ...string) bool {
+		return allSiblingsSet(parent, params) || !walk.IsEmpty(v)
+	})
+
+	RegisterCondition("excluded_if", "%s must be empty when %s is %s", func(v, parent reflect.Value, params ...string) bool {
+		return !siblingsEqual(parent, params) || walk.IsEmpty(v)
+	})
+}
+
+// RegisterCondition registers a new validator which depends on the sibling fields.
+func RegisterCondition(name, format string, fn Condition) {
+	defer plans.Clear()
+	conditions.Store(name, &condition{
+		name:   name,
+		format: format,
+		fn:     fn,
+	})
+}
+
+type condition struct {
+	name   string    // Name of the validator
+	format string    // Format of the error message
+	fn     Condition // The function to call
+}
+
+func lookupCondition(name string) (*condition, bool) {
+	if v, ok := conditions.Load(name); ok {
+		return v.(*condition), true
+	}
+	return nil, false
+}
+
+// ---------------------------------- Siblings ----------------------------------
+
+// sibling returns the field of the struct with the JSON or Go name, dereferenced.
+func sibling(parent reflect.Value, name string) (reflect.Value, bool) {
+	typ := parent.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() || (nameOf(&field) != name && field.Name != name) {
+			continue
+		}
+
+		value := parent.Field(i)
+		for value.Kind() == reflect.Pointer && !value.IsNil() {
+			value = value.Elem()
+		}
+		return value, true
+	}
+	return reflect.Value{}, false
+}
+
+// siblingsEqual returns true if every sibling of the (name, value) pairs has the value.
+func siblingsEqual(parent reflect.Value, params []string) bool {
+	for i := 0; i+1 < len(params); i += 2 {
+		value, ok := sibling(parent, params[i])
+		if !ok || !equalTo(value, params[i+1]) {
+			return false
+		}
+	}
+	return len(params) >= 2
+}
+
+// equalTo returns true if the value equals the parameter, compared by the type of the value
+// like the other validators. A nil pointer is compared as the zero value of its type.
+func equalTo(v reflect.Value, param string) bool {
+	for v.Kind() == reflect.Pointer {
+		v = reflect.Zero(v.Type().Elem())
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(param)
+		return err == nil && v.Bool() == b
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		n, ok := compare(v, param)
+		return ok && n == 0
+	}
+
+	if v.Type() == typeTime {
+		n, ok := compare(v, param)
+		return ok && n == 0
+	}
+	return stringOf(v) == param
+}
+
+// anySiblingSet returns true if any of the siblings is not empty.
+func anySiblingSet(parent reflect.Value, names []string) bool {
+	for _, name := range names {
+		if value, ok := sibling(parent, name); ok && !walk.IsEmpty(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// allSiblingsSet returns true if none of the siblings is empty.
+func allSiblingsSet(parent reflect.Value, names []string) bool {
+	for _, name := range names {
+		if value, ok := sibling(parent, name); !ok || walk.IsEmpty(value) {
+			return false
+		}
+	}
+	return true
+}
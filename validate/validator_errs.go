@@ -79,25 +79,3 @@ func (es Errors) Error() string {
 	sort.Strings(errs)
 	return strings.Join(errs, ";")
 }
-
-// ---------------------------------- Path & Name ----------------------------------
-
-// withName sets the name of the error (or sub-errors) to the given name.
-func withName(err error, name string) error {
-	switch ex := err.(type) {
-	case Error:
-		ex.Name = name
-		return ex
-	case Errors:
-		for j, nested := range ex {
-			switch v := nested.(type) {
-			case Error:
-				v.Name = name
-				ex[j] = v
-			}
-		}
-
-		return ex
-	}
-	return err
-}
@@ -0,0 +1,239 @@
+package validate
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kelindar/folio/internal/walk"
+)
+
+// plans caches the compiled validation of every struct type
+var plans sync.Map
+
+// plan is the compiled validation of a struct type, with the validators of its fields resolved
+// and their parameters parsed once.
+type plan struct {
+	fields []fieldPlan // Fields which are validated or contain nested structs
+	err    error       // Invalid rules of the type, if any
+}
+
+// fieldPlan is the compiled validation of a field.
+type fieldPlan struct {
+	field      reflect.StructField // The field itself
+	path       string              // Name of the field in the path of the errors
+	name       string              // JSON name of the field, for the error messages
+	required   bool                // Whether the field is required
+	nested     bool                // Whether the field may contain nested structs
+	conditions []rule              // Conditional validators, checked even if empty
+	rules      []rule              // Validators, checked if not empty
+}
+
+// rule is a validator of a field with its parameters.
+type rule struct {
+	option string    // Option as written in the tag, e.g. "min(2)"
+	format string    // Format of the error message
+	args   []any     // Arguments of the error message
+	params []string  // Parameters of the validator
+	fn     Func      // Validator, or nil if the rule is invalid
+	cond   Condition // Conditional validator, if any
+}
+
+// Compile compiles and caches the validation of a struct type and of its nested structs, and
+// returns an error if any of their "is" tags is invalid.
+func Compile(typ reflect.Type) error {
+	return compile(typ, make(map[reflect.Type]bool))
+}
+
+// compile compiles the type and its nested types, visiting each type once.
+func compile(typ reflect.Type, visited map[reflect.Type]bool) error {
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() != reflect.Struct || visited[typ] {
+		return nil
+	}
+
+	visited[typ] = true
+	errs := []error{planOf(typ).err}
+	for i := 0; i < typ.NumField(); i++ {
+		if field := typ.Field(i); field.IsExported() {
+			errs = append(errs, compile(field.Type, visited))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// planOf returns the compiled validation of a struct type.
+func planOf(typ reflect.Type) *plan {
+	if p, ok := plans.Load(typ); ok {
+		return p.(*plan)
+	}
+
+	p := new(plan)
+	var errs []error
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		f, err := compileField(field)
+		errs = append(errs, err)
+		if f.required || f.nested || len(f.conditions) > 0 || len(f.rules) > 0 {
+			p.fields = append(p.fields, f)
+		}
+	}
+
+	p.err = errors.Join(errs...)
+	plans.Store(typ, p)
+	return p
+}
+
+// compileField compiles the "is" tag of a field.
+func compileField(field reflect.StructField) (fieldPlan, error) {
+	f := fieldPlan{
+		field:  field,
+		path:   cmp.Or(nameOf(&field), field.Name),
+		name:   nameOf(&field),
+		nested: isNested(field.Type),
+	}
+
+	tag := field.Tag.Get(rsTagName)
+	if tag == "-" {
+		return f, nil
+	}
+
+	var errs []error
+	options := parseOpts(tag)
+	for _, option := range options.orderedKeys() {
+		if option == "required" {
+			f.required = true
+			continue
+		}
+
+		matches := rxValidator.FindStringSubmatch(option)
+		if len(matches) == 0 {
+			f.rules = append(f.rules, rule{
+				option: option,
+				format: "validator is invalid or can't be applied to the field: %q",
+				args:   []any{option},
+			})
+			errs = append(errs, fmt.Errorf("validate: invalid rule %q on field %s", option, field.Name))
+			continue
+		}
+
+		// The arguments of the message are the name of the field, followed by the parameters
+		params := strings.Split(matches[2], "|")
+		args := make([]any, 0, len(params)+1)
+		args = append(args, f.name)
+		for _, param := range params {
+			args = append(args, param)
+		}
+
+		if cond, ok := lookupCondition(matches[1]); ok {
+			f.conditions = append(f.conditions, rule{option: option, format: cond.format, args: args, params: params, cond: cond.fn})
+			continue
+		}
+
+		vd, ok := lookup(matches[1])
+		if !ok {
+			f.rules = append(f.rules, rule{
+				option: option,
+				format: "unknown validator %q for field %s",
+				args:   []any{option, field.Name},
+			})
+			errs = append(errs, fmt.Errorf("validate: unknown validator %q on field %s", option, field.Name))
+			continue
+		}
+
+		f.rules = append(f.rules, rule{option: option, format: vd.format, args: args, params: params, fn: vd.fn})
+	}
+
+	return f, errors.Join(errs...)
+}
+
+// isNested returns whether a value of the type may contain structs to validate.
+func isNested(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Struct || typ.Kind() == reflect.Interface
+}
+
+// ---------------------------------- Execution ----------------------------------
+
+// validate validates the fields of a struct and of its nested structs.
+func (p *plan) validate(v reflect.Value, path []string, errs Errors) Errors {
+	for i := range p.fields {
+		f := &p.fields[i]
+		value := v.FieldByIndex(f.field.Index)
+		at := append(path[:len(path):len(path)], f.path)
+		if err := f.validate(value, v, at); err != nil {
+			errs = append(errs, err)
+		}
+
+		if f.nested {
+			errs = validateNested(value, at, errs)
+		}
+	}
+	return errs
+}
+
+// validate validates the value of a field, within its parent struct.
+func (f *fieldPlan) validate(value, parent reflect.Value, path []string) error {
+	for _, c := range f.conditions {
+		if !c.cond(value, parent, c.params...) {
+			return errorf(&f.field, path, c.option, c.format, c.args...)
+		}
+	}
+
+	// Pointers and interfaces are validated once dereferenced
+	for (value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface) && !value.IsNil() {
+		value = value.Elem()
+	}
+
+	if walk.IsEmpty(value) {
+		if f.required {
+			return errorf(&f.field, path, "required", "%s is a required field", f.name)
+		}
+		return nil
+	}
+
+	for _, r := range f.rules {
+		if r.fn == nil || !r.fn(value, r.params...) {
+			return errorf(&f.field, path, r.option, r.format, r.args...)
+		}
+	}
+	return nil
+}
+
+// validateNested validates the structs contained in the value.
+func validateNested(v reflect.Value, path []string, errs Errors) Errors {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return errs
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		errs = planOf(v.Type()).validate(v, path, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			errs = validateNested(v.Index(i), append(path[:len(path):len(path)], strconv.Itoa(i)), errs)
+		}
+	case reflect.Map:
+		for iter := v.MapRange(); iter.Next(); {
+			key := fmt.Sprintf("%v", iter.Key().Interface())
+			errs = validateNested(iter.Value(), append(path[:len(path):len(path)], key), errs)
+		}
+	}
+	return errs
+}
@@ -2,8 +2,11 @@ package validate
 
 import (
 	"fmt"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -46,6 +49,144 @@ type Car struct {
 	CompanyInfo *Company `json:"companyInfo,omitempty"`
 }
 
+// Account is representative of a registered kind, with a handful of validated fields and a
+// list of nested structs.
+type Account struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name" is:"required,lowercase,alphanum,minlen(2),maxlen(25)"`
+	Label    string    `json:"label" is:"required,minlen(2),maxlen(50)"`
+	Email    string    `json:"email" is:"email"`
+	Website  string    `json:"website" is:"url"`
+	Role     string    `json:"role" is:"in(viewer|editor|admin)"`
+	Quota    int       `json:"quota" is:"range(0|1000)"`
+	Desc     string    `json:"desc" is:"maxlen(255)"`
+	Contacts []Contact `json:"contacts"`
+}
+
+type Contact struct {
+	Name  string `json:"name" is:"required,maxlen(50)"`
+	Email string `json:"email" is:"required,email"`
+}
+
+/*
+Measured end to end with "go test -bench . -benchtime 2s -count 3", medians:
+
+cpu: Intel(R) Xeon(R) Processor
+BenchmarkStruct/account         	  122823	     19726 ns/op	     592 B/op	      15 allocs/op
+BenchmarkStruct/car             	  847443	      3301 ns/op	     472 B/op	      15 allocs/op
+BenchmarkStruct/shipment        	  340647	      8422 ns/op	    2752 B/op	      52 allocs/op
+BenchmarkStruct/invalid         	  227034	     10528 ns/op	    1384 B/op	      46 allocs/op
+BenchmarkParse/per-call         	   73408	     32856 ns/op	    8016 B/op	     107 allocs/op
+BenchmarkParse/compiled         	96660464	        24.69 ns/op	       0 B/op	       0 allocs/op
+
+Before the validation plans were compiled and cached per type, with the same benchmarks:
+BenchmarkStruct/account         	   23846	    105394 ns/op	   14834 B/op	     246 allocs/op
+BenchmarkStruct/car             	   51858	     45968 ns/op	    9976 B/op	     183 allocs/op
+BenchmarkStruct/shipment        	   35683	     68638 ns/op	   13944 B/op	     210 allocs/op
+BenchmarkStruct/invalid         	   61450	     36828 ns/op	    6705 B/op	     133 allocs/op
+*/
+func BenchmarkStruct(b *testing.B) {
+	car := &Car{
+		Type:    "sedan",
+		Year:    2020,
+		Engine:  &Engine{Type: "V8", Power: 300},
+		Engines: []Engine{{Type: "V6"}, {Type: "V8"}},
+		CompanyInfo: &Company{
+			Name:    "Acme",
+			Address: &Address{Street: "Main", City: "Paris"},
+		},
+	}
+
+	shipment := &Shipment{
+		Tags:     []string{"fragile", "cold"},
+		Weight:   10,
+		Deadline: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		Transit:  time.Hour,
+		Carrier:  Code{"ups", "1"},
+	}
+
+	account := &Account{
+		ID:      "01hx0000000000000000000000",
+		Name:    "acme",
+		Label:   "Acme Corporation",
+		Email:   "ops@acme.com",
+		Website: "https://acme.com",
+		Role:    "editor",
+		Quota:   100,
+		Desc:    "Account of the operations team",
+		Contacts: []Contact{
+			{Name: "Alice", Email: "alice@acme.com"},
+			{Name: "Bob", Email: "bob@acme.com"},
+		},
+	}
+
+	b.Run("account", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Struct(account)
+		}
+	})
+
+	b.Run("car", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Struct(car)
+		}
+	})
+
+	b.Run("shipment", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Struct(shipment)
+		}
+	})
+
+	b.Run("invalid", func(b *testing.B) {
+		invalid := &Applicant{IsEmployed: true, Benefits: []string{"car"}}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Struct(invalid)
+		}
+	})
+}
+
+func BenchmarkParse(b *testing.B) {
+	typ := reflect.TypeOf(Shipment{})
+
+	// Baseline, parsing the tags on every call as before the plans were compiled
+	b.Run("per-call", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			parseEach(typ)
+		}
+	})
+
+	b.Run("compiled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			planOf(typ)
+		}
+	})
+}
+
+// parseEach parses the tags of the fields with the regular expression and looks up their
+// validators, as it was done on every call before the plans were compiled.
+func parseEach(typ reflect.Type) (rules int) {
+	for i := 0; i < typ.NumField(); i++ {
+		for _, option := range parseOpts(typ.Field(i).Tag.Get(rsTagName)).orderedKeys() {
+			matches := rxValidator.FindStringSubmatch(option)
+			if len(matches) == 0 {
+				continue
+			}
+
+			if _, ok := lookup(matches[1]); ok {
+				rules += len(strings.Split(matches[2], "|"))
+			}
+		}
+	}
+	return rules
+}
+
 func TestValidate_Simple(t *testing.T) {
 	car := &Car{
 		Type:        "car",
@@ -309,3 +450,285 @@ func TestRequired(t *testing.T) {
 		})
 	}
 }
+
+type Applicant struct {
+	Name       string   `json:"name"`
+	IsEmployed bool     `json:"isEmployed"`
+	JobTitle   string   `json:"jobTitle" is:"required_if(isEmployed|true)"`
+	Employer   *Address `json:"employer" is:"required_with(jobTitle)"`
+	Phone      string   `json:"phone" is:"required_without(email)"`
+	Email      string   `json:"email" is:"required_unless(isEmployed|false),email"`
+	Benefits   []string `json:"benefits" is:"excluded_if(isEmployed|false)"`
+	References []struct {
+		Name    string `json:"name"`
+		Contact string `json:"contact" is:"required_with(name)"`
+	} `json:"references"`
+}
+
+func TestConditions(t *testing.T) {
+	tests := map[string]struct {
+		input    Applicant
+		expected []string
+	}{
+		"unemployed": {
+			input:    Applicant{Phone: "123"},
+			expected: nil,
+		},
+		"employed without a job title": {
+			input:    Applicant{IsEmployed: true, Email: "a@b.com"},
+			expected: []string{"jobTitle is required when isEmployed is true"},
+		},
+		"employed with a job title": {
+			input: Applicant{IsEmployed: true, JobTitle: "engineer", Employer: &Address{City: "Paris"}, Email: "a@b.com"},
+		},
+		"job title without an employer": {
+			input:    Applicant{IsEmployed: true, JobTitle: "engineer", Email: "a@b.com"},
+			expected: []string{"employer is required when jobTitle is set"},
+		},
+		"no way to contact": {
+			input: Applicant{},
+			expected: []string{
+				"phone is required when email is not set",
+			},
+		},
+		"employed without an email": {
+			input:    Applicant{IsEmployed: true, JobTitle: "engineer", Employer: &Address{}, Phone: "123"},
+			expected: []string{"email is required unless isEmployed is false"},
+		},
+		"benefits while unemployed": {
+			input:    Applicant{Phone: "123", Benefits: []string{"car"}},
+			expected: []string{"benefits must be empty when isEmployed is false"},
+		},
+		"invalid email": {
+			input:    Applicant{Phone: "123", Email: "invalid"},
+			expected: []string{"email must be a valid email address"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ok, err := Struct(&tc.input)
+			assert.Equal(t, len(tc.expected) == 0, ok, err)
+			if len(tc.expected) == 0 {
+				return
+			}
+
+			var messages []string
+			for _, e := range err.(Errors).Errors() {
+				messages = append(messages, e.Message())
+			}
+			assert.Equal(t, tc.expected, messages)
+		})
+	}
+}
+
+func TestConditions_Nested(t *testing.T) {
+	applicant := &Applicant{Phone: "123"}
+	applicant.References = append(applicant.References, struct {
+		Name    string `json:"name"`
+		Contact string `json:"contact" is:"required_with(name)"`
+	}{Name: "alice"})
+
+	ok, err := Struct(applicant)
+	assert.False(t, ok)
+
+	errs := err.(Errors).Errors()
+	assert.Len(t, errs, 1)
+	assert.Equal(t, []string{"references", "0", "contact"}, errs[0].Path)
+	assert.Equal(t, "contact is required when name is set", errs[0].Message())
+}
+
+type Order struct {
+	Express  *bool         `json:"express"`
+	Courier  string        `json:"courier" is:"required_if(express|true)"`
+	Priority *int          `json:"priority"`
+	Reason   string        `json:"reason" is:"excluded_if(priority|0)"`
+	Paid     bool          `json:"paid"`
+	Invoice  string        `json:"invoice" is:"required_if(paid|1)"`
+	Window   time.Duration `json:"window"`
+	Slot     string        `json:"slot" is:"required_if(window|60m)"`
+}
+
+func TestConditions_Typed(t *testing.T) {
+	express, priority := true, 2
+	tests := map[string]struct {
+		input    Order
+		expected []string
+	}{
+		"none":        {input: Order{}},
+		"not express": {input: Order{Express: new(bool)}},
+		"express without courier": {
+			input:    Order{Express: &express},
+			expected: []string{"courier is required when express is true"},
+		},
+		"nil priority is zero": {
+			input:    Order{Reason: "late"},
+			expected: []string{"reason must be empty when priority is 0"},
+		},
+		"priority with reason": {input: Order{Priority: &priority, Reason: "late"}},
+		"paid without invoice": {
+			input:    Order{Paid: true},
+			expected: []string{"invoice is required when paid is 1"},
+		},
+		"window without slot": {
+			input:    Order{Window: time.Hour},
+			expected: []string{"slot is required when window is 60m"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ok, err := Struct(&tc.input)
+			assert.Equal(t, len(tc.expected) == 0, ok, err)
+			if len(tc.expected) == 0 {
+				return
+			}
+
+			var messages []string
+			for _, e := range err.(Errors).Errors() {
+				messages = append(messages, e.Message())
+			}
+			assert.Equal(t, tc.expected, messages)
+		})
+	}
+}
+
+// Code is a stringer, such as a URN, which is validated by its string representation.
+type Code struct{ Prefix, ID string }
+
+func (c Code) String() string { return c.Prefix + ":" + c.ID }
+
+type Shipment struct {
+	Tags     []string          `json:"tags" is:"min(2),max(3),in(fragile|cold|heavy|urgent)"`
+	Labels   map[string]string `json:"labels" is:"maxlen(1)"`
+	Weight   float64           `json:"weight" is:"range(0.5|99.5)"`
+	Count    uint64            `json:"count" is:"max(18446744073709551615)"`
+	Serial   int64             `json:"serial" is:"min(9007199254740993)"`
+	Deadline time.Time         `json:"deadline" is:"min(2024-01-01),max(2030-01-01T00:00:00Z)"`
+	Transit  time.Duration     `json:"transit" is:"max(48h)"`
+	Carrier  Code              `json:"carrier" is:"matches(^ups:)"`
+	Codes    []Code            `json:"codes" is:"matches(^[a-z]+:[0-9]+$)"`
+	Note     *string           `json:"note" is:"maxlen(5)"`
+}
+
+func TestTyped(t *testing.T) {
+	valid := func() Shipment {
+		return Shipment{
+			Tags:     []string{"fragile", "cold"},
+			Labels:   map[string]string{"a": "b"},
+			Weight:   99.5,
+			Count:    18446744073709551615,
+			Serial:   9007199254740993,
+			Deadline: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+			Transit:  24 * time.Hour,
+			Carrier:  Code{"ups", "1"},
+			Codes:    []Code{{"dhl", "1"}, {"ups", "2"}},
+		}
+	}
+
+	tests := map[string]struct {
+		update   func(*Shipment)
+		expected []string
+	}{
+		"valid": {
+			update: func(s *Shipment) {},
+		},
+		"too few tags": {
+			update:   func(s *Shipment) { s.Tags = []string{"cold"} },
+			expected: []string{"tags must be at least 2"},
+		},
+		"too many tags": {
+			update:   func(s *Shipment) { s.Tags = []string{"cold", "fragile", "heavy", "urgent"} },
+			expected: []string{"tags must be at most 3"},
+		},
+		"unknown tag": {
+			update:   func(s *Shipment) { s.Tags = []string{"cold", "wet"} },
+			expected: []string{"tags must be one of allowed values"},
+		},
+		"too many labels": {
+			update:   func(s *Shipment) { s.Labels["c"] = "d" },
+			expected: []string{"labels must be at most 1 characters long"},
+		},
+		"too heavy": {
+			update:   func(s *Shipment) { s.Weight = 99.50001 },
+			expected: []string{"weight must be between 0.5 and 99.5"},
+		},
+		"serial without precision loss": {
+			update:   func(s *Shipment) { s.Serial = 9007199254740992 },
+			expected: []string{"serial must be at least 9007199254740993"},
+		},
+		"deadline too early": {
+			update:   func(s *Shipment) { s.Deadline = time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC) },
+			expected: []string{"deadline must be at least 2024-01-01"},
+		},
+		"deadline too late": {
+			update:   func(s *Shipment) { s.Deadline = time.Date(2030, 1, 1, 0, 0, 1, 0, time.UTC) },
+			expected: []string{"deadline must be at most 2030-01-01T00:00:00Z"},
+		},
+		"transit too long": {
+			update:   func(s *Shipment) { s.Transit = 49 * time.Hour },
+			expected: []string{"transit must be at most 48h"},
+		},
+		"wrong carrier": {
+			update:   func(s *Shipment) { s.Carrier = Code{"dhl", "1"} },
+			expected: []string{"carrier must match ^ups:"},
+		},
+		"invalid code": {
+			update:   func(s *Shipment) { s.Codes = append(s.Codes, Code{"dhl", "x"}) },
+			expected: []string{"codes must match ^[a-z]+:[0-9]+$"},
+		},
+		"pointer is validated once": {
+			update: func(s *Shipment) {
+				note := "too long"
+				s.Note = &note
+			},
+			expected: []string{"note must be at most 5 characters long"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			input := valid()
+			tc.update(&input)
+
+			ok, err := Struct(&input)
+			assert.Equal(t, len(tc.expected) == 0, ok, err)
+			if len(tc.expected) == 0 {
+				return
+			}
+
+			var messages []string
+			for _, e := range err.(Errors).Errors() {
+				messages = append(messages, e.Message())
+			}
+			assert.Equal(t, tc.expected, messages)
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	type Node struct {
+		Name     string  `json:"name" is:"required"`
+		Children []*Node `json:"children"`
+	}
+
+	// Recursive types are compiled once and the plan is cached
+	assert.NoError(t, Compile(reflect.TypeOf(&Node{})))
+	plan, ok := plans.Load(reflect.TypeOf(Node{}))
+	assert.True(t, ok)
+	assert.Same(t, plan, planOf(reflect.TypeOf(Node{})))
+
+	ok, err := Struct(&Node{Name: "root", Children: []*Node{{Name: "a"}, {}}})
+	assert.False(t, ok)
+	assert.EqualError(t, err, "children.1.name: name is a required field")
+
+	// Invalid tags are reported once compiled, and when the field is validated
+	type Invalid struct {
+		Name string `json:"name" is:"unknown"`
+	}
+
+	assert.ErrorContains(t, Compile(reflect.TypeOf(Invalid{})), `unknown validator "unknown" on field Name`)
+	ok, err = Struct(&Invalid{Name: "a"})
+	assert.False(t, ok)
+	assert.EqualError(t, err, `name: unknown validator "unknown" for field Name`)
+}
@@ -12,18 +12,20 @@ import (
 
 func init() {
 
+	// Typed validators
+	Register("range", "%s must be between %v and %v", rangeOf)
+	Register("length", "%s must be between %v and %v", lengthBetween(true))
+	Register("runelength", "%s must be between %v and %v", lengthBetween(false))
+	Register("stringlength", "%s must be between %v and %v", lengthBetween(false))
+	Register("minlen", "%s must be at least %v characters long", minLength)
+	Register("maxlen", "%s must be at most %v characters long", maxLength)
+	Register("min", "%s must be at least %v", minOf)
+	Register("max", "%s must be at most %v", maxOf)
+
 	// Variadic validators
-	Register("range", "%s must be between %v and %v", Range)
-	Register("length", "%s must be between %v and %v", ByteLength)
-	Register("runelength", "%s must be between %v and %v", RuneLength)
-	Register("stringlength", "%s must be between %v and %v", StringLength)
-	Register("matches", "%s must match %v", StringMatches)
-	Register("in", "%s must be one of allowed values", IsIn)
-	Register("flags", "%s must contain only allowed values", IsFlags)
-	Register("minlen", "%s must be at least %v characters long", MinStringLength)
-	Register("maxlen", "%s must be at most %v characters long", MaxStringLength)
-	Register("min", "%s must be at least %v", Min)
-	Register("max", "%s must be at most %v", Max)
+	Register("matches", "%s must match %v", String(StringMatches))
+	Register("in", "%s must be one of allowed values", String(IsIn))
+	Register("flags", "%s must contain only allowed values", String(IsFlags))
 
 	// Standard validators
 	Register("snake", "%s must be in snake case", variadic(IsSnakeCase))
@@ -134,9 +136,6 @@ var (
 	rxSnakeCase           = regexp.MustCompile(`^[a-z][a-z0-9_]+$`)
 )
 
-// Func is a wrapper for validator functions that accept additional parameters.
-type Func func(str string, params ...string) bool
-
 type opts map[string]tagOption
 
 func (t opts) orderedKeys() []string {
@@ -0,0 +1,227 @@
+package validate
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kelindar/folio/internal/convert"
+)
+
+var (
+	typeTime     = reflect.TypeFor[time.Time]()
+	typeDuration = reflect.TypeFor[time.Duration]()
+)
+
+// Func is a validator function, which receives the typed value of the field along with the
+// parameters of the tag.
+type Func func(v reflect.Value, params ...string) bool
+
+// String adapts a validator of strings into a Func. The value is converted with its String
+// method if it has one (e.g. a URN), and collections are valid when every element is valid.
+func String(fn func(str string, params ...string) bool) Func {
+	var check Func
+	check = func(v reflect.Value, params ...string) bool {
+		v = indirect(v)
+		switch v.Kind() {
+		case reflect.Slice, reflect.Array:
+			if v.Type().Elem().Kind() == reflect.Uint8 {
+				break // bytes are validated as a string
+			}
+
+			for i := 0; i < v.Len(); i++ {
+				if !check(v.Index(i), params...) {
+					return false
+				}
+			}
+			return true
+		}
+
+		return fn(stringOf(v), params...)
+	}
+	return check
+}
+
+// indirect dereferences the pointers and interfaces of the value.
+func indirect(v reflect.Value) reflect.Value {
+	for (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// stringOf returns the string representation of a value.
+func stringOf(v reflect.Value) string {
+	switch {
+	case !v.IsValid() || !v.CanInterface():
+		return ""
+	case v.Kind() == reflect.String:
+		return v.String()
+	}
+
+	switch x := v.Interface().(type) {
+	case time.Time:
+		return x.Format(time.RFC3339)
+	case []byte:
+		return string(x)
+	case fmt.Stringer:
+		return x.String()
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// ---------------------------------- Comparison ----------------------------------
+
+// compare compares the value with the parameter. Numbers and times are compared by value,
+// collections by their length and strings either by their numeric value or their length.
+// It returns false if the value cannot be compared with the parameter.
+func compare(v reflect.Value, param string) (int, bool) {
+	v = indirect(v)
+	switch v.Type() {
+	case typeTime:
+		at, ok := parseTime(param)
+		if !ok {
+			return 0, false
+		}
+		return v.Interface().(time.Time).Compare(at), true
+	case typeDuration:
+		if d, err := time.ParseDuration(param); err == nil {
+			return cmp.Compare(time.Duration(v.Int()), d), true
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if p, err := strconv.ParseInt(param, 10, 64); err == nil {
+			return cmp.Compare(v.Int(), p), true
+		}
+		if p, err := strconv.ParseFloat(param, 64); err == nil {
+			return cmp.Compare(float64(v.Int()), p), true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if p, err := strconv.ParseUint(param, 10, 64); err == nil {
+			return cmp.Compare(v.Uint(), p), true
+		}
+		if p, err := strconv.ParseFloat(param, 64); err == nil {
+			return cmp.Compare(float64(v.Uint()), p), true
+		}
+	case reflect.Float32, reflect.Float64:
+		if p, err := strconv.ParseFloat(param, 64); err == nil {
+			return cmp.Compare(v.Float(), p), true
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if p, err := strconv.Atoi(param); err == nil {
+			return cmp.Compare(v.Len(), p), true
+		}
+	case reflect.String:
+		value, err := convert.Float64(v.String())
+		if err != nil {
+			value = float64(utf8.RuneCountInString(v.String()))
+		}
+
+		if p, err := convert.Float64(param); err == nil {
+			return cmp.Compare(value, p), true
+		}
+	}
+	return 0, false
+}
+
+// parseTime parses the parameter of a time comparison.
+func parseTime(param string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339, time.DateTime, time.DateOnly} {
+		if at, err := time.Parse(layout, param); err == nil {
+			return at, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// minOf checks whether the value is at least the parameter.
+func minOf(v reflect.Value, params ...string) bool {
+	if len(params) != 1 {
+		return false
+	}
+
+	c, ok := compare(v, params[0])
+	return ok && c >= 0
+}
+
+// maxOf checks whether the value is at most the parameter.
+func maxOf(v reflect.Value, params ...string) bool {
+	if len(params) != 1 {
+		return false
+	}
+
+	c, ok := compare(v, params[0])
+	return ok && c <= 0
+}
+
+// rangeOf checks whether the value is between the parameters, in any order.
+func rangeOf(v reflect.Value, params ...string) bool {
+	if len(params) != 2 {
+		return false
+	}
+
+	lo, ok1 := compare(v, params[0])
+	hi, ok2 := compare(v, params[1])
+	return ok1 && ok2 && ((lo >= 0 && hi <= 0) || (lo <= 0 && hi >= 0))
+}
+
+// ---------------------------------- Length ----------------------------------
+
+// lengthOf returns the number of elements of a collection, or the length of a string in runes
+// or in bytes.
+func lengthOf(v reflect.Value, bytes bool) (int, bool) {
+	v = indirect(v)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len(), true
+	case reflect.String:
+		if bytes {
+			return v.Len(), true
+		}
+		return utf8.RuneCountInString(v.String()), true
+	default:
+		return utf8.RuneCountInString(stringOf(v)), true
+	}
+}
+
+// lengthBetween returns a validator of the length of the value, between both parameters.
+func lengthBetween(bytes bool) Func {
+	return func(v reflect.Value, params ...string) bool {
+		if len(params) != 2 {
+			return false
+		}
+
+		n, _ := lengthOf(v, bytes)
+		lo, err1 := strconv.Atoi(params[0])
+		hi, err2 := strconv.Atoi(params[1])
+		return err1 == nil && err2 == nil && n >= lo && n <= hi
+	}
+}
+
+// minLength checks the minimum length of the value.
+func minLength(v reflect.Value, params ...string) bool {
+	if len(params) != 1 {
+		return false
+	}
+
+	n, _ := lengthOf(v, false)
+	min, err := strconv.Atoi(params[0])
+	return err == nil && n >= min
+}
+
+// maxLength checks the maximum length of the value.
+func maxLength(v reflect.Value, params ...string) bool {
+	if len(params) != 1 {
+		return false
+	}
+
+	n, _ := lengthOf(v, false)
+	max, err := strconv.Atoi(params[0])
+	return err == nil && n <= max
+}